@@ -0,0 +1,19 @@
+ALTER TABLE track
+ADD COLUMN disc_number SMALLINT(5) NOT NULL DEFAULT 1;
+
+ALTER TABLE track
+ADD COLUMN track_number SMALLINT(5) NOT NULL DEFAULT 0;
+
+-- Number existing tracks in the order they were added to their album.
+UPDATE track
+INNER JOIN (
+    SELECT
+        id,
+        ROW_NUMBER() OVER (PARTITION BY album ORDER BY id) AS position
+    FROM track
+) AS numbered
+    ON numbered.id = track.id
+SET track.track_number = numbered.position;
+
+CREATE INDEX track_position
+    ON track (album, disc_number, track_number);
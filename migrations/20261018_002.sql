@@ -0,0 +1,23 @@
+ALTER TABLE album
+ADD COLUMN state ENUM('draft', 'in_review', 'approved', 'scheduled', 'published', 'archived') NOT NULL DEFAULT 'draft'
+AFTER artist;
+
+UPDATE album
+SET state = 'published'
+WHERE published = TRUE;
+
+ALTER TABLE album
+DROP COLUMN published;
+
+CREATE TABLE IF NOT EXISTS album_state_history(
+    id BIGINT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+    album BIGINT NOT NULL,
+    from_state ENUM('draft', 'in_review', 'approved', 'scheduled', 'published', 'archived') NOT NULL,
+    to_state ENUM('draft', 'in_review', 'approved', 'scheduled', 'published', 'archived') NOT NULL,
+    changed_by VARCHAR(255) NOT NULL DEFAULT '',
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT album_state_history_to_album_mapping
+        FOREIGN KEY (album)
+        REFERENCES album(id)
+        ON DELETE CASCADE
+);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS title_proposal(
+    id BIGINT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+    album BIGINT NULL DEFAULT NULL,
+    track BIGINT NULL DEFAULT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    proposed_by VARCHAR(255) NOT NULL DEFAULT '',
+    status ENUM('open', 'accepted', 'rejected') NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT title_proposal_has_one_target
+        CHECK ((album IS NULL) <> (track IS NULL)),
+    CONSTRAINT title_proposal_to_album_mapping
+        FOREIGN KEY (album)
+        REFERENCES album(id)
+        ON DELETE CASCADE,
+    CONSTRAINT title_proposal_to_track_mapping
+        FOREIGN KEY (track)
+        REFERENCES track(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS title_proposal_vote(
+    proposal BIGINT NOT NULL,
+    voter VARCHAR(255) NOT NULL,
+    vote TINYINT NOT NULL,
+    PRIMARY KEY (proposal, voter),
+    CONSTRAINT title_proposal_vote_to_proposal_mapping
+        FOREIGN KEY (proposal)
+        REFERENCES title_proposal(id)
+        ON DELETE CASCADE
+);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS comment(
+    id BIGINT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+    album BIGINT NULL DEFAULT NULL,
+    track BIGINT NULL DEFAULT NULL,
+    parent BIGINT NULL DEFAULT NULL,
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    resolved BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP NULL DEFAULT NULL,
+    deleted_at TIMESTAMP NULL DEFAULT NULL,
+    CONSTRAINT comment_has_one_target
+        CHECK ((album IS NULL) <> (track IS NULL)),
+    CONSTRAINT comment_to_album_mapping
+        FOREIGN KEY (album)
+        REFERENCES album(id)
+        ON DELETE CASCADE,
+    CONSTRAINT comment_to_track_mapping
+        FOREIGN KEY (track)
+        REFERENCES track(id)
+        ON DELETE CASCADE,
+    CONSTRAINT comment_to_parent_mapping
+        FOREIGN KEY (parent)
+        REFERENCES comment(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX comment_album
+    ON comment (album, deleted_at);
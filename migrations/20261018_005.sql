@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS user(
+    id BIGINT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+    username VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Sessions are keyed by a hash of the token handed out in the session cookie,
+-- so the contents of this table alone can't be used to log in.
+CREATE TABLE IF NOT EXISTS session(
+    id CHAR(64) PRIMARY KEY NOT NULL,
+    user BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    CONSTRAINT session_to_user_mapping
+        FOREIGN KEY (user)
+        REFERENCES user(id)
+        ON DELETE CASCADE
+);
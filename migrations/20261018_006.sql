@@ -0,0 +1,3 @@
+ALTER TABLE user
+ADD COLUMN role ENUM('viewer', 'contributor', 'editor', 'admin') NOT NULL DEFAULT 'viewer'
+AFTER password_hash;
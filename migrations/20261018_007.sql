@@ -0,0 +1,16 @@
+-- Like sessions, API tokens are only stored hashed. Scopes are a comma
+-- separated list of the permissions the token may use on behalf of its user.
+CREATE TABLE IF NOT EXISTS api_token(
+    id BIGINT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+    user BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    scopes VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    last_used_at DATETIME NULL,
+    CONSTRAINT api_token_to_user_mapping
+        FOREIGN KEY (user)
+        REFERENCES user(id)
+        ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+-- Users provisioned through single sign-on are tied to the provider's subject
+-- rather than their username, which the provider may let them change. They
+-- have no password of their own.
+ALTER TABLE user
+ADD COLUMN oidc_subject VARCHAR(255) NULL UNIQUE
+AFTER role;
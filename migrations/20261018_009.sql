@@ -0,0 +1,8 @@
+-- Every change to an album or track bumps its version, so that an edit based
+-- on an out of date copy can be turned away instead of silently overwriting
+-- someone else's.
+ALTER TABLE album
+ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE track
+ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,22 @@
+-- Positions were only checked before saving, so tracks could end up sharing
+-- one, or at position 0. Each disc is numbered again from 1, keeping its
+-- tracks in the same order, before positions are made unique.
+UPDATE track
+SET disc_number = 1
+WHERE disc_number = 0;
+
+UPDATE track
+INNER JOIN (
+    SELECT
+        id,
+        ROW_NUMBER() OVER (PARTITION BY album, disc_number ORDER BY track_number, id) AS position
+    FROM track
+) AS numbered
+    ON numbered.id = track.id
+SET track.track_number = numbered.position;
+
+DROP INDEX track_position
+    ON track;
+
+CREATE UNIQUE INDEX track_position
+    ON track (album, disc_number, track_number);
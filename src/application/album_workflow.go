@@ -0,0 +1,155 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/kataras/muxie"
+)
+
+/*
+The states an album may move to from each state. Anything not listed here is
+rejected.
+*/
+var albumTransitions = map[model.AlbumState][]model.AlbumState{
+	model.AlbumDraft: {
+		model.AlbumInReview,
+		model.AlbumArchived,
+	},
+	model.AlbumInReview: {
+		model.AlbumDraft,
+		model.AlbumApproved,
+		model.AlbumArchived,
+	},
+	model.AlbumApproved: {
+		model.AlbumInReview,
+		model.AlbumScheduled,
+		model.AlbumPublished,
+		model.AlbumArchived,
+	},
+	model.AlbumScheduled: {
+		model.AlbumApproved,
+		model.AlbumPublished,
+		model.AlbumArchived,
+	},
+	model.AlbumPublished: {
+		model.AlbumArchived,
+	},
+	model.AlbumArchived: {
+		model.AlbumDraft,
+	},
+}
+
+/*
+Body of a request to move an album to a new state.
+*/
+type albumStateRequest struct {
+	State model.AlbumState `json:"state"`
+}
+
+func parseAlbumState(value string) (model.AlbumState, error) {
+	names := make([]string, len(model.AlbumStates))
+	for index, state := range model.AlbumStates {
+		if string(state) == value {
+			return state, nil
+		}
+		names[index] = string(state)
+	}
+
+	return "", fmt.Errorf("Invalid state provided. Must be one of %s.", strings.Join(names, ", "))
+}
+
+/*
+Check that an album is allowed to move between two states, returning a
+description of the allowed moves if it isn't.
+*/
+func checkAlbumTransition(from model.AlbumState, to model.AlbumState) error {
+	allowed := albumTransitions[from]
+
+	names := make([]string, len(allowed))
+	for index, state := range allowed {
+		if state == to {
+			return nil
+		}
+		names[index] = string(state)
+	}
+
+	return fmt.Errorf(
+		"Cannot move album from %s to %s. Allowed: %s.",
+		from,
+		to,
+		strings.Join(names, ", "),
+	)
+}
+
+func (this App) changeAlbumState(out http.ResponseWriter, req *http.Request) {
+	var albumId int64
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
+		return
+	}
+
+	var request albumStateRequest
+	if err := muxie.JSON.Bind(req, &request); err != nil {
+		writeProblem(out, req, ProblemInvalidJson, "Invalid state change provided.")
+		return
+	}
+
+	to, err := parseAlbumState(string(request.State))
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidRequest, err.Error())
+		return
+	}
+
+	album, err := this.db.Album.Load(req.Context(), albumId)
+	if err != nil {
+		writeLoadError(out, req, err, "Album not found.")
+		return
+	}
+
+	if err = checkAlbumTransition(album.State, to); err != nil {
+		writeProblem(out, req, ProblemInvalidTransition, err.Error())
+		return
+	}
+
+	err = this.db.Album.SetState(req.Context(), model.AlbumStateChange{
+		AlbumId:   albumId,
+		From:      album.State,
+		To:        to,
+		ChangedBy: currentUser(req).Username,
+	})
+
+	if errors.Is(err, dao.ErrConflict) {
+		writeProblem(out, req, ProblemConcurrentChange, "Album was changed by someone else, please try again.")
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	album.State = to
+	muxie.JSON.Dispatch(out, album)
+}
+
+func (this App) retrieveAlbumHistory(out http.ResponseWriter, req *http.Request) {
+	var albumId int64
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
+		return
+	}
+
+	if _, err := this.db.Album.Load(req.Context(), albumId); err != nil {
+		writeLoadError(out, req, err, "Album not found.")
+		return
+	}
+
+	history, err := this.db.Album.LoadHistory(req.Context(), albumId)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+	muxie.JSON.Dispatch(out, history)
+}
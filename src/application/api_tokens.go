@@ -0,0 +1,186 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"citadel_intranet/src/auth"
+	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/logging"
+
+	"github.com/kataras/muxie"
+)
+
+const (
+	DefaultApiTokenLifetimeDays = 90
+	MaxApiTokenLifetimeDays     = 365
+
+	// Scripts can make a lot of requests in a hurry, so the last used time
+	// of a token is only kept to within this much, rather than written on
+	// every request.
+	apiTokenTouchInterval = time.Minute
+)
+
+type apiTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Days   int      `json:"days"`
+}
+
+/*
+A newly created token, the only time the token itself is ever handed out.
+*/
+type createdApiToken struct {
+	model.ApiToken
+	Token string `json:"token"`
+}
+
+/*
+The API token a request was made with, or nil if it was made by logging in.
+*/
+func currentApiToken(req *http.Request) *model.ApiToken {
+	token, _ := req.Context().Value(apiTokenContextKey).(*model.ApiToken)
+	return token
+}
+
+/*
+Look up the user owning the API token in an Authorization header, along with
+the token itself.
+
+Returns nils if the header isn't a bearer token, or the token is unknown or
+expired
+*/
+func (this App) apiTokenUser(ctx context.Context, header string) (*model.User, *model.ApiToken) {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return nil, nil
+	}
+
+	token := this.db.ApiToken.LoadByHash(auth.ApiTokenHash(strings.TrimSpace(parts[1])))
+	now := time.Now().UTC()
+	if token == nil || token.IsExpired(now) {
+		return nil, nil
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenTouchInterval {
+		if err := this.db.ApiToken.Touch(token.Id, now); err != nil {
+			logging.FromContext(ctx).Warn("Unable to record API token use ", err.Error())
+		}
+	}
+
+	user := this.db.User.Load(token.UserId)
+	if user == nil {
+		return nil, nil
+	}
+
+	return user, token
+}
+
+/*
+Wrap a handler so it can only be used after logging in, and not with an API
+token. Stops a leaked token from being used to mint more tokens, or to take
+over its user's account.
+*/
+func loggedInOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		if currentApiToken(req) != nil {
+			writeProblem(out, req, ProblemTokenNotAllowed, "API tokens cannot be used for this, please log in.")
+			return
+		}
+
+		handler(out, req)
+	}
+}
+
+func (this App) retrieveApiTokens(out http.ResponseWriter, req *http.Request) {
+	muxie.JSON.Dispatch(out, this.db.ApiToken.LoadForUser(currentUser(req).Id))
+}
+
+func (this App) createApiToken(out http.ResponseWriter, req *http.Request) {
+	user := currentUser(req)
+
+	request := apiTokenRequest{}
+	if !bindJSON(out, req, &request) {
+		return
+	}
+
+	if request.Name == "" {
+		writeProblem(out, req, ProblemInvalidRequest, "API tokens must have a name.")
+		return
+	}
+
+	if request.Days == 0 {
+		request.Days = DefaultApiTokenLifetimeDays
+	}
+
+	if request.Days < 0 || request.Days > MaxApiTokenLifetimeDays {
+		writeProblem(out, req, ProblemInvalidRequest, fmt.Sprintf("Invalid lifetime provided. Must be between 1 and %d days.", MaxApiTokenLifetimeDays))
+		return
+	}
+
+	for _, scope := range request.Scopes {
+		permission := Permission(scope)
+		if _, found := permissionDescriptions[permission]; !found {
+			writeProblem(out, req, ProblemInvalidRequest, fmt.Sprintf("Invalid scope provided: %q.", scope))
+			return
+		}
+
+		if !can(user, permission) {
+			writeProblem(out, req, ProblemForbidden, fmt.Sprintf("You cannot give a token a scope you don't have: %q.", scope))
+			return
+		}
+	}
+
+	secret, err := auth.NewApiToken()
+	if err != nil {
+		writeInternalError(out, req, err)
+		return
+	}
+
+	token := model.ApiToken{
+		UserId:    user.Id,
+		Name:      request.Name,
+		TokenHash: auth.ApiTokenHash(secret),
+		Scopes:    request.Scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: time.Now().UTC().AddDate(0, 0, request.Days),
+	}
+	if token.Scopes == nil {
+		token.Scopes = make([]string, 0)
+	}
+
+	token.Id, err = this.db.ApiToken.Save(token)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	out.WriteHeader(http.StatusCreated)
+	muxie.JSON.Dispatch(out, &createdApiToken{
+		ApiToken: token,
+		Token:    secret,
+	})
+}
+
+func (this App) removeApiToken(out http.ResponseWriter, req *http.Request) {
+	var tokenId int64
+	if tokenId = parseIdFromUrl(out, req); tokenId == 0 {
+		return
+	}
+
+	rows, err := this.db.ApiToken.Delete(model.ApiToken{
+		Id:     tokenId,
+		UserId: currentUser(req).Id,
+	})
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	if rows == 0 {
+		writeProblem(out, req, ProblemNotFound, "API token not found.")
+	}
+}
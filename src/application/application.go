@@ -1,54 +1,233 @@
 package application
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"citadel_intranet/src/db"
+	"citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/logging"
 	"citadel_intranet/src/server"
 
 	"github.com/kataras/muxie"
-	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultAlbumPageSize = 25
+	MaxAlbumPageSize     = 100
 )
 
 type App struct {
-	server server.Server
-	db     db.DatabaseClient
+	server  server.Server
+	db      db.DatabaseClient
+	oidc    OidcLogin
+	ratings RatingRange
 }
 
-type AppErr struct {
-	Error string `json:"error"`
+/*
+Changes how an application behaves from its defaults.
+*/
+type Option func(*App)
+
+/*
+Only accept album and track ratings from min to max, rather than
+DefaultRatingRange.
+*/
+func WithRatingRange(min uint, max uint) Option {
+	return func(app *App) {
+		app.ratings = RatingRange{Min: min, Max: max}
+	}
 }
 
-func NewApp(db db.DatabaseClient, server server.Server) Application {
-	return App{
-		server: server,
-		db:     db,
+func NewApp(db db.DatabaseClient, server server.Server, options ...Option) Application {
+	app := App{
+		server:  server,
+		db:      db,
+		ratings: DefaultRatingRange,
 	}
+
+	for _, option := range options {
+		option(&app)
+	}
+	return app
+}
+
+/*
+Create an application that also lets people log in through single sign-on.
+*/
+func NewAppWithOidc(db db.DatabaseClient, server server.Server, oidc OidcLogin, options ...Option) Application {
+	app := App{
+		server:  server,
+		db:      db,
+		oidc:    oidc,
+		ratings: DefaultRatingRange,
+	}
+
+	for _, option := range options {
+		option(&app)
+	}
+	return app
+}
+
+/*
+Give up on the database queries made for a request once they've taken longer
+than the client's query timeout, rather than holding on to a connection for a
+client that has long since stopped waiting.
+*/
+func (this App) queryTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(out http.ResponseWriter, req *http.Request) {
+		if this.db.QueryTimeout <= 0 {
+			next.ServeHTTP(out, req)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), this.db.QueryTimeout)
+		defer cancel()
+
+		next.ServeHTTP(out, req.WithContext(ctx))
+	})
 }
 
 func (this App) Run() {
+	this.server.Mux.Use(this.queryTimeout)
+
+	this.server.Mux.Handle("/api/v1/auth/login", muxie.Methods().
+		HandleFunc(http.MethodPost, this.login))
+
+	this.server.Mux.Handle("/api/v1/auth/logout", muxie.Methods().
+		HandleFunc(http.MethodPost, this.logout))
+
+	this.server.Mux.Handle("/api/v1/auth/methods", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveLoginMethods))
+
+	this.server.Mux.Handle("/api/v1/openapi.json", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveOpenApi))
+
+	if this.oidc.Client != nil {
+		this.server.Mux.Handle("/api/v1/auth/oidc/login", muxie.Methods().
+			HandleFunc(http.MethodGet, this.startOidcLogin))
+
+		this.server.Mux.Handle("/api/v1/auth/oidc/callback", muxie.Methods().
+			HandleFunc(http.MethodGet, this.finishOidcLogin))
+	}
+
+	// Every route registered from here on requires a logged in user. Static
+	// files were registered by the server before this, and stay public.
+	this.server.Mux.Use(this.requireUser)
+
+	this.server.Mux.Handle("/api/v1/auth/session", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveSessionUser))
+
+	this.server.Mux.Handle("/api/v1/auth/password", muxie.Methods().
+		HandleFunc(http.MethodPut, loggedInOnly(this.changePassword)))
+
+	this.server.Mux.Handle("/api/v1/auth/tokens", muxie.Methods().
+		HandleFunc(http.MethodGet, loggedInOnly(this.retrieveApiTokens)).
+		HandleFunc(http.MethodPost, loggedInOnly(this.createApiToken)))
+
+	this.server.Mux.Handle("/api/v1/auth/tokens/:id", muxie.Methods().
+		HandleFunc(http.MethodDelete, loggedInOnly(this.removeApiToken)))
+
+	this.server.Mux.Handle("/api/v1/user", muxie.Methods().
+		HandleFunc(http.MethodGet, allow(PermissionManageUsers, this.retrieveUsers)).
+		HandleFunc(http.MethodPost, allow(PermissionManageUsers, this.createUser)))
+
+	this.server.Mux.Handle("/api/v1/user/:id", muxie.Methods().
+		HandleFunc(http.MethodGet, allow(PermissionManageUsers, this.retrieveUser)).
+		HandleFunc(http.MethodDelete, allow(PermissionManageUsers, this.removeUser)))
+
+	this.server.Mux.Handle("/api/v1/user/:id/role", muxie.Methods().
+		HandleFunc(http.MethodPut, allow(PermissionManageUsers, this.changeUserRole)))
+
 	this.server.Mux.Handle("/api/v1/album", muxie.Methods().
 		HandleFunc(http.MethodGet, this.retrieveAllAlbums).
-		HandleFunc(http.MethodPost, this.createAlbum))
+		HandleFunc(http.MethodPost, allow(PermissionCreateAlbum, this.createAlbum)))
 
 	this.server.Mux.Handle("/api/v1/album/:id", muxie.Methods().
 		HandleFunc(http.MethodGet, this.retrieveAlbum).
-		HandleFunc(http.MethodPut, this.updateAlbum).
-		HandleFunc(http.MethodDelete, this.removeAlbum))
+		HandleFunc(http.MethodPut, allow(PermissionEditAlbum, this.updateAlbum)).
+		HandleFunc(http.MethodPatch, allow(PermissionEditAlbum, this.patchAlbum)).
+		HandleFunc(http.MethodDelete, allow(PermissionRemoveAlbum, this.removeAlbum)))
+
+	this.server.Mux.Handle("/api/v1/album/:id/tracks", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveAlbumTracks).
+		HandleFunc(http.MethodPut, allow(PermissionEditTrack, this.reorderAlbumTracks)))
+
+	this.server.Mux.Handle("/api/v1/album/:id/state", muxie.Methods().
+		HandleFunc(http.MethodPost, allow(PermissionChangeAlbumState, this.changeAlbumState)))
+
+	this.server.Mux.Handle("/api/v1/album/:id/history", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveAlbumHistory))
+
+	this.server.Mux.Handle("/api/v1/album/:id/proposals", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveProposals(model.TargetAlbum)).
+		HandleFunc(http.MethodPost, allow(PermissionPropose, this.createProposal(model.TargetAlbum))))
+
+	this.server.Mux.Handle("/api/v1/album/:id/proposals/:proposal/votes", muxie.Methods().
+		HandleFunc(http.MethodPost, allow(PermissionVote, this.voteOnProposal(model.TargetAlbum))))
+
+	this.server.Mux.Handle("/api/v1/album/:id/proposals/:proposal/accept", muxie.Methods().
+		HandleFunc(http.MethodPost, allow(PermissionAcceptProposal, this.acceptProposal(model.TargetAlbum))))
+
+	this.server.Mux.Handle("/api/v1/album/:id/comments", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveComments(model.TargetAlbum)).
+		HandleFunc(http.MethodPost, allow(PermissionComment, this.createComment(model.TargetAlbum))))
 
 	this.server.Mux.Handle("/api/v1/artist", muxie.Methods().
 		HandleFunc(http.MethodGet, this.retrieveArtists).
-		HandleFunc(http.MethodPost, this.createArtist))
+		HandleFunc(http.MethodPost, allow(PermissionCreateArtist, this.createArtist)))
+
+	this.server.Mux.Handle("/api/v1/artist/:id", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveArtist).
+		HandleFunc(http.MethodPut, allow(PermissionEditArtist, this.updateArtist)).
+		HandleFunc(http.MethodPatch, allow(PermissionEditArtist, this.patchArtist)).
+		HandleFunc(http.MethodDelete, allow(PermissionRemoveArtist, this.removeArtist)))
+
+	this.server.Mux.Handle("/api/v1/artist/:id/albums", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveArtistAlbums))
 
 	this.server.Mux.Handle("/api/v1/track", muxie.Methods().
-		HandleFunc(http.MethodPost, this.createTrack))
+		HandleFunc(http.MethodPost, allow(PermissionCreateTrack, this.createTrack)))
 
 	this.server.Mux.Handle("/api/v1/track/:id", muxie.Methods().
-		HandleFunc(http.MethodPut, this.updateTrack))
+		HandleFunc(http.MethodGet, this.retrieveTrack).
+		HandleFunc(http.MethodPut, allow(PermissionEditTrack, this.updateTrack)).
+		HandleFunc(http.MethodPatch, allow(PermissionEditTrack, this.patchTrack)).
+		HandleFunc(http.MethodDelete, allow(PermissionRemoveTrack, this.removeTrack)))
+
+	this.server.Mux.Handle("/api/v1/track/:id/proposals", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveProposals(model.TargetTrack)).
+		HandleFunc(http.MethodPost, allow(PermissionPropose, this.createProposal(model.TargetTrack))))
+
+	this.server.Mux.Handle("/api/v1/track/:id/proposals/:proposal/votes", muxie.Methods().
+		HandleFunc(http.MethodPost, allow(PermissionVote, this.voteOnProposal(model.TargetTrack))))
+
+	this.server.Mux.Handle("/api/v1/track/:id/proposals/:proposal/accept", muxie.Methods().
+		HandleFunc(http.MethodPost, allow(PermissionAcceptProposal, this.acceptProposal(model.TargetTrack))))
+
+	this.server.Mux.Handle("/api/v1/track/:id/comments", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveComments(model.TargetTrack)).
+		HandleFunc(http.MethodPost, allow(PermissionComment, this.createComment(model.TargetTrack))))
+
+	this.server.Mux.Handle("/api/v1/comment/:id", muxie.Methods().
+		HandleFunc(http.MethodGet, this.retrieveComment).
+		HandleFunc(http.MethodPut, allow(PermissionComment, this.updateComment)).
+		HandleFunc(http.MethodDelete, allow(PermissionComment, this.removeComment)))
+
+	// Anything else under the API is a problem too, rather than falling
+	// through to the static files.
+	this.server.Mux.HandleFunc("/api/v1/*path", this.routeNotFound)
+}
+
+func (this App) routeNotFound(out http.ResponseWriter, req *http.Request) {
+	writeProblem(out, req, ProblemNotFound, fmt.Sprintf("No such API route: %s.", req.URL.Path))
 }
 
 func (this App) Close() {
@@ -57,10 +236,6 @@ func (this App) Close() {
 }
 
 func writeBack(out http.ResponseWriter, value interface{}) error {
-	if v, ok := value.(error); ok {
-		value = AppErr{v.Error()}
-	}
-
 	buffer, err := json.Marshal(value)
 	if err != nil {
 		return err
@@ -81,63 +256,254 @@ func writeBack(out http.ResponseWriter, value interface{}) error {
 	return nil
 }
 
+func parseUintParam(params url.Values, name string, defaultValue uint) (uint, error) {
+	value := params.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	ret, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s provided. Must be a non-negative integer.", name)
+	}
+
+	return uint(ret), nil
+}
+
+/*
+Build an album query from the query string of a request. Supported parameters
+are:
+
+	artist     Only albums by the artist with this id
+	state      Only albums in this workflow state
+	published  Only albums that are (or aren't) published (true/false)
+	minRating  Only albums with at least this rating
+	title      Only albums with this substring in their title
+	sort       Field to sort by, prefixed with "-" for descending order, any of
+	           dao.AlbumSortKeys or dao.AlbumSortAliases
+	limit      Page size, defaults to DefaultAlbumPageSize
+	offset     Number of albums to skip
+*/
+func parseAlbumQuery(req *http.Request) (dao.AlbumQuery, error) {
+	var err error
+	params := req.URL.Query()
+	query := dao.AlbumQuery{
+		Title: params.Get("title"),
+	}
+
+	if value := params.Get("artist"); value != "" {
+		query.ArtistId, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return query, errors.New("Invalid artist provided. Must be an integer.")
+		}
+	}
+
+	if value := params.Get("state"); value != "" {
+		if query.State, err = parseAlbumState(value); err != nil {
+			return query, err
+		}
+	}
+
+	if value := params.Get("published"); value != "" {
+		published, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, errors.New("Invalid published provided. Must be true or false.")
+		}
+		query.Published = &published
+	}
+
+	if query.MinRating, err = parseUintParam(params, "minRating", 0); err != nil {
+		return query, err
+	}
+
+	if value := params.Get("sort"); value != "" {
+		if strings.HasPrefix(value, "-") {
+			query.Descending = true
+			value = value[1:]
+		}
+
+		for _, key := range dao.AlbumSortKeys {
+			if string(key) == value {
+				query.SortBy = key
+			}
+		}
+		if key, found := dao.AlbumSortAliases[value]; found {
+			query.SortBy = key
+		}
+
+		if query.SortBy == "" {
+			return query, fmt.Errorf("Invalid sort provided. Cannot sort albums by %q.", value)
+		}
+	}
+
+	if query.Limit, err = parseUintParam(params, "limit", DefaultAlbumPageSize); err != nil {
+		return query, err
+	}
+	if query.Limit == 0 || query.Limit > MaxAlbumPageSize {
+		return query, fmt.Errorf("Invalid limit provided. Must be between 1 and %d.", MaxAlbumPageSize)
+	}
+
+	if query.Offset, err = parseUintParam(params, "offset", 0); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
 func (this App) retrieveAllAlbums(out http.ResponseWriter, req *http.Request) {
-	albums := this.db.Album.LoadAll()
-	muxie.JSON.Dispatch(out, albums)
+	query, err := parseAlbumQuery(req)
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidParameter, err.Error())
+		return
+	}
+
+	this.writeAlbumPage(out, req, query)
+}
+
+func (this App) writeAlbumPage(out http.ResponseWriter, req *http.Request, query dao.AlbumQuery) {
+	albums, total, err := this.db.Album.Query(req.Context(), query)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	if err = this.fillCommentCounts(req.Context(), albums); err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	page := model.AlbumPage{
+		Albums: albums,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}
+
+	if next := query.Offset + uint(len(albums)); len(albums) > 0 && int64(next) < total {
+		page.Next = &next
+	}
+
+	muxie.JSON.Dispatch(out, page)
 }
 
 func (this App) upsertAlbum(out http.ResponseWriter, req *http.Request, albumId int64) {
-	var err error
 	album := model.Album{}
-	muxie.JSON.Bind(req, &album)
+	if !bindJSON(out, req, &album) {
+		return
+	}
 
 	album.Id = albumId
 
-	if album.Artist.Id == 0 {
-		if album.Artist.Name != "" {
-			logrus.Info("Saving off artist with name=", album.Artist.Name, " to ", this.db.Artist)
-			// We need to insert the artist, which is apparently new.
-			album.Artist.Id, err = this.db.Artist.Save(album.Artist)
-			if err != nil {
-				out.WriteHeader(http.StatusInternalServerError)
-				writeBack(out, err)
-				return
-			}
-		} else {
-			// Someone sent us an invalid request.
-			out.WriteHeader(http.StatusBadRequest)
-			writeBack(out, errors.New("Invalid album artist provided. Name cannot be empty when inserting an artist."))
-			return
+	// Only an If-Match header makes the save conditional, whatever version the
+	// body might carry.
+	version, err := parseIfMatch(req)
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidIfMatch, err.Error())
+		return
+	}
+	album.Version = version
+
+	// Tracks on existing albums are managed through the track endpoints, only
+	// new albums can bring their tracklist along, as new tracks.
+	withTracks := albumId == 0
+	if withTracks {
+		for index := range album.Tracks {
+			album.Tracks[index].Id = 0
+			fillTrackPosition(&album.Tracks[index], album.Tracks[:index])
 		}
 	}
 
-	album.Id, err = this.db.Album.Save(album)
+	check := this.validator()
+	check.album(album, withTracks)
+	if err = check.artistExists(req.Context(), this.db.Artist, "artist.id", album.Artist.Id); err == nil {
+		err = check.err()
+	}
 	if err != nil {
-		out.WriteHeader(http.StatusInternalServerError)
-		writeBack(out, err)
+		writeValidationError(out, req, err)
+		return
+	}
+
+	err = this.saveAlbum(req.Context(), &album, withTracks)
+	if errors.Is(err, dao.ErrConflict) {
+		this.writeStaleAlbum(out, req, albumId)
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
 		return
 	}
 
 	if albumId == 0 {
+		// Saving never touches the state, so new albums are always drafts.
+		album.State = model.AlbumDraft
+		album.Version = 1
+		setETag(out, album.Version)
 		out.WriteHeader(http.StatusCreated)
 		muxie.JSON.Dispatch(out, &album)
 	} else {
+		if version != 0 {
+			setETag(out, version+1)
+		}
 		out.WriteHeader(http.StatusOK)
 	}
 }
 
+/*
+Save an album, along with its artist when it has no id yet and its tracks when
+withTracks is set, filling in the ids they're given. The tracks are saved as
+new ones, at the positions they already have.
+
+The artist, the album and its tracks are saved all together or not at all, so
+a failure part way through doesn't leave an orphaned artist or a half empty
+album behind.
+*/
+func (this App) saveAlbum(ctx context.Context, album *model.Album, withTracks bool) error {
+	return this.db.InTransaction(ctx, func(work dao.UnitOfWork) error {
+		var err error
+		if album.Artist.Id == 0 {
+			logging.FromContext(ctx).Info("Saving off artist with name=", album.Artist.Name)
+			// We need to insert the artist, which is apparently new.
+			if album.Artist.Id, err = work.Artist.Save(ctx, album.Artist); err != nil {
+				return err
+			}
+		}
+
+		albumId, err := work.Album.Save(ctx, *album)
+		if err != nil {
+			return err
+		}
+		if album.Id == 0 {
+			album.Id = albumId
+		}
+
+		if !withTracks {
+			return nil
+		}
+
+		for index := range album.Tracks {
+			track := &album.Tracks[index]
+			track.Id = 0
+			track.AlbumId = album.Id
+
+			if track.Id, err = work.Track.Save(ctx, *track); err != nil {
+				return err
+			}
+			track.Version = 1
+		}
+		return nil
+	})
+}
+
 func (this App) createAlbum(out http.ResponseWriter, req *http.Request) {
 	// Ensure that we didn't get an ID sent to us, we are creating a new Album,
 	// _not_ updating.
 	this.upsertAlbum(out, req, 0)
 }
 
-func parseIdFromUrl(out http.ResponseWriter) int64 {
+func parseIdFromUrl(out http.ResponseWriter, req *http.Request) int64 {
 	albumIdStr := muxie.GetParam(out, "id")
 	albumId, err := strconv.ParseInt(albumIdStr, 10, 64)
 	if err != nil {
-		out.WriteHeader(http.StatusBadRequest)
-		writeBack(out, errors.New("Invalid ID provided. Must be an integer."))
+		writeProblem(out, req, ProblemInvalidId, "Invalid ID provided. Must be an integer.")
 		return 0
 	}
 
@@ -146,22 +512,28 @@ func parseIdFromUrl(out http.ResponseWriter) int64 {
 
 func (this App) retrieveAlbum(out http.ResponseWriter, req *http.Request) {
 	var albumId int64
-	if albumId = parseIdFromUrl(out); albumId == 0 {
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
+		return
+	}
+
+	album, err := this.db.Album.Load(req.Context(), albumId)
+	if err != nil {
+		writeLoadError(out, req, err, "Album not found.")
 		return
 	}
 
-	album := this.db.Album.Load(albumId)
-	if album == nil {
-		out.WriteHeader(http.StatusNotFound)
-		writeBack(out, errors.New("Album not found."))
+	albums := []model.Album{*album}
+	if err = this.fillCommentCounts(req.Context(), albums); err != nil {
+		writeDaoError(out, req, err)
 		return
 	}
-	muxie.JSON.Dispatch(out, album)
+	setETag(out, album.Version)
+	muxie.JSON.Dispatch(out, albums[0])
 }
 
 func (this App) updateAlbum(out http.ResponseWriter, req *http.Request) {
 	var albumId int64
-	if albumId = parseIdFromUrl(out); albumId == 0 {
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
 		return
 	}
 
@@ -170,67 +542,246 @@ func (this App) updateAlbum(out http.ResponseWriter, req *http.Request) {
 
 func (this App) removeAlbum(out http.ResponseWriter, req *http.Request) {
 	var albumId int64
-	if albumId = parseIdFromUrl(out); albumId == 0 {
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
 		return
 	}
 
-	_, err := this.db.Album.Delete(model.Album{Id: albumId})
+	rows, err := this.db.Album.Delete(req.Context(), model.Album{Id: albumId})
 	if err != nil {
-		out.WriteHeader(http.StatusInternalServerError)
-		writeBack(out, err)
+		writeDaoError(out, req, err)
+	} else if rows == 0 {
+		writeProblem(out, req, ProblemNotFound, "Album not found.")
 	}
 }
 
 func (this App) retrieveArtists(out http.ResponseWriter, req *http.Request) {
-	artists := this.db.Artist.LoadAll()
+	artists, err := this.db.Artist.LoadAll(req.Context())
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
 	muxie.JSON.Dispatch(out, artists)
 }
 
-func (this App) createArtist(out http.ResponseWriter, req *http.Request) {
-	var err error
+func (this App) upsertArtist(out http.ResponseWriter, req *http.Request, artistId int64) {
 	artist := model.Artist{}
-	muxie.JSON.Bind(req, &artist)
+	if !bindJSON(out, req, &artist) {
+		return
+	}
 
-	artist.Id = 0
+	artist.Id = artistId
+
+	if artistId != 0 {
+		if _, err := this.db.Artist.Load(req.Context(), artistId); err != nil {
+			writeLoadError(out, req, err, "Artist not found.")
+			return
+		}
+	}
 
-	if artist.Name == "" {
-		// Someone sent us an invalid request.
-		out.WriteHeader(http.StatusBadRequest)
-		writeBack(out, errors.New("Artists must be named."))
+	if !this.saveArtist(out, req, &artist) {
 		return
 	}
 
-	logrus.Info("Saving off artist with name=", artist.Name, " to ", this.db.Artist)
-	artist.Id, err = this.db.Artist.Save(artist)
+	if artistId == 0 {
+		out.WriteHeader(http.StatusCreated)
+		muxie.JSON.Dispatch(out, &artist)
+	} else {
+		out.WriteHeader(http.StatusOK)
+	}
+}
+
+/*
+Check and save an artist, filling in its id, writing back an error if it
+can't be saved.
+
+Returns whether the artist was saved
+*/
+func (this App) saveArtist(out http.ResponseWriter, req *http.Request, artist *model.Artist) bool {
+	check := this.validator()
+	check.artist(*artist)
+	if err := check.err(); err != nil {
+		writeValidationError(out, req, err)
+		return false
+	}
+
+	// Names are unique, and an upsert on a name that is already taken would
+	// quietly update the existing artist rather than fail.
+	existing, err := this.db.Artist.LoadByName(req.Context(), artist.Name)
+	if err != nil && !errors.Is(err, dao.ErrNotFound) {
+		writeDaoError(out, req, err)
+		return false
+	}
+	if existing != nil && existing.Id != artist.Id {
+		writeProblem(out, req, ProblemAlreadyExists, "An artist with that name already exists.")
+		return false
+	}
+
+	logging.FromContext(req.Context()).Info("Saving off artist with name=", artist.Name)
+	artistId, err := this.db.Artist.Save(req.Context(), *artist)
+	if errors.Is(err, dao.ErrDuplicate) {
+		writeProblem(out, req, ProblemAlreadyExists, "An artist with that name already exists.")
+		return false
+	} else if err != nil {
+		writeDaoError(out, req, err)
+		return false
+	}
+
+	if artist.Id == 0 {
+		artist.Id = artistId
+	}
+	return true
+}
+
+func (this App) createArtist(out http.ResponseWriter, req *http.Request) {
+	this.upsertArtist(out, req, 0)
+}
+
+func (this App) retrieveArtist(out http.ResponseWriter, req *http.Request) {
+	var artistId int64
+	if artistId = parseIdFromUrl(out, req); artistId == 0 {
+		return
+	}
+
+	artist, err := this.db.Artist.Load(req.Context(), artistId)
 	if err != nil {
-		out.WriteHeader(http.StatusInternalServerError)
-		writeBack(out, err)
+		writeLoadError(out, req, err, "Artist not found.")
+		return
+	}
+	muxie.JSON.Dispatch(out, artist)
+}
+
+func (this App) updateArtist(out http.ResponseWriter, req *http.Request) {
+	var artistId int64
+	if artistId = parseIdFromUrl(out, req); artistId == 0 {
 		return
 	}
 
-	out.WriteHeader(http.StatusCreated)
-	muxie.JSON.Dispatch(out, &artist)
+	this.upsertArtist(out, req, artistId)
+}
+
+/*
+Remove an artist. Deleting an artist cascades to all of their albums (and
+their tracks), so this is refused while the artist still has albums unless
+the caller explicitly asks for it with `?cascade=true`.
+*/
+func (this App) removeArtist(out http.ResponseWriter, req *http.Request) {
+	var artistId int64
+	if artistId = parseIdFromUrl(out, req); artistId == 0 {
+		return
+	}
+
+	cascade := false
+	if value := req.URL.Query().Get("cascade"); value != "" {
+		var err error
+		if cascade, err = strconv.ParseBool(value); err != nil {
+			writeProblem(out, req, ProblemInvalidParameter, "Invalid cascade provided. Must be true or false.")
+			return
+		}
+	}
+
+	if _, err := this.db.Artist.Load(req.Context(), artistId); err != nil {
+		writeLoadError(out, req, err, "Artist not found.")
+		return
+	}
+
+	if !cascade {
+		_, albumCount, err := this.db.Album.Query(req.Context(), dao.AlbumQuery{ArtistId: artistId, Limit: 1})
+		if err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		if albumCount > 0 {
+			writeProblem(out, req, ProblemArtistHasAlbums, fmt.Sprintf("Artist still has %d album(s). Use cascade=true to delete them as well.", albumCount))
+			return
+		}
+	}
+
+	_, err := this.db.Artist.Delete(req.Context(), model.Artist{Id: artistId})
+	if err != nil {
+		writeDaoError(out, req, err)
+	}
+}
+
+func (this App) retrieveArtistAlbums(out http.ResponseWriter, req *http.Request) {
+	var artistId int64
+	if artistId = parseIdFromUrl(out, req); artistId == 0 {
+		return
+	}
+
+	query, err := parseAlbumQuery(req)
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidParameter, err.Error())
+		return
+	}
+	query.ArtistId = artistId
+
+	if _, err = this.db.Artist.Load(req.Context(), artistId); err != nil {
+		writeLoadError(out, req, err, "Artist not found.")
+		return
+	}
+
+	this.writeAlbumPage(out, req, query)
 }
 
 func (this App) upsertTrack(out http.ResponseWriter, req *http.Request, trackId int64) {
-	var err error
 	track := model.Track{}
-	muxie.JSON.Bind(req, &track)
+	if !bindJSON(out, req, &track) {
+		return
+	}
 
 	track.Id = trackId
 
-	logrus.Info("Saving off track with name=", track.Title, " to ", this.db.Track)
-	track.Id, err = this.db.Track.Save(track)
+	// Only an If-Match header makes the save conditional, whatever version the
+	// body might carry.
+	version, err := parseIfMatch(req)
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidIfMatch, err.Error())
+		return
+	}
+	track.Version = version
+
+	check := this.validator()
+	album, err := check.albumExists(req.Context(), this.db.Album, "album", track.AlbumId)
+
+	var tracks []model.Track
+	if album != nil {
+		tracks = album.Tracks
+	}
+	fillTrackPosition(&track, tracks)
+
+	check.track("", track)
+	check.freePosition("", track, tracks)
+	if err == nil {
+		err = check.err()
+	}
 	if err != nil {
-		out.WriteHeader(http.StatusInternalServerError)
-		writeBack(out, err)
+		writeValidationError(out, req, err)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Saving off track with name=", track.Title)
+	track.Id, err = this.db.Track.Save(req.Context(), track)
+	if errors.Is(err, dao.ErrConflict) {
+		this.writeStaleTrack(out, req, trackId)
+		return
+	} else if errors.Is(err, dao.ErrDuplicate) {
+		this.writePositionTaken(out, req, track)
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
 		return
 	}
 
 	if trackId == 0 {
+		track.Version = 1
+		setETag(out, track.Version)
 		out.WriteHeader(http.StatusCreated)
 		muxie.JSON.Dispatch(out, &track)
 	} else {
+		if version != 0 {
+			setETag(out, version+1)
+		}
 		out.WriteHeader(http.StatusOK)
 	}
 }
@@ -241,9 +792,201 @@ func (this App) createTrack(out http.ResponseWriter, req *http.Request) {
 
 func (this App) updateTrack(out http.ResponseWriter, req *http.Request) {
 	var trackId int64
-	if trackId = parseIdFromUrl(out); trackId == 0 {
+	if trackId = parseIdFromUrl(out, req); trackId == 0 {
 		return
 	}
 
 	this.upsertTrack(out, req, trackId)
 }
+
+func (this App) retrieveTrack(out http.ResponseWriter, req *http.Request) {
+	var trackId int64
+	if trackId = parseIdFromUrl(out, req); trackId == 0 {
+		return
+	}
+
+	track, err := this.db.Track.Load(req.Context(), trackId)
+	if err != nil {
+		writeLoadError(out, req, err, "Track not found.")
+		return
+	}
+	setETag(out, track.Version)
+	muxie.JSON.Dispatch(out, track)
+}
+
+func (this App) removeTrack(out http.ResponseWriter, req *http.Request) {
+	var trackId int64
+	if trackId = parseIdFromUrl(out, req); trackId == 0 {
+		return
+	}
+
+	rows, err := this.db.Track.Delete(req.Context(), model.Track{Id: trackId})
+	if err != nil {
+		writeDaoError(out, req, err)
+	} else if rows == 0 {
+		writeProblem(out, req, ProblemNotFound, "Track not found.")
+	}
+}
+
+func (this App) retrieveAlbumTracks(out http.ResponseWriter, req *http.Request) {
+	var albumId int64
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
+		return
+	}
+
+	if _, err := this.db.Album.Load(req.Context(), albumId); err != nil {
+		writeLoadError(out, req, err, "Album not found.")
+		return
+	}
+
+	tracks, err := this.db.Track.LoadForAlbum(req.Context(), albumId)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+	muxie.JSON.Dispatch(out, tracks)
+}
+
+/*
+Let the client know a track couldn't be saved because another track got to
+its position first, after it was checked.
+*/
+func (this App) writePositionTaken(out http.ResponseWriter, req *http.Request, track model.Track) {
+	check := this.validator()
+	check.positionTaken("", track)
+	writeValidationError(out, req, check.err())
+}
+
+func nextTrackNumber(tracks []model.Track, discNumber uint) uint {
+	var last uint
+	for _, track := range tracks {
+		if track.DiscNumber == discNumber && track.TrackNumber > last {
+			last = track.TrackNumber
+		}
+	}
+
+	return last + 1
+}
+
+/*
+Fill in the disc and track number of a track being saved to an album with the
+given tracks, when they're left out. A track already on the album keeps its
+place there, anything else goes on the end of disc 1 (or the disc it names).
+*/
+func fillTrackPosition(track *model.Track, tracks []model.Track) {
+	var stored *model.Track
+	for index := range tracks {
+		if track.Id != 0 && tracks[index].Id == track.Id {
+			stored = &tracks[index]
+		}
+	}
+
+	if track.DiscNumber == 0 {
+		track.DiscNumber = 1
+		if stored != nil {
+			track.DiscNumber = stored.DiscNumber
+		}
+	}
+	if track.TrackNumber == 0 {
+		if stored != nil && stored.DiscNumber == track.DiscNumber {
+			track.TrackNumber = stored.TrackNumber
+		} else {
+			track.TrackNumber = nextTrackNumber(tracks, track.DiscNumber)
+		}
+	}
+}
+
+/*
+Ensure that a new track order places every track on the album exactly once,
+and that discs and the tracks on each disc are numbered 1 through n without
+any gaps or repeats.
+*/
+func validateTrackOrder(tracks []model.Track, order []model.TrackPosition) error {
+	onAlbum := make(map[int64]bool, len(tracks))
+	for _, track := range tracks {
+		onAlbum[track.Id] = true
+	}
+
+	placed := make(map[int64]bool, len(order))
+	discs := make(map[uint]map[uint]bool)
+	for _, position := range order {
+		if !onAlbum[position.Id] {
+			return fmt.Errorf("Track %d does not belong to this album.", position.Id)
+		}
+		if placed[position.Id] {
+			return fmt.Errorf("Track %d appears more than once.", position.Id)
+		}
+		placed[position.Id] = true
+
+		if position.DiscNumber == 0 || position.TrackNumber == 0 {
+			return fmt.Errorf("Track %d must have a disc and track number of at least 1.", position.Id)
+		}
+
+		if discs[position.DiscNumber] == nil {
+			discs[position.DiscNumber] = make(map[uint]bool)
+		}
+		if discs[position.DiscNumber][position.TrackNumber] {
+			return fmt.Errorf("Disc %d has more than one track at position %d.", position.DiscNumber, position.TrackNumber)
+		}
+		discs[position.DiscNumber][position.TrackNumber] = true
+	}
+
+	for _, track := range tracks {
+		if !placed[track.Id] {
+			return fmt.Errorf("Track %d is missing from the new order.", track.Id)
+		}
+	}
+
+	for disc := uint(1); disc <= uint(len(discs)); disc++ {
+		numbers, found := discs[disc]
+		if !found {
+			return fmt.Errorf("Disc %d is missing.", disc)
+		}
+
+		for number := uint(1); number <= uint(len(numbers)); number++ {
+			if !numbers[number] {
+				return fmt.Errorf("Disc %d is missing position %d.", disc, number)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (this App) reorderAlbumTracks(out http.ResponseWriter, req *http.Request) {
+	var albumId int64
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
+		return
+	}
+
+	order := []model.TrackPosition{}
+	if !bindJSON(out, req, &order) {
+		return
+	}
+
+	album, err := this.db.Album.Load(req.Context(), albumId)
+	if err != nil {
+		writeLoadError(out, req, err, "Album not found.")
+		return
+	}
+
+	if err := validateTrackOrder(album.Tracks, order); err != nil {
+		writeProblem(out, req, ProblemInvalidTrackOrder, err.Error())
+		return
+	}
+
+	if err := this.db.Track.Reorder(req.Context(), albumId, order); errors.Is(err, dao.ErrConflict) {
+		writeProblem(out, req, ProblemConcurrentChange, "Album tracks were changed by someone else, please try again.")
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	tracks, err := this.db.Track.LoadForAlbum(req.Context(), albumId)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+	muxie.JSON.Dispatch(out, tracks)
+}
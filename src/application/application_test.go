@@ -2,16 +2,25 @@ package application_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"citadel_intranet/src/application"
+	"citadel_intranet/src/auth"
+	authmock "citadel_intranet/src/auth/mock"
 	"citadel_intranet/src/config"
 	"citadel_intranet/src/db"
+	"citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/dao/mock"
 	"citadel_intranet/src/db/model"
 	"citadel_intranet/src/server"
@@ -19,12 +28,13 @@ import (
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/golang/mock/gomock"
 	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
 const (
-	ExpectedJsonForGetAlbums = "[{\"id\":1,\"title\":\"Waffle Irons\",\"artist\":{\"id\":42,\"name\":\"James\"},\"tracks\":[{\"id\":1,\"title\":\"Track 1.1\",\"album\":1,\"rating\":5},{\"id\":2,\"title\":\"Track 1.2\",\"album\":1,\"rating\":5},{\"id\":3,\"title\":\"Track 1.3\",\"album\":1,\"rating\":5}],\"published\":false,\"rating\":5},{\"id\":2,\"title\":\"Something New\",\"artist\":{\"id\":42,\"name\":\"James\"},\"tracks\":[{\"id\":4,\"title\":\"Track 2.1\",\"album\":2,\"rating\":5},{\"id\":5,\"title\":\"Track 2.2\",\"album\":2,\"rating\":5},{\"id\":6,\"title\":\"Track 2.3\",\"album\":2,\"rating\":5}],\"published\":false,\"rating\":3},{\"id\":3,\"title\":\"Something New (Deluxe)\",\"artist\":{\"id\":42,\"name\":\"James\"},\"tracks\":[{\"id\":7,\"title\":\"Track 3.1\",\"album\":3,\"rating\":5},{\"id\":8,\"title\":\"Track 3.2\",\"album\":3,\"rating\":5},{\"id\":9,\"title\":\"Track 3.3\",\"album\":3,\"rating\":5}],\"published\":true,\"rating\":5}]"
+	ExpectedJsonForGetAlbums = "{\"albums\":[{\"id\":1,\"title\":\"Waffle Irons\",\"artist\":{\"id\":42,\"name\":\"James\"},\"tracks\":[{\"id\":1,\"title\":\"Track 1.1\",\"album\":1,\"rating\":5,\"disc\":1,\"number\":1,\"version\":1},{\"id\":2,\"title\":\"Track 1.2\",\"album\":1,\"rating\":5,\"disc\":1,\"number\":2,\"version\":1},{\"id\":3,\"title\":\"Track 1.3\",\"album\":1,\"rating\":5,\"disc\":1,\"number\":3,\"version\":1}],\"state\":\"draft\",\"rating\":5,\"version\":1,\"commentCount\":0,\"published\":false},{\"id\":2,\"title\":\"Something New\",\"artist\":{\"id\":42,\"name\":\"James\"},\"tracks\":[{\"id\":4,\"title\":\"Track 2.1\",\"album\":2,\"rating\":5,\"disc\":1,\"number\":1,\"version\":1},{\"id\":5,\"title\":\"Track 2.2\",\"album\":2,\"rating\":5,\"disc\":1,\"number\":2,\"version\":1},{\"id\":6,\"title\":\"Track 2.3\",\"album\":2,\"rating\":5,\"disc\":1,\"number\":3,\"version\":1}],\"state\":\"in_review\",\"rating\":3,\"version\":4,\"commentCount\":4,\"published\":false},{\"id\":3,\"title\":\"Something New (Deluxe)\",\"artist\":{\"id\":42,\"name\":\"James\"},\"tracks\":[{\"id\":7,\"title\":\"Track 3.1\",\"album\":3,\"rating\":5,\"disc\":1,\"number\":1,\"version\":1},{\"id\":8,\"title\":\"Track 3.2\",\"album\":3,\"rating\":5,\"disc\":1,\"number\":2,\"version\":1},{\"id\":9,\"title\":\"Track 3.3\",\"album\":3,\"rating\":5,\"disc\":1,\"number\":3,\"version\":1}],\"state\":\"published\",\"rating\":5,\"version\":2,\"commentCount\":0,\"published\":true}],\"total\":3,\"limit\":25,\"offset\":0,\"next\":null}"
 )
 
 func TestGetAlbums(t *testing.T) {
@@ -42,80 +52,93 @@ func TestGetAlbums(t *testing.T) {
 	logrus.Info("Setting web path to: ", cfg.ServerFilePath)
 
 	server := server.NewServer(cfg)
+	useTestSession()
 
 	mockDb, mock, err := sqlmock.New()
 	assert.Nil(err)
 
-	mockAlbums := sqlmock.NewRows([]string{"id", "title", "artist", "published", "rating"}).
-		AddRow(1, "Waffle Irons", 42, false, 5).
-		AddRow(2, "Something New", 42, false, 3).
-		AddRow(3, "Something New (Deluxe)", 42, true, 5)
+	now := time.Now()
 	mock.ExpectQuery(`
         SELECT
             \*
-        FROM album
-    `).
-		WillReturnRows(mockAlbums)
-	mock.ExpectQuery(`
-        SELECT
-            \*
-        FROM artist
-        WHERE id = ?
+        FROM session
+        WHERE id = \?
     `).
-		WithArgs(42).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(42, "James"))
-	mockTracks1 := sqlmock.NewRows([]string{"id", "title", "album", "rating"}).
-		AddRow(1, "Track 1.1", 1, 5).
-		AddRow(2, "Track 1.2", 1, 5).
-		AddRow(3, "Track 1.3", 1, 5)
+		WithArgs(auth.SessionId(testSessionToken)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user", "created_at", "expires_at"}).
+			AddRow(auth.SessionId(testSessionToken), testUser.Id, now, now.Add(time.Hour)))
 	mock.ExpectQuery(`
         SELECT
             \*
-        FROM track
-        WHERE album = ?
+        FROM user
+        WHERE id = \?
     `).
-		WithArgs(1).
-		WillReturnRows(mockTracks1)
+		WithArgs(testUser.Id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "oidc_subject", "created_at"}).
+			AddRow(testUser.Id, testUser.Username, "", testUser.Role, nil, now))
 	mock.ExpectQuery(`
         SELECT
-            \*
-        FROM artist
-        WHERE id = ?
+            COUNT\(\*\)
+        FROM album
     `).
-		WithArgs(42).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(42, "James"))
-	mockTracks2 := sqlmock.NewRows([]string{"id", "title", "album", "rating"}).
-		AddRow(4, "Track 2.1", 2, 5).
-		AddRow(5, "Track 2.2", 2, 5).
-		AddRow(6, "Track 2.3", 2, 5)
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mockAlbums := sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+		AddRow(1, "Waffle Irons", 42, "draft", 5, 1).
+		AddRow(2, "Something New", 42, "in_review", 3, 4).
+		AddRow(3, "Something New (Deluxe)", 42, "published", 5, 2)
 	mock.ExpectQuery(`
         SELECT
             \*
-        FROM track
-        WHERE album = ?
+        FROM album
+        ORDER BY
+            id ASC
+        LIMIT \?
+        OFFSET \?
     `).
-		WithArgs(2).
-		WillReturnRows(mockTracks2)
+		WithArgs(application.DefaultAlbumPageSize, 0).
+		WillReturnRows(mockAlbums)
 	mock.ExpectQuery(`
         SELECT
             \*
         FROM artist
-        WHERE id = ?
+        WHERE id IN \(\?\)
     `).
 		WithArgs(42).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(42, "James"))
-	mockTracks3 := sqlmock.NewRows([]string{"id", "title", "album", "rating"}).
-		AddRow(7, "Track 3.1", 3, 5).
-		AddRow(8, "Track 3.2", 3, 5).
-		AddRow(9, "Track 3.3", 3, 5)
+	mockTracks := sqlmock.NewRows([]string{"id", "title", "album", "rating", "disc_number", "track_number", "version"}).
+		AddRow(1, "Track 1.1", 1, 5, 1, 1, 1).
+		AddRow(2, "Track 1.2", 1, 5, 1, 2, 1).
+		AddRow(3, "Track 1.3", 1, 5, 1, 3, 1).
+		AddRow(4, "Track 2.1", 2, 5, 1, 1, 1).
+		AddRow(5, "Track 2.2", 2, 5, 1, 2, 1).
+		AddRow(6, "Track 2.3", 2, 5, 1, 3, 1).
+		AddRow(7, "Track 3.1", 3, 5, 1, 1, 1).
+		AddRow(8, "Track 3.2", 3, 5, 1, 2, 1).
+		AddRow(9, "Track 3.3", 3, 5, 1, 3, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
         FROM track
-        WHERE album = ?
+        WHERE album IN \(\?, \?, \?\)
+        ORDER BY
+            album ASC,
+            disc_number ASC,
+            track_number ASC,
+            id ASC
+    `).
+		WithArgs(1, 2, 3).
+		WillReturnRows(mockTracks)
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            COUNT\(\*\)
+        FROM comment
+        WHERE album IN \(\?, \?, \?\)
+            AND deleted_at IS NULL
+        GROUP BY album
     `).
-		WithArgs(3).
-		WillReturnRows(mockTracks3)
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"album", "count"}).AddRow(2, 4))
 
 	dbClient := db.NewDatabaseClientFromConnection(mockDb)
 
@@ -134,6 +157,110 @@ func TestGetAlbums(t *testing.T) {
 	assert.Equal(ExpectedJsonForGetAlbums, string(body))
 }
 
+const testSessionToken = "test-session-token"
+
+var testUser = model.User{
+	Id:       1,
+	Username: "James",
+	Role:     model.RoleAdmin,
+}
+
+/*
+Have every request made through the default client carry the session cookie
+that withTestSession recognises.
+*/
+func useTestSession() {
+	jar, _ := cookiejar.New(nil)
+	jar.SetCookies(&url.URL{Scheme: "http", Host: "localhost:8080"}, []*http.Cookie{
+		{Name: auth.SessionCookieName, Value: testSessionToken, Path: "/"},
+	})
+	http.DefaultClient.Jar = jar
+}
+
+/*
+Fill in the session and user DAOs of a client, so that requests made after
+useTestSession are logged in as testUser. DAOs the client already has are left
+alone, and need to expect loading the session or user themselves.
+*/
+func withTestSession(ctrl *gomock.Controller, client db.DatabaseClient) db.DatabaseClient {
+	return withTestSessionAs(ctrl, client, testUser)
+}
+
+/*
+Like withTestSession, but logged in as someone else.
+*/
+func withTestSessionAs(ctrl *gomock.Controller, client db.DatabaseClient, user model.User) db.DatabaseClient {
+	if client.Session == nil {
+		mockSessionDao := mock.NewMockSessionDao(ctrl)
+		mockSessionDao.EXPECT().
+			Load(gomock.Eq(auth.SessionId(testSessionToken))).
+			Return(&model.Session{
+				Id:        auth.SessionId(testSessionToken),
+				UserId:    user.Id,
+				ExpiresAt: time.Now().Add(time.Hour),
+			}).
+			AnyTimes()
+		mockSessionDao.EXPECT().Close().AnyTimes()
+		client.Session = mockSessionDao
+	}
+
+	if client.User == nil {
+		mockUserDao := mock.NewMockUserDao(ctrl)
+		mockUserDao.EXPECT().Load(gomock.Eq(user.Id)).Return(&user).AnyTimes()
+		mockUserDao.EXPECT().Close().AnyTimes()
+		client.User = mockUserDao
+	}
+
+	return withTransactions(ctrl, client)
+}
+
+/*
+Hand the client's own artist, album and track DAOs to any unit of work, unless
+the test has set up a transactor of its own.
+*/
+func withTransactions(ctrl *gomock.Controller, client db.DatabaseClient) db.DatabaseClient {
+	if client.Transactor == nil {
+		mockTransactor := mock.NewMockTransactor(ctrl)
+		mockTransactor.EXPECT().
+			InTransaction(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, work func(dao.UnitOfWork) error) error {
+				return work(dao.UnitOfWork{
+					Artist: client.Artist,
+					Album:  client.Album,
+					Track:  client.Track,
+				})
+			}).
+			AnyTimes()
+		client.Transactor = mockTransactor
+	}
+
+	return client
+}
+
+/*
+Build a PATCH request carrying the given merge patch.
+*/
+func newPatchRequest(url string, patch string) *http.Request {
+	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBufferString(patch))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	return req
+}
+
+/*
+The detail of a problem written back by the app, or the whole body if it isn't
+a problem.
+*/
+func problemDetail(body []byte) string {
+	problem := application.Problem{}
+	if err := json.Unmarshal(body, &problem); err != nil || problem.Code == "" {
+		return string(body)
+	}
+	return problem.Detail
+}
+
 type AppSuite struct {
 	suite.Suite
 	ctrl *gomock.Controller
@@ -145,6 +272,12 @@ func TestAppSuite(t *testing.T) {
 }
 
 func (suite *AppSuite) SetupTest() {
+	// Each test stands up (and shuts down) its own server on the same port, so
+	// make sure we aren't holding on to keep-alive connections from the last
+	// one.
+	http.DefaultClient.CloseIdleConnections()
+	useTestSession()
+
 	suite.ctrl = gomock.NewController(suite.T())
 	suite.cfg = config.Config{
 		ServerHost: "",
@@ -152,64 +285,121 @@ func (suite *AppSuite) SetupTest() {
 	}
 }
 
-func (suite *AppSuite) TestCreateAlbum() {
+func (suite *AppSuite) TestGetAlbumsFiltered() {
 	defer suite.ctrl.Finish()
 
-	album := model.Album{
-		Title: "Something Wicked This Way Comes",
-		Artist: model.Artist{
-			Id:   1,
-			Name: "James",
-		},
-		Published: false,
-		Rating:    0,
+	published := true
+	albums := []model.Album{
+		{Id: 3, Title: "Something New (Deluxe)", Artist: model.Artist{Id: 42, Name: "James"}, State: model.AlbumPublished, Rating: 5},
+		{Id: 1, Title: "Something New", Artist: model.Artist{Id: 42, Name: "James"}, State: model.AlbumPublished, Rating: 4},
 	}
 
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
 	mockAlbumDao.EXPECT().
-		Save(gomock.Eq(album)).
-		Return(int64(1), nil).
+		Query(gomock.Any(), gomock.Eq(dao.AlbumQuery{
+			ArtistId:   42,
+			Published:  &published,
+			MinRating:  4,
+			Title:      "New",
+			SortBy:     dao.AlbumSortRating,
+			Descending: true,
+			Limit:      2,
+			Offset:     2,
+		})).
+		Return(albums, int64(5), nil).
 		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		CountForAlbums(gomock.Any(), gomock.Eq([]int64{3, 1})).
+		Return(map[int64]int64{3: 2}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album: mockAlbumDao,
+		Album:   mockAlbumDao,
+		Comment: mockCommentDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(album)
-	suite.Nil(err)
-
-	buffer := bytes.NewBuffer(body)
-	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
+	resp, err := http.Get("http://localhost:8080/api/v1/album?artist=42&published=true&minRating=4&title=New&sort=-rating&limit=2&offset=2")
 	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
 	defer resp.Body.Close()
 
-	album.Id = 1
-	body, err = json.Marshal(album)
-	suite.Nil(err)
+	albums[0].CommentCount = 2
+	next := uint(4)
+	expected := model.AlbumPage{
+		Albums: albums,
+		Total:  5,
+		Limit:  2,
+		Offset: 2,
+		Next:   &next,
+	}
 
+	retPage := model.AlbumPage{}
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal(string(body), string(retBody))
+	suite.Nil(json.Unmarshal(retBody, &retPage))
+	suite.Equal(expected, retPage)
 }
 
-func (suite *AppSuite) TestCreateAlbumBadArtist() {
+func (suite *AppSuite) TestGetAlbumsInvalidQuery() {
 	defer suite.ctrl.Finish()
 
-	album := model.Album{
-		Title:     "Something Wicked This Way Comes",
-		Artist:    model.Artist{},
-		Published: false,
-		Rating:    0,
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for query, expected := range map[string]string{
+		"artist=cats":     "Invalid artist provided. Must be an integer.",
+		"published=maybe": "Invalid published provided. Must be true or false.",
+		"state=released":  "Invalid state provided. Must be one of draft, in_review, approved, scheduled, published, archived.",
+		"minRating=-1":    "Invalid minRating provided. Must be a non-negative integer.",
+		"sort=artist":     "Invalid sort provided. Cannot sort albums by \"artist\".",
+		"limit=0":         "Invalid limit provided. Must be between 1 and 100.",
+		"limit=101":       "Invalid limit provided. Must be between 1 and 100.",
+		"offset=waffles":  "Invalid offset provided. Must be a non-negative integer.",
+	} {
+		resp, err := http.Get("http://localhost:8080/api/v1/album?" + query)
+		suite.Nil(err)
+		suite.Equal(http.StatusBadRequest, resp.StatusCode, query)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(expected, problemDetail(retBody), query)
 	}
+}
+
+func (suite *AppSuite) TestGetAlbumsSortPublished() {
+	defer suite.ctrl.Finish()
 
+	// Sorting by published, from before albums had states, sorts by state.
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Query(gomock.Any(), gomock.Eq(dao.AlbumQuery{
+			SortBy:     dao.AlbumSortState,
+			Descending: true,
+			Limit:      application.DefaultAlbumPageSize,
+		})).
+		Return([]model.Album{}, int64(0), nil).
+		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
@@ -217,109 +407,111 @@ func (suite *AppSuite) TestCreateAlbumBadArtist() {
 		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(album)
+	resp, err := http.Get("http://localhost:8080/api/v1/album?sort=-published")
 	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
 
-	buffer := bytes.NewBuffer(body)
-	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
+func (suite *AppSuite) TestGetAlbumsError() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Query(gomock.Any(), gomock.Any()).
+		Return(nil, int64(0), errors.New("Database went away")).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/album")
 	suite.Nil(err)
-	suite.Equal(http.StatusBadRequest, resp.StatusCode)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
 	defer resp.Body.Close()
 
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Invalid album artist provided. Name cannot be empty when inserting an artist.\"}", string(retBody))
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestCreateAlbumNewArtist() {
+func (suite *AppSuite) TestGetAlbumsCommentCountError() {
 	defer suite.ctrl.Finish()
 
-	album := model.Album{
-		Title: "Something Wicked This Way Comes",
-		Artist: model.Artist{
-			Name: "James",
-		},
-		Published: false,
-		Rating:    0,
-	}
-	albumPostEdit := model.Album{
-		Title: "Something Wicked This Way Comes",
-		Artist: model.Artist{
-			Id:   42,
-			Name: "James",
-		},
-		Published: false,
-		Rating:    0,
-	}
-
-	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
-	mockArtistDao.EXPECT().
-		Save(gomock.Eq(album.Artist)).
-		Return(int64(42), nil).
-		Times(1)
-	mockArtistDao.EXPECT().Close().Times(1)
-
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
 	mockAlbumDao.EXPECT().
-		Save(gomock.Eq(albumPostEdit)).
-		Return(int64(1), nil).
+		Query(gomock.Any(), gomock.Any()).
+		Return([]model.Album{{Id: 1, Title: "Album 1"}}, int64(1), nil).
 		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
+	// Albums aren't sent back looking like they have no comments when the
+	// comments couldn't be counted.
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		CountForAlbums(gomock.Any(), gomock.Eq([]int64{1})).
+		Return(nil, errors.New("Database went away")).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album:  mockAlbumDao,
-		Artist: mockArtistDao,
+		Album:   mockAlbumDao,
+		Comment: mockCommentDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(album)
-	suite.Nil(err)
-
-	buffer := bytes.NewBuffer(body)
-	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
+	resp, err := http.Get("http://localhost:8080/api/v1/album")
 	suite.Nil(err)
-	suite.Equal(http.StatusCreated, resp.StatusCode)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
 	defer resp.Body.Close()
 
-	albumPostEdit.Id = 1
-	body, err = json.Marshal(albumPostEdit)
-	suite.Nil(err)
-
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal(string(body), string(retBody))
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestCreateAlbumNewArtistError() {
+func (suite *AppSuite) TestCreateAlbum() {
 	defer suite.ctrl.Finish()
 
 	album := model.Album{
 		Title: "Something Wicked This Way Comes",
 		Artist: model.Artist{
+			Id:   1,
 			Name: "James",
 		},
-		Published: false,
-		Rating:    0,
+		Rating: 0,
 	}
 
 	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
 	mockArtistDao.EXPECT().
-		Save(gomock.Eq(album.Artist)).
-		Return(int64(0), errors.New("Something went bad with the artist")).
+		Load(gomock.Any(), gomock.Eq(album.Artist.Id)).
+		Return(&album.Artist, nil).
 		Times(1)
 	mockArtistDao.EXPECT().Close().Times(1)
 
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(album)).
+		Return(int64(1), nil).
+		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
@@ -328,7 +520,7 @@ func (suite *AppSuite) TestCreateAlbumNewArtistError() {
 		Artist: mockArtistDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
@@ -339,44 +531,37 @@ func (suite *AppSuite) TestCreateAlbumNewArtistError() {
 	buffer := bytes.NewBuffer(body)
 	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
 	suite.Nil(err)
-	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
 	defer resp.Body.Close()
 
+	album.Id = 1
+	album.State = model.AlbumDraft
+	album.Version = 1
+	body, err = json.Marshal(album)
+	suite.Nil(err)
+
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Something went bad with the artist\"}", string(retBody))
+	suite.Equal(string(body), string(retBody))
 }
 
-func (suite *AppSuite) TestCreateAlbumError() {
+func (suite *AppSuite) TestCreateAlbumBadArtist() {
 	defer suite.ctrl.Finish()
 
 	album := model.Album{
-		Title: "Something Wicked This Way Comes",
-		Artist: model.Artist{
-			Id:   42,
-			Name: "James",
-		},
-		Published: false,
-		Rating:    0,
+		Title:  "Something Wicked This Way Comes",
+		Artist: model.Artist{},
+		Rating: 0,
 	}
 
-	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
-	mockArtistDao.EXPECT().Close().Times(1)
-
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
-	mockAlbumDao.EXPECT().
-		Save(gomock.Eq(album)).
-		Return(int64(0), errors.New("Waffles")).
-		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album:  mockAlbumDao,
-		Artist: mockArtistDao,
+		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
@@ -387,65 +572,73 @@ func (suite *AppSuite) TestCreateAlbumError() {
 	buffer := bytes.NewBuffer(body)
 	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
 	suite.Nil(err)
-	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
 	defer resp.Body.Close()
 
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Waffles\"}", string(retBody))
+	suite.Equal("Invalid fields provided: artist.name must not be empty.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestRetrieveAlbum() {
+func (suite *AppSuite) TestCreateAlbumInvalidFields() {
 	defer suite.ctrl.Finish()
 
 	album := model.Album{
-		Id:    123,
-		Title: "Something Wicked This Way Comes",
-		Artist: model.Artist{
-			Id:   42,
-			Name: "James",
+		Title:  strings.Repeat("a", 256),
+		Artist: model.Artist{Id: 42},
+		Rating: 9,
+		Tracks: []model.Track{
+			{Title: "Fine"},
+			{Title: " "},
+			{Title: "Also First", DiscNumber: 1, TrackNumber: 1},
 		},
-		Published: false,
-		Rating:    0,
 	}
 
-	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
-	mockAlbumDao.EXPECT().
-		Load(gomock.Eq(album.Id)).
-		Return(&album).
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(42))).
+		Return(nil, dao.ErrNotFound).
 		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album: mockAlbumDao,
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	resp, err := http.Get("http://localhost:8080/api/v1/album/123")
+	body, err := json.Marshal(album)
 	suite.Nil(err)
-	suite.Equal(http.StatusOK, resp.StatusCode)
-	defer resp.Body.Close()
 
-	retAlbum := model.Album{}
-	retBody, err := ioutil.ReadAll(resp.Body)
+	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", bytes.NewBuffer(body))
 	suite.Nil(err)
-	suite.Nil(json.Unmarshal(retBody, &retAlbum))
-	suite.Equal(album, retAlbum)
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody := application.Problem{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retBody))
+	suite.Equal(application.ProblemValidationFailed, retBody.Code)
+	suite.Equal([]application.FieldError{
+		{Field: "title", Message: "must be at most 255 characters"},
+		{Field: "rating", Message: "must be from 0 to 5"},
+		{Field: "tracks[1].title", Message: "must not be empty"},
+		{Field: "tracks[2].number", Message: "must not be taken by another track on disc 1"},
+		{Field: "artist.id", Message: "must refer to an existing artist"},
+	}, retBody.Fields)
 }
 
-func (suite *AppSuite) TestRetrieveAlbumNotFound() {
+func (suite *AppSuite) TestCreateAlbumRatingRange() {
 	defer suite.ctrl.Finish()
 
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
-	mockAlbumDao.EXPECT().
-		Load(gomock.Eq(int64(13))).
-		Return(nil).
-		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
@@ -453,22 +646,23 @@ func (suite *AppSuite) TestRetrieveAlbumNotFound() {
 		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server, application.WithRatingRange(1, 10))
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	resp, err := http.Get("http://localhost:8080/api/v1/album/13")
+	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json",
+		strings.NewReader("{\"title\":\"Waffles\",\"artist\":{\"name\":\"James\"},\"rating\":0}"))
 	suite.Nil(err)
-	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
 	defer resp.Body.Close()
 
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Album not found.\"}", string(retBody))
+	suite.Equal("Invalid fields provided: rating must be from 1 to 10.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestRetrieveAlbumInvalidId() {
+func (suite *AppSuite) TestCreateAlbumBadJSON() {
 	defer suite.ctrl.Finish()
 
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
@@ -479,25 +673,42 @@ func (suite *AppSuite) TestRetrieveAlbumInvalidId() {
 		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	resp, err := http.Get("http://localhost:8080/api/v1/album/cats")
-	suite.Nil(err)
-	suite.Equal(http.StatusBadRequest, resp.StatusCode)
-	defer resp.Body.Close()
-
-	retBody, err := ioutil.ReadAll(resp.Body)
-	suite.Nil(err)
-	suite.Equal("{\"error\":\"Invalid ID provided. Must be an integer.\"}", string(retBody))
+	for _, row := range []struct {
+		Body     string
+		Status   int
+		Expected string
+	}{
+		{"{\"title\":", http.StatusBadRequest, "Invalid JSON provided. The request body couldn't be read."},
+		{"{\"title\":7}", http.StatusUnprocessableEntity, "Invalid fields provided: title must be a string."},
+	} {
+		resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", strings.NewReader(row.Body))
+		suite.Nil(err)
+		suite.Equal(row.Status, resp.StatusCode, row.Body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		suite.Nil(err)
+		resp.Body.Close()
+		suite.Equal(row.Expected, problemDetail(retBody), row.Body)
+	}
 }
 
-func (suite *AppSuite) TestUpdateAlbumInvalidId() {
+func (suite *AppSuite) TestProblems() {
 	defer suite.ctrl.Finish()
 
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(404))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(503))).
+		Return(nil, dao.ErrUnavailable).
+		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
@@ -505,53 +716,91 @@ func (suite *AppSuite) TestUpdateAlbumInvalidId() {
 		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/cats", nil)
-	suite.Nil(err)
-
-	httpClient := &http.Client{}
-
-	resp, err := httpClient.Do(req)
-	suite.Nil(err)
-	suite.Equal(http.StatusBadRequest, resp.StatusCode)
-	defer resp.Body.Close()
-
-	retBody, err := ioutil.ReadAll(resp.Body)
-	suite.Nil(err)
-	suite.Equal("{\"error\":\"Invalid ID provided. Must be an integer.\"}", string(retBody))
+	for _, test := range []struct {
+		method string
+		url    string
+		body   string
+		code   application.ProblemCode
+		status int
+		title  string
+		fields []application.FieldError
+	}{
+		{http.MethodGet, "album/404", "", application.ProblemNotFound, http.StatusNotFound, "Not found", nil},
+		{http.MethodGet, "album/503", "", application.ProblemDatabaseUnavailable, http.StatusServiceUnavailable, "Database unavailable", nil},
+		{http.MethodGet, "album/cats", "", application.ProblemInvalidId, http.StatusBadRequest, "Invalid ID", nil},
+		{http.MethodGet, "album?limit=0", "", application.ProblemInvalidParameter, http.StatusBadRequest, "Invalid query parameter", nil},
+		{http.MethodGet, "waffles", "", application.ProblemNotFound, http.StatusNotFound, "Not found", nil},
+		{http.MethodPost, "album", "{\"title\":", application.ProblemInvalidJson, http.StatusBadRequest, "Invalid JSON", nil},
+		{http.MethodPost, "album", "{\"artist\":{\"name\":\"James\"}}", application.ProblemValidationFailed, http.StatusUnprocessableEntity, "Invalid fields",
+			[]application.FieldError{{Field: "title", Message: "must not be empty"}}},
+	} {
+		req, err := http.NewRequest(test.method, "http://localhost:8080/api/v1/"+test.url, strings.NewReader(test.body))
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.url)
+		suite.Equal("application/problem+json", resp.Header.Get("Content-Type"), test.url)
+
+		problem := application.Problem{}
+		suite.Nil(json.NewDecoder(resp.Body).Decode(&problem))
+		resp.Body.Close()
+
+		suite.Equal(application.ProblemTypeUri(test.code), problem.Type, test.url)
+		suite.Equal(test.title, problem.Title, test.url)
+		suite.Equal(test.status, problem.Status, test.url)
+		suite.Equal(test.code, problem.Code, test.url)
+		suite.Equal(resp.Header.Get("X-Request-ID"), problem.RequestId, test.url)
+		suite.NotEmpty(problem.Detail, test.url)
+		suite.Equal(test.fields, problem.Fields, test.url)
+	}
 }
 
-func (suite *AppSuite) TestUpdateAlbum() {
+func (suite *AppSuite) TestCreateAlbumNewArtist() {
 	defer suite.ctrl.Finish()
 
 	album := model.Album{
-		Id:    456,
 		Title: "Something Wicked This Way Comes",
 		Artist: model.Artist{
-			Id:   42,
 			Name: "James",
 		},
-		Published: false,
-		Rating:    0,
+		Rating: 0,
 	}
-
-	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	albumPostEdit := model.Album{
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Id:   42,
+			Name: "James",
+		},
+		Rating: 0,
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(album.Artist)).
+		Return(int64(42), nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
 	mockAlbumDao.EXPECT().
-		Save(gomock.Eq(album)).
-		Return(int64(456), nil).
+		Save(gomock.Any(), gomock.Eq(albumPostEdit)).
+		Return(int64(1), nil).
 		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album: mockAlbumDao,
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
@@ -560,282 +809,479 @@ func (suite *AppSuite) TestUpdateAlbum() {
 	suite.Nil(err)
 
 	buffer := bytes.NewBuffer(body)
-
-	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/456", buffer)
+	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
 	suite.Nil(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	defer resp.Body.Close()
 
-	httpClient := &http.Client{}
+	albumPostEdit.Id = 1
+	albumPostEdit.State = model.AlbumDraft
+	albumPostEdit.Version = 1
+	body, err = json.Marshal(albumPostEdit)
+	suite.Nil(err)
 
-	resp, err := httpClient.Do(req)
+	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal(http.StatusOK, resp.StatusCode)
-	defer resp.Body.Close()
+	suite.Equal(string(body), string(retBody))
 }
 
-func (suite *AppSuite) TestRemoveAlbumInvalidId() {
+func (suite *AppSuite) TestCreateAlbumNewArtistError() {
 	defer suite.ctrl.Finish()
 
+	album := model.Album{
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Name: "James",
+		},
+		Rating: 0,
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(album.Artist)).
+		Return(int64(0), errors.New("Something went bad with the artist")).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album: mockAlbumDao,
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/album/cats", nil)
+	body, err := json.Marshal(album)
 	suite.Nil(err)
 
-	httpClient := &http.Client{}
-
-	resp, err := httpClient.Do(req)
+	buffer := bytes.NewBuffer(body)
+	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
 	suite.Nil(err)
-	suite.Equal(http.StatusBadRequest, resp.StatusCode)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
 	defer resp.Body.Close()
 
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Invalid ID provided. Must be an integer.\"}", string(retBody))
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestRemoveAlbum() {
+func (suite *AppSuite) TestCreateAlbumError() {
 	defer suite.ctrl.Finish()
 
+	album := model.Album{
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Id:   42,
+			Name: "James",
+		},
+		Rating: 0,
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(album.Artist.Id)).
+		Return(&album.Artist, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
 	mockAlbumDao.EXPECT().
-		Delete(gomock.Eq(model.Album{Id: 456})).
-		Return(int64(1), nil).
+		Save(gomock.Any(), gomock.Eq(album)).
+		Return(int64(0), errors.New("Waffles")).
 		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album: mockAlbumDao,
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/album/456", nil)
+	body, err := json.Marshal(album)
 	suite.Nil(err)
 
-	httpClient := &http.Client{}
-
-	resp, err := httpClient.Do(req)
+	buffer := bytes.NewBuffer(body)
+	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", buffer)
 	suite.Nil(err)
-	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
 	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestRemoveAlbumError() {
+func (suite *AppSuite) TestCreateAlbumWithTracks() {
 	defer suite.ctrl.Finish()
 
+	album := model.Album{
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Name: "James",
+		},
+		Tracks: []model.Track{
+			{Title: "Intro"},
+			{Title: "Waffles", DiscNumber: 2},
+			{Title: "Outro", DiscNumber: 2},
+		},
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(album.Artist)).
+		Return(int64(42), nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
 	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
 	mockAlbumDao.EXPECT().
-		Delete(gomock.Eq(model.Album{Id: 456})).
-		Return(int64(0), errors.New("Unable to delete album")).
+		Save(gomock.Any(), gomock.Any()).
+		Return(int64(1), nil).
 		Times(1)
 	mockAlbumDao.EXPECT().Close().Times(1)
 
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	gomock.InOrder(
+		mockTrackDao.EXPECT().
+			Save(gomock.Any(), gomock.Eq(model.Track{Title: "Intro", AlbumId: 1, DiscNumber: 1, TrackNumber: 1})).
+			Return(int64(7), nil),
+		mockTrackDao.EXPECT().
+			Save(gomock.Any(), gomock.Eq(model.Track{Title: "Waffles", AlbumId: 1, DiscNumber: 2, TrackNumber: 1})).
+			Return(int64(8), nil),
+		mockTrackDao.EXPECT().
+			Save(gomock.Any(), gomock.Eq(model.Track{Title: "Outro", AlbumId: 1, DiscNumber: 2, TrackNumber: 2})).
+			Return(int64(9), nil),
+	)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	mockTransactor := mock.NewMockTransactor(suite.ctrl)
+	mockTransactor.EXPECT().
+		InTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, work func(dao.UnitOfWork) error) error {
+			return work(dao.UnitOfWork{
+				Artist: mockArtistDao,
+				Album:  mockAlbumDao,
+				Track:  mockTrackDao,
+			})
+		}).
+		Times(1)
+
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Album: mockAlbumDao,
+		Album:      mockAlbumDao,
+		Artist:     mockArtistDao,
+		Track:      mockTrackDao,
+		Transactor: mockTransactor,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/album/456", nil)
+	body, err := json.Marshal(album)
 	suite.Nil(err)
 
-	httpClient := &http.Client{}
-
-	resp, err := httpClient.Do(req)
+	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", bytes.NewBuffer(body))
 	suite.Nil(err)
-	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
 	defer resp.Body.Close()
 
-	retBody, err := ioutil.ReadAll(resp.Body)
-	suite.Nil(err)
-	suite.Equal("{\"error\":\"Unable to delete album\"}", string(retBody))
+	created := model.Album{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&created))
+	suite.Equal(int64(1), created.Id)
+	suite.Equal(int64(42), created.Artist.Id)
+	suite.Equal(model.AlbumDraft, created.State)
+	suite.Equal([]model.Track{
+		{Id: 7, Title: "Intro", AlbumId: 1, DiscNumber: 1, TrackNumber: 1, Version: 1},
+		{Id: 8, Title: "Waffles", AlbumId: 1, DiscNumber: 2, TrackNumber: 1, Version: 1},
+		{Id: 9, Title: "Outro", AlbumId: 1, DiscNumber: 2, TrackNumber: 2, Version: 1},
+	}, created.Tracks)
 }
 
-func (suite *AppSuite) TestGetArtists() {
+func (suite *AppSuite) TestCreateAlbumWithTracksRollback() {
 	defer suite.ctrl.Finish()
 
-	artists := []model.Artist{
-		{Id: 1, Name: "James"},
-		{Id: 2, Name: "Bobby"},
-		{Id: 3, Name: "Jayne"},
+	album := model.Album{
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Name: "James",
+		},
+		Tracks: []model.Track{
+			{Title: "Intro"},
+			{Title: "Waffles"},
+		},
 	}
 
 	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
 	mockArtistDao.EXPECT().
-		LoadAll().
-		Return(artists).
+		Save(gomock.Any(), gomock.Any()).
+		Return(int64(42), nil).
 		Times(1)
 	mockArtistDao.EXPECT().Close().Times(1)
 
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Save(gomock.Any(), gomock.Any()).
+		Return(int64(1), nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	gomock.InOrder(
+		mockTrackDao.EXPECT().
+			Save(gomock.Any(), gomock.Any()).
+			Return(int64(7), nil),
+		mockTrackDao.EXPECT().
+			Save(gomock.Any(), gomock.Any()).
+			Return(int64(0), errors.New("Waffles")),
+	)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	// The failed track must reach the transactor, so that it rolls back the
+	// artist, album and first track saved before it.
+	var workErr error
+	mockTransactor := mock.NewMockTransactor(suite.ctrl)
+	mockTransactor.EXPECT().
+		InTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, work func(dao.UnitOfWork) error) error {
+			workErr = work(dao.UnitOfWork{
+				Artist: mockArtistDao,
+				Album:  mockAlbumDao,
+				Track:  mockTrackDao,
+			})
+			return workErr
+		}).
+		Times(1)
+
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Artist: mockArtistDao,
+		Album:      mockAlbumDao,
+		Artist:     mockArtistDao,
+		Track:      mockTrackDao,
+		Transactor: mockTransactor,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	resp, err := http.Get("http://localhost:8080/api/v1/artist")
+	body, err := json.Marshal(album)
 	suite.Nil(err)
-	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, err := http.Post("http://localhost:8080/api/v1/album", "application/json", bytes.NewBuffer(body))
+	suite.Nil(err)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
 	defer resp.Body.Close()
 
-	retArtists := []model.Artist{}
+	suite.EqualError(workErr, "Waffles")
+
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Nil(json.Unmarshal(retBody, &retArtists))
-	suite.Equal(artists, retArtists)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestCreateArtist() {
+func (suite *AppSuite) TestRetrieveAlbum() {
 	defer suite.ctrl.Finish()
 
-	artist := model.Artist{
-		Name: "James",
+	album := model.Album{
+		Id:    123,
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Id:   42,
+			Name: "James",
+		},
+		Rating:  0,
+		Version: 3,
 	}
 
-	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
-	mockArtistDao.EXPECT().
-		Save(gomock.Eq(artist)).
-		Return(int64(1), nil).
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(album.Id)).
+		Return(&album, nil).
 		Times(1)
-	mockArtistDao.EXPECT().Close().Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		CountForAlbums(gomock.Any(), gomock.Eq([]int64{123})).
+		Return(map[int64]int64{123: 4}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Artist: mockArtistDao,
+		Album:   mockAlbumDao,
+		Comment: mockCommentDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(artist)
-	suite.Nil(err)
-
-	buffer := bytes.NewBuffer(body)
-	resp, err := http.Post("http://localhost:8080/api/v1/artist", "application/json", buffer)
+	resp, err := http.Get("http://localhost:8080/api/v1/album/123")
 	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("\"3\"", resp.Header.Get("ETag"))
 	defer resp.Body.Close()
 
-	artist.Id = 1
-	body, err = json.Marshal(artist)
-	suite.Nil(err)
-
+	retAlbum := model.Album{}
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal(string(body), string(retBody))
+	suite.Nil(json.Unmarshal(retBody, &retAlbum))
+	album.CommentCount = 4
+	suite.Equal(album, retAlbum)
 }
 
-func (suite *AppSuite) TestCreateArtistEmptyName() {
+func (suite *AppSuite) TestRetrieveAlbumNotFound() {
 	defer suite.ctrl.Finish()
 
-	artist := model.Artist{}
-
-	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
-	mockArtistDao.EXPECT().Close().Times(1)
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(13))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Artist: mockArtistDao,
+		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(artist)
-	suite.Nil(err)
-
-	buffer := bytes.NewBuffer(body)
-	resp, err := http.Post("http://localhost:8080/api/v1/artist", "application/json", buffer)
+	resp, err := http.Get("http://localhost:8080/api/v1/album/13")
 	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
 	defer resp.Body.Close()
 
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Artists must be named.\"}", string(retBody))
+	suite.Equal("Album not found.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestCreateArtistError() {
+func (suite *AppSuite) TestRetrieveAlbumUnavailable() {
 	defer suite.ctrl.Finish()
 
-	artist := model.Artist{
-		Name: "James",
-	}
-
-	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
-	mockArtistDao.EXPECT().
-		Save(gomock.Eq(artist)).
-		Return(int64(0), errors.New("Wat")).
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(13))).
+		Return(nil, fmt.Errorf("Unable to load album 13: %w", dao.ErrUnavailable)).
 		Times(1)
-	mockArtistDao.EXPECT().Close().Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Artist: mockArtistDao,
+		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(artist)
-	suite.Nil(err)
-
-	buffer := bytes.NewBuffer(body)
-	resp, err := http.Post("http://localhost:8080/api/v1/artist", "application/json", buffer)
+	resp, err := http.Get("http://localhost:8080/api/v1/album/13")
 	suite.Nil(err)
+	suite.Equal(http.StatusServiceUnavailable, resp.StatusCode)
 	defer resp.Body.Close()
 
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Wat\"}", string(retBody))
+	suite.Equal("The database is unavailable, please try again later.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestUpdateTrackInvalidId() {
+func (suite *AppSuite) TestRetrieveAlbumError() {
 	defer suite.ctrl.Finish()
 
-	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
-	mockTrackDao.EXPECT().Close().Times(1)
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(13))).
+		Return(nil, errors.New("Something bad happened")).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Track: mockTrackDao,
+		Album: mockAlbumDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/cats", nil)
+	resp, err := http.Get("http://localhost:8080/api/v1/album/13")
 	suite.Nil(err)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	defer resp.Body.Close()
 
-	httpClient := &http.Client{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRetrieveAlbumInvalidId() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/album/cats")
+	suite.Nil(err)
+	suite.Equal(http.StatusBadRequest, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Invalid ID provided. Must be an integer.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestUpdateAlbumInvalidId() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/cats", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
 
 	resp, err := httpClient.Do(req)
 	suite.Nil(err)
@@ -844,44 +1290,56 @@ func (suite *AppSuite) TestUpdateTrackInvalidId() {
 
 	retBody, err := ioutil.ReadAll(resp.Body)
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Invalid ID provided. Must be an integer.\"}", string(retBody))
+	suite.Equal("Invalid ID provided. Must be an integer.", problemDetail(retBody))
 }
 
-func (suite *AppSuite) TestUpdateTrack() {
+func (suite *AppSuite) TestUpdateAlbum() {
 	defer suite.ctrl.Finish()
 
-	track := model.Track{
-		Id:     456,
-		Title:  "Something Wicked This Way Comes",
+	album := model.Album{
+		Id:    456,
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Id:   42,
+			Name: "James",
+		},
 		Rating: 0,
 	}
 
-	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
-	mockTrackDao.EXPECT().
-		Save(gomock.Eq(track)).
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(album.Artist.Id)).
+		Return(&album.Artist, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(album)).
 		Return(int64(456), nil).
 		Times(1)
-	mockTrackDao.EXPECT().Close().Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Track: mockTrackDao,
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(track)
+	body, err := json.Marshal(album)
 	suite.Nil(err)
 
 	buffer := bytes.NewBuffer(body)
 
-	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/456", buffer)
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/456", buffer)
 	suite.Nil(err)
 
-	httpClient := &http.Client{}
+	httpClient := http.DefaultClient
 
 	resp, err := httpClient.Do(req)
 	suite.Nil(err)
@@ -889,97 +1347,4599 @@ func (suite *AppSuite) TestUpdateTrack() {
 	defer resp.Body.Close()
 }
 
-func (suite *AppSuite) TestUpdateTrackError() {
+func (suite *AppSuite) TestUpdateAlbumIfMatch() {
 	defer suite.ctrl.Finish()
 
-	track := model.Track{
-		Id:     456,
-		Title:  "Something Wicked This Way Comes",
-		Rating: 0,
+	album := model.Album{
+		Id:    456,
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Id:   42,
+			Name: "James",
+		},
 	}
 
-	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
-	mockTrackDao.EXPECT().
-		Save(gomock.Eq(track)).
-		Return(int64(0), errors.New("Bad day")).
+	// The version comes from If-Match rather than the body.
+	saved := album
+	saved.Version = 3
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(album.Artist.Id)).
+		Return(&album.Artist, nil).
 		Times(1)
-	mockTrackDao.EXPECT().Close().Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(saved)).
+		Return(int64(456), nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Track: mockTrackDao,
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(track)
+	album.Version = 1
+	body, err := json.Marshal(album)
 	suite.Nil(err)
 
-	buffer := bytes.NewBuffer(body)
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/456", bytes.NewBuffer(body))
+	suite.Nil(err)
+	req.Header.Set("If-Match", "\"3\"")
 
-	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/456", buffer)
+	resp, err := http.DefaultClient.Do(req)
 	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("\"4\"", resp.Header.Get("ETag"))
+	defer resp.Body.Close()
+}
 
-	httpClient := &http.Client{}
+func (suite *AppSuite) TestUpdateAlbumStale() {
+	defer suite.ctrl.Finish()
 
-	resp, err := httpClient.Do(req)
+	album := model.Album{
+		Id:    456,
+		Title: "Something Wicked This Way Comes",
+		Artist: model.Artist{
+			Id:   42,
+			Name: "James",
+		},
+		Version: 3,
+	}
+	current := model.Album{
+		Id:    456,
+		Title: "Something Else Entirely",
+		Artist: model.Artist{
+			Id:   42,
+			Name: "James",
+		},
+		Tracks:  []model.Track{},
+		State:   model.AlbumDraft,
+		Version: 5,
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(album.Artist.Id)).
+		Return(&album.Artist, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(album)).
+		Return(int64(0), fmt.Errorf("%w: album 456 is no longer at version 3", dao.ErrConflict)).
+		Times(1)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(album.Id)).
+		Return(&current, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		CountForAlbums(gomock.Any(), gomock.Eq([]int64{456})).
+		Return(map[int64]int64{}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:   mockAlbumDao,
+		Artist:  mockArtistDao,
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(album)
 	suite.Nil(err)
-	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
-	defer resp.Body.Close()
 
-	retBody, err := ioutil.ReadAll(resp.Body)
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/456", bytes.NewBuffer(body))
 	suite.Nil(err)
-	suite.Equal("{\"error\":\"Bad day\"}", string(retBody))
+	req.Header.Set("If-Match", "\"3\"")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	suite.Equal("\"5\"", resp.Header.Get("ETag"))
+	defer resp.Body.Close()
+
+	retProblem := struct {
+		Code    application.ProblemCode
+		Current model.Album
+	}{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retProblem))
+	suite.Equal(application.ProblemStaleVersion, retProblem.Code)
+	suite.Equal(current, retProblem.Current)
 }
 
-func (suite *AppSuite) TestCreateTrack() {
+func (suite *AppSuite) TestUpdateAlbumInvalidIfMatch() {
 	defer suite.ctrl.Finish()
 
-	track := model.Track{
-		Title:  "Something Wicked This Way Comes",
-		Rating: 0,
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
 	}
 
-	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
-	mockTrackDao.EXPECT().
-		Save(gomock.Eq(track)).
-		Return(int64(111), nil).
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, ifMatch := range []string{"3", "W/\"3\"", "\"waffles\"", "\"0\""} {
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/456", bytes.NewBufferString("{\"title\":\"Waffles\"}"))
+		suite.Nil(err)
+		req.Header.Set("If-Match", ifMatch)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(http.StatusBadRequest, resp.StatusCode, ifMatch)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		suite.Nil(err)
+		resp.Body.Close()
+		suite.Equal("Invalid If-Match header provided. Must be the ETag of the copy being changed.", problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestPatchAlbum() {
+	defer suite.ctrl.Finish()
+
+	original := model.Album{
+		Id:      456,
+		Title:   "Something Wicked This Way Comes",
+		Artist:  model.Artist{Id: 42, Name: "James"},
+		Tracks:  []model.Track{},
+		State:   model.AlbumInReview,
+		Rating:  5,
+		Version: 2,
+	}
+	patched := original
+	patched.Title = "Something Wicked"
+	patched.Artist = model.Artist{Id: 7, Name: "Bobby"}
+	patched.Version = 3
+
+	// Only the title and artist change, the rating is left alone and the save
+	// is made against the version that was patched.
+	saved := original
+	saved.Title = "Something Wicked"
+	saved.Artist = model.Artist{Id: 7, Name: "James"}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	gomock.InOrder(
+		mockAlbumDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(original.Id)).
+			Return(&original, nil),
+		mockAlbumDao.EXPECT().
+			Save(gomock.Any(), gomock.Eq(saved)).
+			Return(int64(456), nil),
+		mockAlbumDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(original.Id)).
+			Return(&patched, nil),
+	)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&patched.Artist, nil).
 		Times(1)
-	mockTrackDao.EXPECT().Close().Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		CountForAlbums(gomock.Any(), gomock.Eq([]int64{456})).
+		Return(map[int64]int64{}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
 
 	server := server.NewServer(suite.cfg)
 	dbClient := db.DatabaseClient{
-		Track: mockTrackDao,
+		Album:   mockAlbumDao,
+		Artist:  mockArtistDao,
+		Comment: mockCommentDao,
 	}
 
-	app := application.NewApp(dbClient, server)
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
 	suite.NotNil(app)
 	defer app.Close()
 	app.Run()
 
-	body, err := json.Marshal(track)
+	req := newPatchRequest("http://localhost:8080/api/v1/album/456", "{\"title\":\"Something Wicked\",\"artist\":{\"id\":7},\"state\":\"published\"}")
+	req.Header.Set("If-Match", "\"2\"")
+
+	resp, err := http.DefaultClient.Do(req)
 	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("\"3\"", resp.Header.Get("ETag"))
+	defer resp.Body.Close()
 
-	buffer := bytes.NewBuffer(body)
+	retAlbum := model.Album{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retAlbum))
+	suite.Equal(patched, retAlbum)
+}
 
-	req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/track", buffer)
-	suite.Nil(err)
+func (suite *AppSuite) TestPatchAlbumStale() {
+	defer suite.ctrl.Finish()
 
-	httpClient := &http.Client{}
+	current := model.Album{
+		Id:      456,
+		Title:   "Something Wicked This Way Comes",
+		Artist:  model.Artist{Id: 42, Name: "James"},
+		Tracks:  []model.Track{},
+		State:   model.AlbumDraft,
+		Version: 5,
+	}
 
-	resp, err := httpClient.Do(req)
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(current.Id)).
+		Return(&current, nil).
+		Times(2)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		CountForAlbums(gomock.Any(), gomock.Eq([]int64{456})).
+		Return(map[int64]int64{}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:   mockAlbumDao,
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req := newPatchRequest("http://localhost:8080/api/v1/album/456", "{\"rating\":1}")
+	req.Header.Set("If-Match", "\"4\"")
+
+	resp, err := http.DefaultClient.Do(req)
 	suite.Nil(err)
-	suite.Equal(http.StatusCreated, resp.StatusCode)
+	suite.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	suite.Equal("\"5\"", resp.Header.Get("ETag"))
 	defer resp.Body.Close()
 
-	track.Id = 111
-	body, err = json.Marshal(track)
-	suite.Nil(err)
+	retProblem := struct {
+		Code    application.ProblemCode
+		Current model.Album
+	}{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retProblem))
+	suite.Equal(application.ProblemStaleVersion, retProblem.Code)
+	suite.Equal(current, retProblem.Current)
+}
 
-	retBody, err := ioutil.ReadAll(resp.Body)
+func (suite *AppSuite) TestPatchAlbumInvalid() {
+	defer suite.ctrl.Finish()
+
+	original := model.Album{
+		Id:      456,
+		Title:   "Something Wicked This Way Comes",
+		Artist:  model.Artist{Id: 42, Name: "James"},
+		Version: 2,
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(original.Id)).
+		Return(&original, nil).
+		AnyTimes()
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(original.Artist.Id)).
+		Return(&original.Artist, nil).
+		AnyTimes()
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, row := range []struct {
+		Patch    string
+		Status   int
+		Expected string
+	}{
+		{"{\"title\":null}", http.StatusUnprocessableEntity, "Invalid fields provided: title must not be empty."},
+		{"{\"artist\":null}", http.StatusUnprocessableEntity, "Invalid fields provided: artist.name must not be empty."},
+		{"{\"rating\":\"five\"}", http.StatusUnprocessableEntity, "Invalid fields provided: rating must be a whole number, no less than 0."},
+		{"{\"title\":\"Waffles\"", http.StatusBadRequest, "Invalid patch provided. Must be a JSON merge patch."},
+	} {
+		resp, err := http.DefaultClient.Do(newPatchRequest("http://localhost:8080/api/v1/album/456", row.Patch))
+		suite.Nil(err)
+		suite.Equal(row.Status, resp.StatusCode, row.Patch)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		suite.Nil(err)
+		resp.Body.Close()
+		suite.Equal(row.Expected, problemDetail(retBody), row.Patch)
+	}
+
+	req := newPatchRequest("http://localhost:8080/api/v1/album/456", "title=Waffles")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
 	suite.Nil(err)
-	suite.Equal(string(body), string(retBody))
+	suite.Equal(http.StatusUnsupportedMediaType, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func (suite *AppSuite) TestPatchAlbumNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(456))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.DefaultClient.Do(newPatchRequest("http://localhost:8080/api/v1/album/456", "{\"rating\":1}"))
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func (suite *AppSuite) TestRemoveAlbumInvalidId() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/album/cats", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusBadRequest, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Invalid ID provided. Must be an integer.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRemoveAlbum() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Album{Id: 456})).
+		Return(int64(1), nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/album/456", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+}
+
+func (suite *AppSuite) TestRemoveAlbumNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Album{Id: 456})).
+		Return(int64(0), nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/album/456", nil)
+	suite.Nil(err)
+	req.Header.Set("X-Request-ID", "remove-456")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	suite.Equal("application/problem+json", resp.Header.Get("Content-Type"))
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("{\"type\":\"urn:citadel:problem:not_found\",\"title\":\"Not found\",\"status\":404,\"detail\":\"Album not found.\",\"requestId\":\"remove-456\",\"code\":\"not_found\"}", string(retBody))
+}
+
+func (suite *AppSuite) TestRemoveAlbumError() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Album{Id: 456})).
+		Return(int64(0), errors.New("Unable to delete album")).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/album/456", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestGetArtists() {
+	defer suite.ctrl.Finish()
+
+	artists := []model.Artist{
+		{Id: 1, Name: "James"},
+		{Id: 2, Name: "Bobby"},
+		{Id: 3, Name: "Jayne"},
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		LoadAll(gomock.Any()).
+		Return(artists, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/artist")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retArtists := []model.Artist{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retArtists))
+	suite.Equal(artists, retArtists)
+}
+
+func (suite *AppSuite) TestGetArtistsError() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		LoadAll(gomock.Any()).
+		Return(nil, errors.New("Something bad happened")).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/artist")
+	suite.Nil(err)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestQueryTimeout() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		LoadAll(gomock.Any()).
+		DoAndReturn(func(ctx context.Context) ([]model.Artist, error) {
+			deadline, found := ctx.Deadline()
+			suite.True(found)
+			suite.WithinDuration(time.Now().Add(time.Minute), deadline, 5*time.Second)
+			return []model.Artist{}, nil
+		}).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist:       mockArtistDao,
+		QueryTimeout: time.Minute,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/artist")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func (suite *AppSuite) TestNoQueryTimeout() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		LoadAll(gomock.Any()).
+		DoAndReturn(func(ctx context.Context) ([]model.Artist, error) {
+			_, found := ctx.Deadline()
+			suite.False(found)
+			return []model.Artist{}, nil
+		}).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/artist")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func (suite *AppSuite) TestCreateArtist() {
+	defer suite.ctrl.Finish()
+
+	artist := model.Artist{
+		Name: "James",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		LoadByName(gomock.Any(), gomock.Eq(artist.Name)).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(artist)).
+		Return(int64(1), nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(artist)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+	resp, err := http.Post("http://localhost:8080/api/v1/artist", "application/json", buffer)
+	suite.Nil(err)
+	defer resp.Body.Close()
+
+	artist.Id = 1
+	body, err = json.Marshal(artist)
+	suite.Nil(err)
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal(string(body), string(retBody))
+}
+
+func (suite *AppSuite) TestCreateArtistEmptyName() {
+	defer suite.ctrl.Finish()
+
+	artist := model.Artist{}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(artist)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+	resp, err := http.Post("http://localhost:8080/api/v1/artist", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Invalid fields provided: name must not be empty.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestCreateArtistError() {
+	defer suite.ctrl.Finish()
+
+	artist := model.Artist{
+		Name: "James",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		LoadByName(gomock.Any(), gomock.Eq(artist.Name)).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(artist)).
+		Return(int64(0), errors.New("Wat")).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(artist)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+	resp, err := http.Post("http://localhost:8080/api/v1/artist", "application/json", buffer)
+	suite.Nil(err)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestCreateArtistDuplicate() {
+	defer suite.ctrl.Finish()
+
+	artist := model.Artist{
+		Name: "James",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		LoadByName(gomock.Any(), gomock.Eq(artist.Name)).
+		Return(&model.Artist{Id: 42, Name: "James"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(artist)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+	resp, err := http.Post("http://localhost:8080/api/v1/artist", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("An artist with that name already exists.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRetrieveArtist() {
+	defer suite.ctrl.Finish()
+
+	artist := model.Artist{
+		Id:   42,
+		Name: "James",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(artist.Id)).
+		Return(&artist, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/artist/42")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retArtist := model.Artist{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retArtist))
+	suite.Equal(artist, retArtist)
+}
+
+func (suite *AppSuite) TestRetrieveArtistNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(13))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/artist/13")
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Artist not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestUpdateArtist() {
+	defer suite.ctrl.Finish()
+
+	artist := model.Artist{
+		Id:   42,
+		Name: "Jimmy",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(artist.Id)).
+		Return(&model.Artist{Id: 42, Name: "James"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().
+		LoadByName(gomock.Any(), gomock.Eq(artist.Name)).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(artist)).
+		Return(int64(42), nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(artist)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/artist/42", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+}
+
+func (suite *AppSuite) TestUpdateArtistNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(42))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"name\":\"Jimmy\"}")
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/artist/42", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Artist not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestUpdateArtistDuplicate() {
+	defer suite.ctrl.Finish()
+
+	artist := model.Artist{
+		Id:   42,
+		Name: "Jimmy",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(artist.Id)).
+		Return(&model.Artist{Id: 42, Name: "James"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().
+		LoadByName(gomock.Any(), gomock.Eq(artist.Name)).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(artist)).
+		Return(int64(0), fmt.Errorf("%w: Jimmy", dao.ErrDuplicate)).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(artist)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/artist/42", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("An artist with that name already exists.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestPatchArtist() {
+	defer suite.ctrl.Finish()
+
+	original := model.Artist{
+		Id:   42,
+		Name: "James",
+	}
+	patched := model.Artist{
+		Id:   42,
+		Name: "Jim",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(original.Id)).
+		Return(&original, nil).
+		Times(1)
+	mockArtistDao.EXPECT().
+		LoadByName(gomock.Any(), gomock.Eq("Jim")).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(patched)).
+		Return(int64(0), nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.DefaultClient.Do(newPatchRequest("http://localhost:8080/api/v1/artist/42", "{\"id\":7,\"name\":\"Jim\"}"))
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retArtist := model.Artist{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retArtist))
+	suite.Equal(patched, retArtist)
+}
+
+func (suite *AppSuite) TestPatchArtistDuplicate() {
+	defer suite.ctrl.Finish()
+
+	original := model.Artist{
+		Id:   42,
+		Name: "James",
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(original.Id)).
+		Return(&original, nil).
+		Times(1)
+	mockArtistDao.EXPECT().
+		LoadByName(gomock.Any(), gomock.Eq("Bobby")).
+		Return(&model.Artist{Id: 7, Name: "Bobby"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.DefaultClient.Do(newPatchRequest("http://localhost:8080/api/v1/artist/42", "{\"name\":\"Bobby\"}"))
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("An artist with that name already exists.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRemoveArtist() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(42))).
+		Return(&model.Artist{Id: 42, Name: "James"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Artist{Id: 42})).
+		Return(int64(1), nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Query(gomock.Any(), gomock.Eq(dao.AlbumQuery{ArtistId: 42, Limit: 1})).
+		Return([]model.Album{}, int64(0), nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/artist/42", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+}
+
+func (suite *AppSuite) TestRemoveArtistWithAlbums() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(42))).
+		Return(&model.Artist{Id: 42, Name: "James"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Query(gomock.Any(), gomock.Eq(dao.AlbumQuery{ArtistId: 42, Limit: 1})).
+		Return([]model.Album{{Id: 1}}, int64(3), nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/artist/42", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Artist still has 3 album(s). Use cascade=true to delete them as well.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRemoveArtistCascade() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(42))).
+		Return(&model.Artist{Id: 42, Name: "James"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Artist{Id: 42})).
+		Return(int64(1), nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:  mockAlbumDao,
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/artist/42?cascade=true", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+}
+
+func (suite *AppSuite) TestRemoveArtistNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(42))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Artist: mockArtistDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/artist/42", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Artist not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRetrieveArtistAlbums() {
+	defer suite.ctrl.Finish()
+
+	albums := []model.Album{
+		{Id: 1, Title: "Waffle Irons", Artist: model.Artist{Id: 42, Name: "James"}, Tracks: []model.Track{}},
+	}
+
+	mockArtistDao := mock.NewMockArtistDao(suite.ctrl)
+	mockArtistDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(42))).
+		Return(&model.Artist{Id: 42, Name: "James"}, nil).
+		Times(1)
+	mockArtistDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Query(gomock.Any(), gomock.Eq(dao.AlbumQuery{ArtistId: 42, Limit: application.DefaultAlbumPageSize})).
+		Return(albums, int64(1), nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		CountForAlbums(gomock.Any(), gomock.Eq([]int64{1})).
+		Return(map[int64]int64{}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:   mockAlbumDao,
+		Artist:  mockArtistDao,
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/artist/42/albums")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retPage := model.AlbumPage{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retPage))
+	suite.Equal(model.AlbumPage{Albums: albums, Total: 1, Limit: application.DefaultAlbumPageSize}, retPage)
+}
+
+func (suite *AppSuite) TestUpdateTrackInvalidId() {
+	defer suite.ctrl.Finish()
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/cats", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusBadRequest, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Invalid ID provided. Must be an integer.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestUpdateTrack() {
+	defer suite.ctrl.Finish()
+
+	track := model.Track{
+		Id:      456,
+		Title:   "Something Wicked This Way Comes",
+		AlbumId: 123,
+		Rating:  0,
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(track.AlbumId)).
+		Return(&model.Album{Id: track.AlbumId, Tracks: []model.Track{
+			{Id: 455, AlbumId: track.AlbumId, DiscNumber: 1, TrackNumber: 1},
+			{Id: 456, AlbumId: track.AlbumId, DiscNumber: 2, TrackNumber: 3},
+		}}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	// The body leaves out the position, so the track stays where it was.
+	saved := track
+	saved.DiscNumber = 2
+	saved.TrackNumber = 3
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(saved)).
+		Return(int64(456), nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(track)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/456", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+}
+
+func (suite *AppSuite) TestUpdateTrackNewDisc() {
+	defer suite.ctrl.Finish()
+
+	track := model.Track{
+		Id:         456,
+		Title:      "Something Wicked This Way Comes",
+		AlbumId:    123,
+		DiscNumber: 1,
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(track.AlbumId)).
+		Return(&model.Album{Id: track.AlbumId, Tracks: []model.Track{
+			{Id: 455, AlbumId: track.AlbumId, DiscNumber: 1, TrackNumber: 1},
+			{Id: 456, AlbumId: track.AlbumId, DiscNumber: 2, TrackNumber: 1},
+		}}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	// Moving to another disc without a number goes on the end of that disc.
+	saved := track
+	saved.TrackNumber = 2
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(saved)).
+		Return(int64(456), nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(track)
+	suite.Nil(err)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/456", bytes.NewBuffer(body))
+	suite.Nil(err)
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+}
+
+func (suite *AppSuite) TestUpdateTrackError() {
+	defer suite.ctrl.Finish()
+
+	track := model.Track{
+		Id:          456,
+		Title:       "Something Wicked This Way Comes",
+		AlbumId:     123,
+		Rating:      0,
+		DiscNumber:  1,
+		TrackNumber: 1,
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(track.AlbumId)).
+		Return(&model.Album{Id: track.AlbumId}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(track)).
+		Return(int64(0), errors.New("Bad day")).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(track)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/456", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestUpdateTrackStale() {
+	defer suite.ctrl.Finish()
+
+	track := model.Track{
+		Id:          456,
+		Title:       "Something Wicked This Way Comes",
+		AlbumId:     123,
+		DiscNumber:  1,
+		TrackNumber: 1,
+		Version:     2,
+	}
+	current := model.Track{
+		Id:          456,
+		Title:       "Something Else Entirely",
+		AlbumId:     123,
+		DiscNumber:  1,
+		TrackNumber: 4,
+		Version:     3,
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(track.AlbumId)).
+		Return(&model.Album{Id: track.AlbumId}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(track)).
+		Return(int64(0), fmt.Errorf("%w: track 456 is no longer at version 2", dao.ErrConflict)).
+		Times(1)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(track.Id)).
+		Return(&current, nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(track)
+	suite.Nil(err)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/track/456", bytes.NewBuffer(body))
+	suite.Nil(err)
+	req.Header.Set("If-Match", "\"2\"")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	suite.Equal("\"3\"", resp.Header.Get("ETag"))
+	defer resp.Body.Close()
+
+	retProblem := struct {
+		Code    application.ProblemCode
+		Current model.Track
+	}{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retProblem))
+	suite.Equal(application.ProblemStaleVersion, retProblem.Code)
+	suite.Equal(current, retProblem.Current)
+}
+
+func (suite *AppSuite) TestPatchTrack() {
+	defer suite.ctrl.Finish()
+
+	original := model.Track{
+		Id:          456,
+		Title:       "Something Wicked This Way Comes",
+		AlbumId:     123,
+		Rating:      5,
+		DiscNumber:  1,
+		TrackNumber: 3,
+		Version:     2,
+	}
+	saved := original
+	saved.Rating = 4
+	saved.AlbumId = 124
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(original.Id)).
+		Return(&original, nil).
+		Times(1)
+	mockTrackDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(saved)).
+		Return(int64(456), nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(124))).
+		Return(&model.Album{Id: 124}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.DefaultClient.Do(newPatchRequest("http://localhost:8080/api/v1/track/456", "{\"rating\":4,\"album\":124,\"version\":1}"))
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("\"3\"", resp.Header.Get("ETag"))
+	defer resp.Body.Close()
+
+	saved.Version = 3
+	retTrack := model.Track{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retTrack))
+	suite.Equal(saved, retTrack)
+}
+
+func (suite *AppSuite) TestPatchTrackInvalidAlbum() {
+	defer suite.ctrl.Finish()
+
+	original := model.Track{
+		Id:          456,
+		Title:       "Something Wicked This Way Comes",
+		AlbumId:     123,
+		DiscNumber:  1,
+		TrackNumber: 3,
+		Version:     2,
+	}
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(original.Id)).
+		Return(&original, nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(999))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.DefaultClient.Do(newPatchRequest("http://localhost:8080/api/v1/track/456", "{\"album\":999}"))
+	suite.Nil(err)
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Invalid fields provided: album must refer to an existing album.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestPatchTrackInvalidPosition() {
+	defer suite.ctrl.Finish()
+
+	original := model.Track{
+		Id:          456,
+		Title:       "Something Wicked This Way Comes",
+		AlbumId:     123,
+		DiscNumber:  1,
+		TrackNumber: 3,
+		Version:     2,
+	}
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(original.Id)).
+		Return(&original, nil).
+		Times(3)
+	mockTrackDao.EXPECT().
+		LoadForAlbum(gomock.Any(), gomock.Eq(original.AlbumId)).
+		Return([]model.Track{
+			{Id: 455, AlbumId: 123, DiscNumber: 1, TrackNumber: 1},
+			original,
+		}, nil).
+		Times(3)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for patch, expected := range map[string]string{
+		"{\"number\":0}": "Invalid fields provided: number must be at least 1.",
+		"{\"disc\":0}":   "Invalid fields provided: disc must be at least 1.",
+		"{\"number\":1}": "Invalid fields provided: number must not be taken by another track on disc 1.",
+	} {
+		resp, err := http.DefaultClient.Do(newPatchRequest("http://localhost:8080/api/v1/track/456", patch))
+		suite.Nil(err)
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, patch)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(expected, problemDetail(retBody), patch)
+	}
+}
+
+func (suite *AppSuite) TestCreateTrackTakenPosition() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, Tracks: []model.Track{
+			{Id: 455, AlbumId: 123, DiscNumber: 1, TrackNumber: 1},
+			{Id: 456, AlbumId: 123, DiscNumber: 2, TrackNumber: 1},
+		}}, nil).
+		Times(2)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	// A new track can't be put at a taken position, and neither can a track
+	// moved around with a PUT.
+	for _, row := range []struct {
+		Method string
+		Url    string
+		Track  model.Track
+	}{
+		{http.MethodPost, "http://localhost:8080/api/v1/track", model.Track{Title: "New", AlbumId: 123, DiscNumber: 2, TrackNumber: 1}},
+		{http.MethodPut, "http://localhost:8080/api/v1/track/455", model.Track{Title: "Moved", AlbumId: 123, DiscNumber: 2, TrackNumber: 1}},
+	} {
+		body, err := json.Marshal(row.Track)
+		suite.Nil(err)
+
+		req, err := http.NewRequest(row.Method, row.Url, bytes.NewBuffer(body))
+		suite.Nil(err)
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, row.Method)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal("Invalid fields provided: number must not be taken by another track on disc 2.", problemDetail(retBody), row.Method)
+	}
+}
+
+func (suite *AppSuite) TestCreateTrack() {
+	defer suite.ctrl.Finish()
+
+	track := model.Track{
+		Title:   "Something Wicked This Way Comes",
+		AlbumId: 123,
+		Rating:  0,
+	}
+	trackPostEdit := model.Track{
+		Title:       "Something Wicked This Way Comes",
+		AlbumId:     123,
+		Rating:      0,
+		DiscNumber:  1,
+		TrackNumber: 3,
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{
+			Id: 123,
+			Tracks: []model.Track{
+				{Id: 1, AlbumId: 123, DiscNumber: 1, TrackNumber: 1},
+				{Id: 2, AlbumId: 123, DiscNumber: 1, TrackNumber: 2},
+				{Id: 3, AlbumId: 123, DiscNumber: 2, TrackNumber: 7},
+			},
+		}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(trackPostEdit)).
+		Return(int64(111), nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(track)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/track", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	defer resp.Body.Close()
+
+	trackPostEdit.Id = 111
+	trackPostEdit.Version = 1
+	body, err = json.Marshal(trackPostEdit)
+	suite.Nil(err)
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal(string(body), string(retBody))
+}
+
+func (suite *AppSuite) TestCreateTrackPositionTakenOnSave() {
+	defer suite.ctrl.Finish()
+
+	track := model.Track{
+		Title:       "Something Wicked This Way Comes",
+		AlbumId:     123,
+		DiscNumber:  1,
+		TrackNumber: 1,
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(track.AlbumId)).
+		Return(&model.Album{Id: track.AlbumId}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	// Another track took the position after it was checked.
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(track)).
+		Return(int64(0), fmt.Errorf("%w: Duplicate entry for key 'track_position'", dao.ErrDuplicate)).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(track)
+	suite.Nil(err)
+
+	resp, err := http.Post("http://localhost:8080/api/v1/track", "application/json", bytes.NewBuffer(body))
+	suite.Nil(err)
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody := application.Problem{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retBody))
+	suite.Equal([]application.FieldError{
+		{Field: "number", Message: "must not be taken by another track on disc 1"},
+	}, retBody.Fields)
+}
+
+func (suite *AppSuite) TestCreateTrackInvalidAlbum() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, row := range []struct {
+		Track    model.Track
+		Expected string
+	}{
+		{model.Track{Title: "No Album"}, "Invalid fields provided: album must refer to an existing album."},
+		{model.Track{Title: "Missing Album", AlbumId: 123}, "Invalid fields provided: album must refer to an existing album."},
+	} {
+		body, err := json.Marshal(row.Track)
+		suite.Nil(err)
+
+		resp, err := http.Post("http://localhost:8080/api/v1/track", "application/json", bytes.NewBuffer(body))
+		suite.Nil(err)
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(row.Expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestRetrieveTrack() {
+	defer suite.ctrl.Finish()
+
+	track := model.Track{
+		Id:      456,
+		Title:   "Something Wicked This Way Comes",
+		AlbumId: 123,
+		Rating:  3,
+	}
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(track.Id)).
+		Return(&track, nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/track/456")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retTrack := model.Track{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retTrack))
+	suite.Equal(track, retTrack)
+}
+
+func (suite *AppSuite) TestRetrieveTrackNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(13))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/track/13")
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Track not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRemoveTrack() {
+	defer suite.ctrl.Finish()
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Track{Id: 456})).
+		Return(int64(1), nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/track/456", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+}
+
+func (suite *AppSuite) TestRemoveTrackNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Track{Id: 456})).
+		Return(int64(0), nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/track/456", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Track not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRemoveTrackError() {
+	defer suite.ctrl.Finish()
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(model.Track{Id: 456})).
+		Return(int64(0), errors.New("Unable to delete track")).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/track/456", nil)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRetrieveAlbumTracks() {
+	defer suite.ctrl.Finish()
+
+	tracks := []model.Track{
+		{Id: 1, Title: "Track 1", AlbumId: 123, Rating: 5},
+		{Id: 2, Title: "Track 2", AlbumId: 123, Rating: 4},
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		LoadForAlbum(gomock.Any(), gomock.Eq(int64(123))).
+		Return(tracks, nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/album/123/tracks")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retTracks := []model.Track{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retTracks))
+	suite.Equal(tracks, retTracks)
+}
+
+func (suite *AppSuite) TestRetrieveAlbumTracksNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/album/123/tracks")
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Album not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestReorderAlbumTracks() {
+	defer suite.ctrl.Finish()
+
+	order := []model.TrackPosition{
+		{Id: 3, DiscNumber: 1, TrackNumber: 1},
+		{Id: 1, DiscNumber: 1, TrackNumber: 2},
+		{Id: 2, DiscNumber: 2, TrackNumber: 1},
+	}
+	reordered := []model.Track{
+		{Id: 3, Title: "Track 3", AlbumId: 123, DiscNumber: 1, TrackNumber: 1},
+		{Id: 1, Title: "Track 1", AlbumId: 123, DiscNumber: 1, TrackNumber: 2},
+		{Id: 2, Title: "Track 2", AlbumId: 123, DiscNumber: 2, TrackNumber: 1},
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{
+			Id: 123,
+			Tracks: []model.Track{
+				{Id: 1, Title: "Track 1", AlbumId: 123, DiscNumber: 1, TrackNumber: 1},
+				{Id: 2, Title: "Track 2", AlbumId: 123, DiscNumber: 1, TrackNumber: 2},
+				{Id: 3, Title: "Track 3", AlbumId: 123, DiscNumber: 1, TrackNumber: 3},
+			},
+		}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Reorder(gomock.Any(), gomock.Eq(int64(123)), gomock.Eq(order)).
+		Return(nil).
+		Times(1)
+	mockTrackDao.EXPECT().
+		LoadForAlbum(gomock.Any(), gomock.Eq(int64(123))).
+		Return(reordered, nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(order)
+	suite.Nil(err)
+
+	buffer := bytes.NewBuffer(body)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/123/tracks", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retTracks := []model.Track{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retTracks))
+	suite.Equal(reordered, retTracks)
+}
+
+func (suite *AppSuite) TestReorderAlbumTracksInvalidOrder() {
+	defer suite.ctrl.Finish()
+
+	album := model.Album{
+		Id: 123,
+		Tracks: []model.Track{
+			{Id: 1, AlbumId: 123, DiscNumber: 1, TrackNumber: 1},
+			{Id: 2, AlbumId: 123, DiscNumber: 1, TrackNumber: 2},
+			{Id: 3, AlbumId: 123, DiscNumber: 1, TrackNumber: 3},
+		},
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&album, nil).
+		AnyTimes()
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	httpClient := http.DefaultClient
+
+	at := func(id int64, disc uint, number uint) model.TrackPosition {
+		return model.TrackPosition{Id: id, DiscNumber: disc, TrackNumber: number}
+	}
+
+	for _, row := range []struct {
+		Order    []model.TrackPosition
+		Expected string
+	}{
+		{
+			[]model.TrackPosition{at(1, 1, 1), at(2, 1, 2), at(3, 1, 3), at(4, 1, 4)},
+			"Track 4 does not belong to this album.",
+		},
+		{
+			[]model.TrackPosition{at(1, 1, 1), at(2, 1, 2), at(2, 1, 3)},
+			"Track 2 appears more than once.",
+		},
+		{
+			[]model.TrackPosition{at(1, 1, 1), at(2, 1, 2)},
+			"Track 3 is missing from the new order.",
+		},
+		{
+			[]model.TrackPosition{at(1, 1, 1), at(2, 1, 2), at(3, 1, 0)},
+			"Track 3 must have a disc and track number of at least 1.",
+		},
+		{
+			[]model.TrackPosition{at(1, 1, 1), at(2, 1, 2), at(3, 1, 2)},
+			"Disc 1 has more than one track at position 2.",
+		},
+		{
+			[]model.TrackPosition{at(1, 1, 1), at(2, 1, 2), at(3, 1, 4)},
+			"Disc 1 is missing position 3.",
+		},
+		{
+			[]model.TrackPosition{at(1, 1, 1), at(2, 1, 2), at(3, 3, 1)},
+			"Disc 2 is missing.",
+		},
+	} {
+		body, err := json.Marshal(row.Order)
+		suite.Nil(err)
+
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/123/tracks", bytes.NewBuffer(body))
+		suite.Nil(err)
+
+		resp, err := httpClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(http.StatusBadRequest, resp.StatusCode)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(row.Expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestReorderAlbumTracksConcurrentChange() {
+	defer suite.ctrl.Finish()
+
+	order := []model.TrackPosition{{Id: 1, DiscNumber: 1, TrackNumber: 1}}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, Tracks: []model.Track{
+			{Id: 1, AlbumId: 123, DiscNumber: 1, TrackNumber: 1},
+		}}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Reorder(gomock.Any(), gomock.Eq(int64(123)), gomock.Eq(order)).
+		Return(fmt.Errorf("%w: the tracks on album 123 have changed", dao.ErrConflict)).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+		Track: mockTrackDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	body, err := json.Marshal(order)
+	suite.Nil(err)
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/123/tracks", bytes.NewBuffer(body))
+	suite.Nil(err)
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody := application.Problem{}
+	suite.Nil(json.NewDecoder(resp.Body).Decode(&retBody))
+	suite.Equal(application.ProblemConcurrentChange, retBody.Code)
+}
+
+func (suite *AppSuite) TestReorderAlbumTracksBadJson() {
+	defer suite.ctrl.Finish()
+
+	server := server.NewServer(suite.cfg)
+	app := application.NewApp(withTestSession(suite.ctrl, db.DatabaseClient{}), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for body, code := range map[string]application.ProblemCode{
+		"not json":                      application.ProblemInvalidJson,
+		"[{\"id\":1,\"disc\":\"one\"}]": application.ProblemValidationFailed,
+	} {
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/123/tracks", bytes.NewBufferString(body))
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal("application/problem+json", resp.Header.Get("Content-Type"), body)
+
+		retBody := application.Problem{}
+		suite.Nil(json.NewDecoder(resp.Body).Decode(&retBody))
+		resp.Body.Close()
+		suite.Equal(code, retBody.Code, body)
+	}
+}
+
+func (suite *AppSuite) TestReorderAlbumTracksNotFound() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("[{\"id\":1,\"disc\":1,\"number\":1}]")
+
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/album/123/tracks", buffer)
+	suite.Nil(err)
+
+	httpClient := http.DefaultClient
+
+	resp, err := httpClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Album not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestChangeAlbumState() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, Title: "Waffle Irons", State: model.AlbumApproved}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().
+		SetState(gomock.Any(), gomock.Eq(model.AlbumStateChange{
+			AlbumId:   123,
+			From:      model.AlbumApproved,
+			To:        model.AlbumPublished,
+			ChangedBy: "James",
+		})).
+		Return(nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"state\":\"published\"}")
+	resp, err := http.Post("http://localhost:8080/api/v1/album/123/state", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("{\"id\":123,\"title\":\"Waffle Irons\",\"artist\":{\"id\":0,\"name\":\"\"},\"tracks\":null,\"state\":\"published\",\"rating\":0,\"version\":0,\"commentCount\":0,\"published\":true}", string(retBody))
+}
+
+func (suite *AppSuite) TestChangeAlbumStateInvalidTransition() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, State: model.AlbumDraft}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"state\":\"published\"}")
+	resp, err := http.Post("http://localhost:8080/api/v1/album/123/state", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Cannot move album from draft to published. Allowed: in_review, archived.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestChangeAlbumStateConcurrentChange() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, State: model.AlbumDraft}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().
+		SetState(gomock.Any(), gomock.Any()).
+		Return(fmt.Errorf("%w: album 123 is no longer draft", dao.ErrConflict)).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"state\":\"in_review\"}")
+	resp, err := http.Post("http://localhost:8080/api/v1/album/123/state", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Album was changed by someone else, please try again.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestChangeAlbumStateInvalidRequest() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(404))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		url      string
+		body     string
+		status   int
+		expected string
+	}{
+		{"123", "{\"state\":\"released\"}", http.StatusBadRequest, "Invalid state provided. Must be one of draft, in_review, approved, scheduled, published, archived."},
+		{"123", "not json", http.StatusBadRequest, "Invalid state change provided."},
+		{"404", "{\"state\":\"approved\"}", http.StatusNotFound, "Album not found."},
+	} {
+		buffer := bytes.NewBufferString(test.body)
+		resp, err := http.Post("http://localhost:8080/api/v1/album/"+test.url+"/state", "application/json", buffer)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestRetrieveAlbumHistory() {
+	defer suite.ctrl.Finish()
+
+	history := []model.AlbumStateChange{
+		{
+			Id:        1,
+			AlbumId:   123,
+			From:      model.AlbumDraft,
+			To:        model.AlbumInReview,
+			ChangedBy: "James",
+			ChangedAt: time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC),
+		},
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, State: model.AlbumInReview}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().
+		LoadHistory(gomock.Any(), gomock.Eq(int64(123))).
+		Return(history, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album: mockAlbumDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/album/123/history")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("[{\"id\":1,\"album\":123,\"from\":\"draft\",\"to\":\"in_review\",\"changedBy\":\"James\",\"changedAt\":\"2026-10-18T09:30:00Z\"}]", string(retBody))
+}
+
+func (suite *AppSuite) TestRetrieveAlbumProposals() {
+	defer suite.ctrl.Finish()
+
+	proposals := []model.TitleProposal{
+		{Id: 2, Target: model.TargetAlbum, TargetId: 123, Title: "Pancake Irons", ProposedBy: "James", Status: model.ProposalOpen, Score: 2},
+		{Id: 1, Target: model.TargetAlbum, TargetId: 123, Title: "Crepe Irons", ProposedBy: "Bobby", Status: model.ProposalOpen, Score: -1},
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, Title: "Waffle Irons"}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(404))).
+		Return(nil, dao.ErrNotFound).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockProposalDao := mock.NewMockProposalDao(suite.ctrl)
+	mockProposalDao.EXPECT().
+		LoadFor(gomock.Any(), gomock.Eq(model.TargetAlbum), gomock.Eq(int64(123))).
+		Return(proposals, nil).
+		Times(1)
+	mockProposalDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:    mockAlbumDao,
+		Proposal: mockProposalDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/album/123/proposals")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retProposals := []model.TitleProposal{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retProposals))
+	suite.Equal(proposals, retProposals)
+
+	resp, err = http.Get("http://localhost:8080/api/v1/album/404/proposals")
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err = ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Album not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestCreateTrackProposal() {
+	defer suite.ctrl.Finish()
+
+	created := model.TitleProposal{
+		Id:         7,
+		Target:     model.TargetTrack,
+		TargetId:   12,
+		Title:      "Pancake Irons",
+		ProposedBy: "James",
+		Status:     model.ProposalOpen,
+		CreatedAt:  time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC),
+	}
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(12))).
+		Return(&model.Track{Id: 12, Title: "Waffle Irons"}, nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	mockProposalDao := mock.NewMockProposalDao(suite.ctrl)
+	mockProposalDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(model.TitleProposal{
+			Target:     model.TargetTrack,
+			TargetId:   12,
+			Title:      "Pancake Irons",
+			ProposedBy: "James",
+			Status:     model.ProposalOpen,
+		})).
+		Return(int64(7), nil).
+		Times(1)
+	mockProposalDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&created, nil).
+		Times(1)
+	mockProposalDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track:    mockTrackDao,
+		Proposal: mockProposalDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"title\":\"Pancake Irons\",\"proposedBy\":\"Bobby\",\"status\":\"accepted\"}")
+	resp, err := http.Post("http://localhost:8080/api/v1/track/12/proposals", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retProposal := model.TitleProposal{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retProposal))
+	suite.Equal(created, retProposal)
+}
+
+func (suite *AppSuite) TestCreateProposalInvalid() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123, Title: "Waffle Irons"}, nil).
+		AnyTimes()
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(404))).
+		Return(nil, dao.ErrNotFound).
+		AnyTimes()
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockProposalDao := mock.NewMockProposalDao(suite.ctrl)
+	mockProposalDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:    mockAlbumDao,
+		Proposal: mockProposalDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		url      string
+		body     string
+		status   int
+		expected string
+	}{
+		{"123", "{}", http.StatusUnprocessableEntity, "Invalid fields provided: title must not be empty."},
+		{"123", "{\"title\":\"  \"}", http.StatusUnprocessableEntity, "Invalid fields provided: title must not be empty."},
+		{"123", "{\"title\":\"" + strings.Repeat("a", 256) + "\"}", http.StatusUnprocessableEntity, "Invalid fields provided: title must be at most 255 characters."},
+		{"123", "{\"title\":\"Waffle Irons\"}", http.StatusBadRequest, "The album is already titled \"Waffle Irons\"."},
+		{"404", "{\"title\":\"Pancake Irons\"}", http.StatusNotFound, "Album not found."},
+	} {
+		buffer := bytes.NewBufferString(test.body)
+		resp, err := http.Post("http://localhost:8080/api/v1/album/"+test.url+"/proposals", "application/json", buffer)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestVoteOnProposal() {
+	defer suite.ctrl.Finish()
+
+	proposal := model.TitleProposal{
+		Id:         7,
+		Target:     model.TargetAlbum,
+		TargetId:   123,
+		Title:      "Pancake Irons",
+		ProposedBy: "James",
+		Status:     model.ProposalOpen,
+	}
+	voted := proposal
+	voted.Score = 1
+
+	mockProposalDao := mock.NewMockProposalDao(suite.ctrl)
+	gomock.InOrder(
+		mockProposalDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(int64(7))).
+			Return(&proposal, nil),
+		mockProposalDao.EXPECT().
+			Vote(gomock.Any(), gomock.Eq(model.ProposalVote{ProposalId: 7, Voter: "James", Vote: 1})).
+			Return(nil),
+		mockProposalDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(int64(7))).
+			Return(&voted, nil),
+	)
+	mockProposalDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Proposal: mockProposalDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"vote\":1}")
+	resp, err := http.Post("http://localhost:8080/api/v1/album/123/proposals/7/votes", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retProposal := model.TitleProposal{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retProposal))
+	suite.Equal(voted, retProposal)
+}
+
+func (suite *AppSuite) TestVoteOnProposalInvalid() {
+	defer suite.ctrl.Finish()
+
+	mockProposalDao := mock.NewMockProposalDao(suite.ctrl)
+	mockProposalDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&model.TitleProposal{Id: 7, Target: model.TargetAlbum, TargetId: 123, Status: model.ProposalOpen}, nil).
+		AnyTimes()
+	mockProposalDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(8))).
+		Return(&model.TitleProposal{Id: 8, Target: model.TargetAlbum, TargetId: 123, Status: model.ProposalAccepted}, nil).
+		AnyTimes()
+	mockProposalDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(9))).
+		Return(nil, fmt.Errorf("%w: proposal 9", dao.ErrNotFound)).
+		AnyTimes()
+	mockProposalDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(10))).
+		Return(nil, fmt.Errorf("Unable to load proposal 10: %w", dao.ErrUnavailable)).
+		AnyTimes()
+	mockProposalDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Proposal: mockProposalDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		url      string
+		body     string
+		status   int
+		expected string
+	}{
+		{"album/123/proposals/7", "{\"vote\":2}", http.StatusBadRequest, "Invalid vote provided. Must be 1 or -1."},
+		{"album/123/proposals/cats", "{\"vote\":1}", http.StatusBadRequest, "Invalid proposal ID provided. Must be an integer."},
+		{"album/124/proposals/7", "{\"vote\":1}", http.StatusNotFound, "Proposal not found."},
+		{"track/123/proposals/7", "{\"vote\":1}", http.StatusNotFound, "Proposal not found."},
+		{"album/123/proposals/8", "{\"vote\":1}", http.StatusConflict, "Proposal has already been accepted."},
+		{"album/123/proposals/9", "{\"vote\":1}", http.StatusNotFound, "Proposal not found."},
+		{"album/123/proposals/10", "{\"vote\":1}", http.StatusServiceUnavailable, "The database is unavailable, please try again later."},
+	} {
+		buffer := bytes.NewBufferString(test.body)
+		resp, err := http.Post("http://localhost:8080/api/v1/"+test.url+"/votes", "application/json", buffer)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.url)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody), test.url)
+	}
+}
+
+func (suite *AppSuite) TestAcceptProposal() {
+	defer suite.ctrl.Finish()
+
+	proposal := model.TitleProposal{
+		Id:         7,
+		Target:     model.TargetTrack,
+		TargetId:   12,
+		Title:      "Pancake Irons",
+		ProposedBy: "James",
+		Status:     model.ProposalOpen,
+	}
+	accepted := proposal
+	accepted.Status = model.ProposalAccepted
+
+	mockProposalDao := mock.NewMockProposalDao(suite.ctrl)
+	gomock.InOrder(
+		mockProposalDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(int64(7))).
+			Return(&proposal, nil),
+		mockProposalDao.EXPECT().
+			Accept(gomock.Any(), gomock.Eq(int64(7))).
+			Return(nil),
+		mockProposalDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(int64(7))).
+			Return(&accepted, nil),
+	)
+	mockProposalDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Proposal: mockProposalDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Post("http://localhost:8080/api/v1/track/12/proposals/7/accept", "application/json", nil)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retProposal := model.TitleProposal{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retProposal))
+	suite.Equal(accepted, retProposal)
+}
+
+func (suite *AppSuite) TestAcceptProposalConflict() {
+	defer suite.ctrl.Finish()
+
+	mockProposalDao := mock.NewMockProposalDao(suite.ctrl)
+	mockProposalDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&model.TitleProposal{Id: 7, Target: model.TargetTrack, TargetId: 12, Status: model.ProposalOpen}, nil).
+		Times(1)
+	mockProposalDao.EXPECT().
+		Accept(gomock.Any(), gomock.Eq(int64(7))).
+		Return(fmt.Errorf("%w: proposal 7 is already rejected", dao.ErrConflict)).
+		Times(1)
+	mockProposalDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Proposal: mockProposalDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Post("http://localhost:8080/api/v1/track/12/proposals/7/accept", "application/json", nil)
+	suite.Nil(err)
+	suite.Equal(http.StatusConflict, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Proposal was closed by someone else.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRetrieveAlbumComments() {
+	defer suite.ctrl.Finish()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	parent := func(id int64) *int64 { return &id }
+	comments := []model.Comment{
+		{Id: 1, Target: model.TargetAlbum, TargetId: 123, Author: "James", Body: "Needs a new title", CreatedAt: createdAt},
+		{Id: 2, Target: model.TargetAlbum, TargetId: 123, Author: "Bobby", CreatedAt: createdAt, DeletedAt: &createdAt},
+		{Id: 3, Target: model.TargetAlbum, TargetId: 123, ParentId: parent(1), Author: "Bobby", Body: "Agreed", CreatedAt: createdAt},
+		{Id: 4, Target: model.TargetAlbum, TargetId: 123, Author: "Frank", CreatedAt: createdAt, DeletedAt: &createdAt},
+		{Id: 5, Target: model.TargetAlbum, TargetId: 123, ParentId: parent(2), Author: "Frank", Body: "What?", CreatedAt: createdAt},
+	}
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123}, nil).
+		Times(1)
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		LoadFor(gomock.Any(), gomock.Eq(model.TargetAlbum), gomock.Eq(int64(123))).
+		Return(comments, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:   mockAlbumDao,
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/album/123/comments")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retComments := []model.Comment{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retComments))
+
+	// The second deleted comment has no replies, so it is left out.
+	suite.Len(retComments, 2)
+	suite.Equal(int64(1), retComments[0].Id)
+	suite.Len(retComments[0].Replies, 1)
+	suite.Equal("Agreed", retComments[0].Replies[0].Body)
+	suite.Equal(int64(2), retComments[1].Id)
+	suite.NotNil(retComments[1].DeletedAt)
+	suite.Len(retComments[1].Replies, 1)
+	suite.Equal(int64(5), retComments[1].Replies[0].Id)
+	suite.Len(retComments[1].Replies[0].Replies, 0)
+}
+
+func (suite *AppSuite) TestCreateTrackComment() {
+	defer suite.ctrl.Finish()
+
+	parentId := int64(3)
+	created := model.Comment{
+		Id:        7,
+		Target:    model.TargetTrack,
+		TargetId:  12,
+		ParentId:  &parentId,
+		Author:    "James",
+		Body:      "Louder in the **chorus**",
+		CreatedAt: time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC),
+	}
+
+	mockTrackDao := mock.NewMockTrackDao(suite.ctrl)
+	mockTrackDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(12))).
+		Return(&model.Track{Id: 12}, nil).
+		Times(1)
+	mockTrackDao.EXPECT().Close().Times(1)
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(3))).
+		Return(&model.Comment{Id: 3, Target: model.TargetTrack, TargetId: 12}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().
+		Save(gomock.Any(), gomock.Eq(model.Comment{
+			Target:   model.TargetTrack,
+			TargetId: 12,
+			ParentId: &parentId,
+			Author:   "James",
+			Body:     "Louder in the **chorus**",
+		})).
+		Return(int64(7), nil).
+		Times(1)
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&created, nil).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Track:   mockTrackDao,
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"parent\":3,\"author\":\"Bobby\",\"body\":\"Louder in the **chorus**\",\"resolved\":true}")
+	resp, err := http.Post("http://localhost:8080/api/v1/track/12/comments", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retComment := model.Comment{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &retComment))
+	suite.Equal(created, retComment)
+}
+
+func (suite *AppSuite) TestCreateCommentInvalid() {
+	defer suite.ctrl.Finish()
+
+	mockAlbumDao := mock.NewMockAlbumDao(suite.ctrl)
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(123))).
+		Return(&model.Album{Id: 123}, nil).
+		AnyTimes()
+	mockAlbumDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(404))).
+		Return(nil, dao.ErrNotFound).
+		AnyTimes()
+	mockAlbumDao.EXPECT().Close().Times(1)
+
+	deletedAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(1))).
+		Return(&model.Comment{Id: 1, Target: model.TargetTrack, TargetId: 123}, nil).
+		AnyTimes()
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(2))).
+		Return(&model.Comment{Id: 2, Target: model.TargetAlbum, TargetId: 123, DeletedAt: &deletedAt}, nil).
+		AnyTimes()
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(3))).
+		Return(nil, fmt.Errorf("%w: comment 3", dao.ErrNotFound)).
+		AnyTimes()
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(4))).
+		Return(nil, fmt.Errorf("Unable to load comment 4: %w", dao.ErrUnavailable)).
+		AnyTimes()
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Album:   mockAlbumDao,
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		url      string
+		body     string
+		status   int
+		expected string
+	}{
+		{"123", "{}", http.StatusUnprocessableEntity, "Invalid fields provided: body must not be empty."},
+		{"123", "{\"body\":\" \\n \"}", http.StatusUnprocessableEntity, "Invalid fields provided: body must not be empty."},
+		{"123", "{\"body\":\"" + strings.Repeat("a", 65536) + "\"}", http.StatusUnprocessableEntity, "Invalid fields provided: body must be at most 65535 bytes."},
+		{"404", "{\"body\":\"Hi\"}", http.StatusNotFound, "Album not found."},
+		{"123", "{\"body\":\"Hi\",\"parent\":1}", http.StatusBadRequest, "Invalid parent provided. Replies must be left on the same album or track."},
+		{"123", "{\"body\":\"Hi\",\"parent\":2}", http.StatusBadRequest, "Invalid parent provided. Comment does not exist."},
+		{"123", "{\"body\":\"Hi\",\"parent\":3}", http.StatusBadRequest, "Invalid parent provided. Comment does not exist."},
+		{"123", "{\"body\":\"Hi\",\"parent\":4}", http.StatusServiceUnavailable, "The database is unavailable, please try again later."},
+	} {
+		buffer := bytes.NewBufferString(test.body)
+		resp, err := http.Post("http://localhost:8080/api/v1/album/"+test.url+"/comments", "application/json", buffer)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody), test.body)
+	}
+}
+
+func (suite *AppSuite) TestUpdateComment() {
+	defer suite.ctrl.Finish()
+
+	comment := model.Comment{Id: 7, Target: model.TargetAlbum, TargetId: 123, Author: "James", Body: "Tpyo"}
+
+	var saved model.Comment
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	gomock.InOrder(
+		mockCommentDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(int64(7))).
+			Return(&comment, nil),
+		mockCommentDao.EXPECT().
+			Save(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, comment model.Comment) (int64, error) {
+				saved = comment
+				return comment.Id, nil
+			}),
+		mockCommentDao.EXPECT().
+			Load(gomock.Any(), gomock.Eq(int64(7))).
+			DoAndReturn(func(_ context.Context, _ int64) (*model.Comment, error) {
+				return &saved, nil
+			}),
+	)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"body\":\"Typo\",\"resolved\":true}")
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/comment/7", buffer)
+	suite.Nil(err)
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	suite.Equal("Typo", saved.Body)
+	suite.True(saved.Resolved)
+	suite.NotNil(saved.EditedAt)
+	suite.Nil(saved.DeletedAt)
+}
+
+func (suite *AppSuite) TestUpdateCommentInvalid() {
+	defer suite.ctrl.Finish()
+
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&model.Comment{Id: 7, Target: model.TargetAlbum, TargetId: 123, Author: "James", Body: "Tpyo"}, nil).
+		AnyTimes()
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		body     string
+		expected string
+	}{
+		{"{\"body\":\"\"}", "Invalid fields provided: body must not be empty."},
+		{"{\"body\":\"   \"}", "Invalid fields provided: body must not be empty."},
+		{"{\"body\":\"" + strings.Repeat("a", 65536) + "\"}", "Invalid fields provided: body must be at most 65535 bytes."},
+	} {
+		buffer := bytes.NewBufferString(test.body)
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/comment/7", buffer)
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode, test.expected)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestRemoveComment() {
+	defer suite.ctrl.Finish()
+
+	deletedAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+
+	var saved model.Comment
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&model.Comment{Id: 7, Target: model.TargetAlbum, TargetId: 123, Author: "James", Body: "Oops"}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(8))).
+		Return(&model.Comment{Id: 8, DeletedAt: &deletedAt}, nil).
+		Times(1)
+	mockCommentDao.EXPECT().
+		Save(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, comment model.Comment) (int64, error) {
+			saved = comment
+			return comment.Id, nil
+		}).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/comment/7", nil)
+	suite.Nil(err)
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	suite.Equal("", saved.Body)
+	suite.NotNil(saved.DeletedAt)
+
+	req, err = http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/comment/8", nil)
+	suite.Nil(err)
+
+	resp, err = http.DefaultClient.Do(req)
+	suite.Nil(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("Comment not found.", problemDetail(retBody))
+}
+
+func (suite *AppSuite) TestRequiresLogin() {
+	defer suite.ctrl.Finish()
+
+	wd, err := os.Getwd()
+	suite.Nil(err)
+	suite.cfg.ServerFilePath = wd + "/../server/testcontents"
+
+	expired := time.Now().Add(-time.Hour)
+	mockSessionDao := mock.NewMockSessionDao(suite.ctrl)
+	mockSessionDao.EXPECT().
+		Load(gomock.Eq(auth.SessionId("unknown"))).
+		Return(nil).
+		Times(1)
+	mockSessionDao.EXPECT().
+		Load(gomock.Eq(auth.SessionId("expired"))).
+		Return(&model.Session{Id: auth.SessionId("expired"), UserId: 1, ExpiresAt: expired}).
+		Times(1)
+	mockSessionDao.EXPECT().
+		Delete(gomock.Eq(auth.SessionId("expired"))).
+		Return(int64(1), nil).
+		Times(1)
+	mockSessionDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Session: mockSessionDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, token := range []string{"", "unknown", "expired"} {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/v1/album", nil)
+		suite.Nil(err)
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: token})
+		}
+
+		resp, err := (&http.Client{}).Do(req)
+		suite.Nil(err)
+		suite.Equal(http.StatusUnauthorized, resp.StatusCode, token)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal("You must be logged in.", problemDetail(retBody))
+	}
+
+	resp, err := (&http.Client{}).Get("http://localhost:8080/index.html")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func (suite *AppSuite) TestLogin() {
+	defer suite.ctrl.Finish()
+
+	hash, err := auth.HashPassword("correct horse")
+	suite.Nil(err)
+
+	user := model.User{
+		Id:           2,
+		Username:     "Bobby",
+		PasswordHash: hash,
+		Role:         model.RoleEditor,
+	}
+
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		LoadByUsername(gomock.Eq("Bobby")).
+		Return(&user).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	var saved model.Session
+	mockSessionDao := mock.NewMockSessionDao(suite.ctrl)
+	mockSessionDao.EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(session model.Session) error {
+			saved = session
+			return nil
+		}).
+		Times(1)
+	mockSessionDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User:    mockUserDao,
+		Session: mockSessionDao,
+	}
+
+	app := application.NewApp(dbClient, server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"username\":\"Bobby\",\"password\":\"correct horse\"}")
+	resp, err := http.Post("http://localhost:8080/api/v1/auth/login", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("{\"id\":2,\"username\":\"Bobby\",\"role\":\"editor\",\"createdAt\":\"0001-01-01T00:00:00Z\"}", string(retBody))
+
+	cookies := resp.Cookies()
+	suite.Len(cookies, 1)
+	suite.Equal(auth.SessionCookieName, cookies[0].Name)
+	suite.True(cookies[0].HttpOnly)
+	suite.True(cookies[0].Secure)
+	suite.Equal(http.SameSiteLaxMode, cookies[0].SameSite)
+
+	suite.Equal(auth.SessionId(cookies[0].Value), saved.Id)
+	suite.Equal(user.Id, saved.UserId)
+	suite.WithinDuration(time.Now().Add(application.SessionLifetime), saved.ExpiresAt, time.Minute)
+}
+
+func (suite *AppSuite) TestLoginInvalid() {
+	defer suite.ctrl.Finish()
+
+	hash, err := auth.HashPassword("correct horse")
+	suite.Nil(err)
+
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		LoadByUsername(gomock.Eq("Bobby")).
+		Return(&model.User{Id: 2, Username: "Bobby", PasswordHash: hash}).
+		AnyTimes()
+	mockUserDao.EXPECT().
+		LoadByUsername(gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	mockUserDao.EXPECT().Close().Times(1)
+
+	mockSessionDao := mock.NewMockSessionDao(suite.ctrl)
+	mockSessionDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User:    mockUserDao,
+		Session: mockSessionDao,
+	}
+
+	app := application.NewApp(dbClient, server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, body := range []string{
+		"{\"username\":\"Bobby\",\"password\":\"battery staple\"}",
+		"{\"username\":\"Nobody\",\"password\":\"correct horse\"}",
+		"{\"username\":\"Bobby\"}",
+		"not json",
+	} {
+		buffer := bytes.NewBufferString(body)
+		resp, err := http.Post("http://localhost:8080/api/v1/auth/login", "application/json", buffer)
+		suite.Nil(err)
+		suite.Equal(http.StatusUnauthorized, resp.StatusCode, body)
+		suite.Len(resp.Cookies(), 0)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal("Invalid username or password.", problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestLogout() {
+	defer suite.ctrl.Finish()
+
+	mockSessionDao := mock.NewMockSessionDao(suite.ctrl)
+	mockSessionDao.EXPECT().
+		Delete(gomock.Eq(auth.SessionId(testSessionToken))).
+		Return(int64(1), nil).
+		Times(1)
+	mockSessionDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Session: mockSessionDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Post("http://localhost:8080/api/v1/auth/logout", "application/json", nil)
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	cookies := resp.Cookies()
+	suite.Len(cookies, 1)
+	suite.Equal(auth.SessionCookieName, cookies[0].Name)
+	suite.Equal("", cookies[0].Value)
+	suite.Equal(-1, cookies[0].MaxAge)
+}
+
+func (suite *AppSuite) TestRetrieveSessionUser() {
+	defer suite.ctrl.Finish()
+
+	server := server.NewServer(suite.cfg)
+	app := application.NewApp(withTestSession(suite.ctrl, db.DatabaseClient{}), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/auth/session")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("{\"id\":1,\"username\":\"James\",\"role\":\"admin\",\"createdAt\":\"0001-01-01T00:00:00Z\"}", string(retBody))
+}
+
+func (suite *AppSuite) TestChangePassword() {
+	defer suite.ctrl.Finish()
+
+	hash, err := auth.HashPassword("correct horse")
+	suite.Nil(err)
+
+	user := model.User{
+		Id:           testUser.Id,
+		Username:     testUser.Username,
+		PasswordHash: hash,
+	}
+
+	var saved model.User
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(testUser.Id)).
+		Return(&user).
+		AnyTimes()
+	mockUserDao.EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(user model.User) (int64, error) {
+			saved = user
+			return user.Id, nil
+		}).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User: mockUserDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		body     string
+		status   int
+		expected string
+	}{
+		{"{\"current\":\"battery staple\",\"new\":\"battery staple\"}", http.StatusBadRequest, "Current password is incorrect."},
+		{"{\"current\":\"correct horse\",\"new\":\"short\"}", http.StatusBadRequest, "Passwords must be at least 8 characters long."},
+		{"{\"current\":\"correct horse\",\"new\":\"battery staple\"}", http.StatusOK, ""},
+	} {
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/auth/password", bytes.NewBufferString(test.body))
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+
+	suite.Equal(testUser.Username, saved.Username)
+	suite.True(auth.CheckPassword(saved.PasswordHash, "battery staple"))
+}
+
+func (suite *AppSuite) TestCreateUser() {
+	defer suite.ctrl.Finish()
+
+	var saved model.User
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(testUser.Id)).
+		Return(&testUser).
+		AnyTimes()
+	mockUserDao.EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(user model.User) (int64, error) {
+			saved = user
+			return int64(2), nil
+		}).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User: mockUserDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"username\":\"Bobby\",\"password\":\"correct horse\",\"role\":\"contributor\"}")
+	resp, err := http.Post("http://localhost:8080/api/v1/user", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	defer resp.Body.Close()
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Equal("{\"id\":2,\"username\":\"Bobby\",\"role\":\"contributor\",\"createdAt\":\"0001-01-01T00:00:00Z\"}", string(retBody))
+
+	suite.Equal("Bobby", saved.Username)
+	suite.Equal(model.RoleContributor, saved.Role)
+	suite.True(auth.CheckPassword(saved.PasswordHash, "correct horse"))
+}
+
+func (suite *AppSuite) TestCreateUserInvalid() {
+	defer suite.ctrl.Finish()
+
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(testUser.Id)).
+		Return(&testUser).
+		AnyTimes()
+	mockUserDao.EXPECT().
+		Save(gomock.Any()).
+		Return(int64(0), fmt.Errorf("%w: user", dao.ErrDuplicate)).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User: mockUserDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		body     string
+		status   int
+		expected string
+	}{
+		{"{\"password\":\"correct horse\"}", http.StatusBadRequest, "Users must have a username."},
+		{"{\"username\":\"Bobby\",\"password\":\"short\"}", http.StatusBadRequest, "Passwords must be at least 8 characters long."},
+		{"{\"username\":\"Bobby\",\"password\":\"correct horse\",\"role\":\"owner\"}", http.StatusBadRequest, "Invalid role provided. Must be one of viewer, contributor, editor, admin."},
+		{"{\"username\":\"James\",\"password\":\"correct horse\"}", http.StatusConflict, "A user with that username already exists."},
+	} {
+		buffer := bytes.NewBufferString(test.body)
+		resp, err := http.Post("http://localhost:8080/api/v1/user", "application/json", buffer)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestRetrieveUsers() {
+	defer suite.ctrl.Finish()
+
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(testUser.Id)).
+		Return(&testUser).
+		AnyTimes()
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(int64(404))).
+		Return(nil).
+		Times(1)
+	mockUserDao.EXPECT().
+		LoadAll().
+		Return([]model.User{testUser, {Id: 2, Username: "Bobby", PasswordHash: "secret", Role: model.RoleViewer}}).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User: mockUserDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		url      string
+		status   int
+		expected string
+	}{
+		{"user", http.StatusOK, "[{\"id\":1,\"username\":\"James\",\"role\":\"admin\",\"createdAt\":\"0001-01-01T00:00:00Z\"},{\"id\":2,\"username\":\"Bobby\",\"role\":\"viewer\",\"createdAt\":\"0001-01-01T00:00:00Z\"}]"},
+		{"user/1", http.StatusOK, "{\"id\":1,\"username\":\"James\",\"role\":\"admin\",\"createdAt\":\"0001-01-01T00:00:00Z\"}"},
+		{"user/404", http.StatusNotFound, "User not found."},
+	} {
+		resp, err := http.Get("http://localhost:8080/api/v1/" + test.url)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.url)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestRemoveUser() {
+	defer suite.ctrl.Finish()
+
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(testUser.Id)).
+		Return(&testUser).
+		AnyTimes()
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(int64(2))).
+		Return(&model.User{Id: 2, Username: "Bobby", Role: model.RoleViewer}).
+		Times(1)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(int64(3))).
+		Return(&model.User{Id: 3, Username: "Frank", Role: model.RoleAdmin}).
+		Times(2)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(int64(404))).
+		Return(nil).
+		Times(1)
+	gomock.InOrder(
+		mockUserDao.EXPECT().
+			CountByRole(gomock.Eq(model.RoleAdmin)).
+			Return(int64(1), nil),
+		mockUserDao.EXPECT().
+			CountByRole(gomock.Eq(model.RoleAdmin)).
+			Return(int64(2), nil),
+	)
+	mockUserDao.EXPECT().
+		Delete(gomock.Eq(model.User{Id: 2})).
+		Return(int64(1), nil).
+		Times(1)
+	mockUserDao.EXPECT().
+		Delete(gomock.Eq(model.User{Id: 3})).
+		Return(int64(1), nil).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User: mockUserDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		url      string
+		status   int
+		expected string
+	}{
+		{"2", http.StatusOK, ""},
+		{"404", http.StatusNotFound, "User not found."},
+		{fmt.Sprint(testUser.Id), http.StatusConflict, "You cannot remove yourself."},
+		{"3", http.StatusConflict, "You cannot remove the last admin."},
+		{"3", http.StatusOK, ""},
+	} {
+		req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/user/"+test.url, nil)
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.url)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		if test.expected != "" {
+			suite.Equal(test.expected, problemDetail(retBody), test.url)
+		}
+	}
+}
+
+func (suite *AppSuite) TestEnsureUser() {
+	defer suite.ctrl.Finish()
+
+	var saved model.User
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		LoadByUsername(gomock.Eq("James")).
+		Return(&testUser).
+		Times(1)
+	mockUserDao.EXPECT().
+		LoadByUsername(gomock.Eq("Frank")).
+		Return(&model.User{Id: 3, Username: "Frank", Role: model.RoleViewer}).
+		Times(1)
+	mockUserDao.EXPECT().
+		Save(gomock.Eq(model.User{Id: 3, Username: "Frank", Role: model.RoleAdmin})).
+		Return(int64(3), nil).
+		Times(1)
+	mockUserDao.EXPECT().
+		LoadByUsername(gomock.Eq("Bobby")).
+		Return(nil).
+		Times(2)
+	mockUserDao.EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(user model.User) (int64, error) {
+			saved = user
+			return int64(2), nil
+		}).
+		Times(1)
+
+	suite.Nil(application.EnsureUser(mockUserDao, "James", "correct horse", model.RoleAdmin))
+	suite.Nil(application.EnsureUser(mockUserDao, "Frank", "correct horse", model.RoleAdmin))
+	suite.Nil(application.EnsureUser(mockUserDao, "Bobby", "correct horse", model.RoleEditor))
+	suite.Equal("Bobby", saved.Username)
+	suite.Equal(model.RoleEditor, saved.Role)
+	suite.True(auth.CheckPassword(saved.PasswordHash, "correct horse"))
+
+	suite.Equal(auth.ErrPasswordTooShort, application.EnsureUser(mockUserDao, "Bobby", "short", model.RoleEditor))
+}
+
+func (suite *AppSuite) TestPermissionDenied() {
+	defer suite.ctrl.Finish()
+
+	viewer := model.User{Id: 2, Username: "Bobby", Role: model.RoleViewer}
+	contributor := model.User{Id: 3, Username: "Frank", Role: model.RoleContributor}
+	editor := model.User{Id: 4, Username: "Jayne", Role: model.RoleEditor}
+
+	for _, test := range []struct {
+		user     model.User
+		method   string
+		url      string
+		expected string
+	}{
+		{viewer, http.MethodPost, "album", "create albums"},
+		{viewer, http.MethodPut, "album/1", "edit albums"},
+		{viewer, http.MethodPost, "track", "create tracks"},
+		{viewer, http.MethodPut, "album/1/tracks", "edit tracks"},
+		{viewer, http.MethodPost, "artist", "create artists"},
+		{viewer, http.MethodPost, "album/1/comments", "comment"},
+		{viewer, http.MethodPut, "comment/1", "comment"},
+		{viewer, http.MethodDelete, "comment/1", "comment"},
+		{viewer, http.MethodPost, "track/1/proposals", "propose titles"},
+		{viewer, http.MethodPost, "track/1/proposals/1/votes", "vote on titles"},
+		{contributor, http.MethodDelete, "album/1", "remove albums"},
+		{contributor, http.MethodPost, "album/1/state", "change the state of albums"},
+		{contributor, http.MethodDelete, "track/1", "remove tracks"},
+		{contributor, http.MethodPut, "artist/1", "edit artists"},
+		{contributor, http.MethodDelete, "artist/1", "remove artists"},
+		{contributor, http.MethodPost, "album/1/proposals/1/accept", "accept title proposals"},
+		{editor, http.MethodGet, "user", "manage users"},
+		{editor, http.MethodPost, "user", "manage users"},
+		{editor, http.MethodGet, "user/1", "manage users"},
+		{editor, http.MethodDelete, "user/1", "manage users"},
+		{editor, http.MethodPut, "user/1/role", "manage users"},
+	} {
+		// Nothing past the permission check should be reached, so the only
+		// DAOs needed are the ones for logging in.
+		server := server.NewServer(suite.cfg)
+		app := application.NewApp(withTestSessionAs(suite.ctrl, db.DatabaseClient{}, test.user), server)
+		suite.NotNil(app)
+		app.Run()
+
+		req, err := http.NewRequest(test.method, "http://localhost:8080/api/v1/"+test.url, bytes.NewBufferString("{}"))
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(http.StatusForbidden, resp.StatusCode, test.url)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal("You are not allowed to "+test.expected+".", problemDetail(retBody), test.url)
+
+		app.Close()
+		http.DefaultClient.CloseIdleConnections()
+	}
+}
+
+func (suite *AppSuite) TestCommentOwnership() {
+	defer suite.ctrl.Finish()
+
+	contributor := model.User{Id: 3, Username: "Frank", Role: model.RoleContributor}
+
+	var saved model.Comment
+	mockCommentDao := mock.NewMockCommentDao(suite.ctrl)
+	mockCommentDao.EXPECT().
+		Load(gomock.Any(), gomock.Eq(int64(7))).
+		Return(&model.Comment{Id: 7, Target: model.TargetAlbum, TargetId: 123, Author: "James", Body: "Tpyo"}, nil).
+		AnyTimes()
+	mockCommentDao.EXPECT().
+		Save(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, comment model.Comment) (int64, error) {
+			saved = comment
+			return comment.Id, nil
+		}).
+		Times(1)
+	mockCommentDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		Comment: mockCommentDao,
+	}
+
+	app := application.NewApp(withTestSessionAs(suite.ctrl, dbClient, contributor), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		method string
+		body   string
+		status int
+	}{
+		{http.MethodPut, "{\"body\":\"Typo\"}", http.StatusForbidden},
+		{http.MethodDelete, "", http.StatusForbidden},
+		{http.MethodPut, "{\"body\":\"Tpyo\",\"resolved\":true}", http.StatusOK},
+	} {
+		req, err := http.NewRequest(test.method, "http://localhost:8080/api/v1/comment/7", bytes.NewBufferString(test.body))
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.method+" "+test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		if test.status == http.StatusForbidden {
+			suite.Equal("You are not allowed to change other people's comments.", problemDetail(retBody))
+		}
+	}
+
+	suite.Equal("Tpyo", saved.Body)
+	suite.True(saved.Resolved)
+	suite.Nil(saved.EditedAt)
+}
+
+func (suite *AppSuite) TestChangeUserRole() {
+	defer suite.ctrl.Finish()
+
+	var saved model.User
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(testUser.Id)).
+		Return(&testUser).
+		AnyTimes()
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(int64(2))).
+		Return(&model.User{Id: 2, Username: "Bobby", Role: model.RoleViewer}).
+		Times(1)
+	mockUserDao.EXPECT().
+		Load(gomock.Eq(int64(404))).
+		Return(nil).
+		Times(1)
+	mockUserDao.EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(user model.User) (int64, error) {
+			saved = user
+			return user.Id, nil
+		}).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User: mockUserDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		url      string
+		body     string
+		status   int
+		expected string
+	}{
+		{"2", "{\"role\":\"editor\"}", http.StatusOK, "{\"id\":2,\"username\":\"Bobby\",\"role\":\"editor\",\"createdAt\":\"0001-01-01T00:00:00Z\"}"},
+		{"2", "{\"role\":\"owner\"}", http.StatusBadRequest, "Invalid role provided. Must be one of viewer, contributor, editor, admin."},
+		{"1", "{\"role\":\"viewer\"}", http.StatusBadRequest, "You cannot change your own role."},
+		{"404", "{\"role\":\"viewer\"}", http.StatusNotFound, "User not found."},
+	} {
+		req, err := http.NewRequest(http.MethodPut, "http://localhost:8080/api/v1/user/"+test.url+"/role", bytes.NewBufferString(test.body))
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+
+	suite.Equal(model.RoleEditor, saved.Role)
+}
+
+func (suite *AppSuite) TestApiTokenLogin() {
+	defer suite.ctrl.Finish()
+
+	now := time.Now().UTC()
+	recent := now.Add(-time.Second)
+	contributor := model.User{Id: 3, Username: "Frank", Role: model.RoleContributor}
+
+	mockApiTokenDao := mock.NewMockApiTokenDao(suite.ctrl)
+	mockApiTokenDao.EXPECT().
+		LoadByHash(gomock.Eq(auth.ApiTokenHash("citadel_fresh"))).
+		Return(&model.ApiToken{Id: 7, UserId: 3, Scopes: []string{"tracks:create"}, ExpiresAt: now.Add(time.Hour)}).
+		AnyTimes()
+	mockApiTokenDao.EXPECT().
+		LoadByHash(gomock.Eq(auth.ApiTokenHash("citadel_recent"))).
+		Return(&model.ApiToken{Id: 8, UserId: 3, ExpiresAt: now.Add(time.Hour), LastUsedAt: &recent}).
+		AnyTimes()
+	mockApiTokenDao.EXPECT().
+		LoadByHash(gomock.Eq(auth.ApiTokenHash("citadel_expired"))).
+		Return(&model.ApiToken{Id: 9, UserId: 3, ExpiresAt: now.Add(-time.Hour)}).
+		AnyTimes()
+	mockApiTokenDao.EXPECT().
+		LoadByHash(gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	mockApiTokenDao.EXPECT().
+		Touch(gomock.Eq(int64(7)), gomock.Any()).
+		Return(nil).
+		MinTimes(1)
+	mockApiTokenDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		ApiToken: mockApiTokenDao,
+	}
+
+	app := application.NewApp(withTestSessionAs(suite.ctrl, dbClient, contributor), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		header   string
+		method   string
+		url      string
+		status   int
+		expected string
+	}{
+		{"Bearer citadel_fresh", http.MethodGet, "auth/session", http.StatusOK, "{\"id\":3,\"username\":\"Frank\",\"role\":\"contributor\",\"createdAt\":\"0001-01-01T00:00:00Z\"}"},
+		{"bearer citadel_recent", http.MethodGet, "auth/session", http.StatusOK, "{\"id\":3,\"username\":\"Frank\",\"role\":\"contributor\",\"createdAt\":\"0001-01-01T00:00:00Z\"}"},
+		{"Bearer citadel_fresh", http.MethodPost, "album", http.StatusForbidden, "This API token is not allowed to create albums."},
+		{"Bearer citadel_fresh", http.MethodDelete, "track/1", http.StatusForbidden, "You are not allowed to remove tracks."},
+		{"Bearer citadel_fresh", http.MethodGet, "auth/tokens", http.StatusForbidden, "API tokens cannot be used for this, please log in."},
+		{"Bearer citadel_fresh", http.MethodPut, "auth/password", http.StatusForbidden, "API tokens cannot be used for this, please log in."},
+		{"Bearer citadel_expired", http.MethodGet, "auth/session", http.StatusUnauthorized, "You must be logged in."},
+		{"Bearer citadel_unknown", http.MethodGet, "auth/session", http.StatusUnauthorized, "You must be logged in."},
+		{"Basic citadel_fresh", http.MethodGet, "auth/session", http.StatusUnauthorized, "You must be logged in."},
+	} {
+		req, err := http.NewRequest(test.method, "http://localhost:8080/api/v1/"+test.url, bytes.NewBufferString("{}"))
+		suite.Nil(err)
+		req.Header.Set("Authorization", test.header)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.header+" "+test.url)
+		suite.Equal("Bearer", resp.Header.Get("WWW-Authenticate"))
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody), test.header+" "+test.url)
+	}
+}
+
+func (suite *AppSuite) TestCreateApiToken() {
+	defer suite.ctrl.Finish()
+
+	contributor := model.User{Id: 3, Username: "Frank", Role: model.RoleContributor}
+
+	var saved model.ApiToken
+	mockApiTokenDao := mock.NewMockApiTokenDao(suite.ctrl)
+	mockApiTokenDao.EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(token model.ApiToken) (int64, error) {
+			saved = token
+			return int64(7), nil
+		}).
+		Times(1)
+	mockApiTokenDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		ApiToken: mockApiTokenDao,
+	}
+
+	app := application.NewApp(withTestSessionAs(suite.ctrl, dbClient, contributor), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	buffer := bytes.NewBufferString("{\"name\":\"CI\",\"scopes\":[\"tracks:create\"],\"days\":30}")
+	resp, err := http.Post("http://localhost:8080/api/v1/auth/tokens", "application/json", buffer)
+	suite.Nil(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	defer resp.Body.Close()
+
+	created := struct {
+		Id     int64    `json:"id"`
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		Token  string   `json:"token"`
+	}{}
+	retBody, err := ioutil.ReadAll(resp.Body)
+	suite.Nil(err)
+	suite.Nil(json.Unmarshal(retBody, &created))
+	suite.NotContains(string(retBody), saved.TokenHash)
+
+	suite.Equal(int64(7), created.Id)
+	suite.Equal("CI", created.Name)
+	suite.Equal([]string{"tracks:create"}, created.Scopes)
+	suite.Equal(auth.ApiTokenHash(created.Token), saved.TokenHash)
+	suite.Equal(contributor.Id, saved.UserId)
+	suite.WithinDuration(time.Now().AddDate(0, 0, 30), saved.ExpiresAt, time.Minute)
+}
+
+func (suite *AppSuite) TestCreateApiTokenInvalid() {
+	defer suite.ctrl.Finish()
+
+	contributor := model.User{Id: 3, Username: "Frank", Role: model.RoleContributor}
+
+	mockApiTokenDao := mock.NewMockApiTokenDao(suite.ctrl)
+	mockApiTokenDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		ApiToken: mockApiTokenDao,
+	}
+
+	app := application.NewApp(withTestSessionAs(suite.ctrl, dbClient, contributor), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	for _, test := range []struct {
+		body     string
+		status   int
+		expected string
+	}{
+		{"{\"scopes\":[]}", http.StatusBadRequest, "API tokens must have a name."},
+		{"{\"name\":\"CI\",\"days\":366}", http.StatusBadRequest, "Invalid lifetime provided. Must be between 1 and 365 days."},
+		{"{\"name\":\"CI\",\"days\":-1}", http.StatusBadRequest, "Invalid lifetime provided. Must be between 1 and 365 days."},
+		{"{\"name\":\"CI\",\"scopes\":[\"everything\"]}", http.StatusBadRequest, "Invalid scope provided: \"everything\"."},
+		{"{\"name\":\"CI\",\"scopes\":[\"tracks:remove\"]}", http.StatusForbidden, "You cannot give a token a scope you don't have: \"tracks:remove\"."},
+	} {
+		buffer := bytes.NewBufferString(test.body)
+		resp, err := http.Post("http://localhost:8080/api/v1/auth/tokens", "application/json", buffer)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.body)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+	}
+}
+
+func (suite *AppSuite) TestRetrieveAndRemoveApiTokens() {
+	defer suite.ctrl.Finish()
+
+	expiresAt := time.Date(2027, time.January, 16, 9, 30, 0, 0, time.UTC)
+
+	mockApiTokenDao := mock.NewMockApiTokenDao(suite.ctrl)
+	mockApiTokenDao.EXPECT().
+		LoadForUser(gomock.Eq(testUser.Id)).
+		Return([]model.ApiToken{{Id: 7, UserId: 1, Name: "CI", TokenHash: "abc123", Scopes: []string{"tracks:create"}, ExpiresAt: expiresAt}}).
+		Times(1)
+	mockApiTokenDao.EXPECT().
+		Delete(gomock.Eq(model.ApiToken{Id: 7, UserId: testUser.Id})).
+		Return(int64(1), nil).
+		Times(1)
+	mockApiTokenDao.EXPECT().
+		Delete(gomock.Eq(model.ApiToken{Id: 8, UserId: testUser.Id})).
+		Return(int64(0), nil).
+		Times(1)
+	mockApiTokenDao.EXPECT().
+		Delete(gomock.Eq(model.ApiToken{Id: 9, UserId: testUser.Id})).
+		Return(int64(0), errors.New("dial tcp 10.0.0.5:3306: connect: connection refused")).
+		Times(1)
+	mockApiTokenDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		ApiToken: mockApiTokenDao,
+	}
+
+	app := application.NewApp(withTestSession(suite.ctrl, dbClient), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	resp, err := http.Get("http://localhost:8080/api/v1/auth/tokens")
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	retBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	suite.Nil(err)
+	suite.Equal("[{\"id\":7,\"user\":1,\"name\":\"CI\",\"scopes\":[\"tracks:create\"],\"createdAt\":\"0001-01-01T00:00:00Z\",\"expiresAt\":\"2027-01-16T09:30:00Z\",\"lastUsedAt\":null}]", string(retBody))
+
+	for _, test := range []struct {
+		url    string
+		status int
+	}{
+		{"7", http.StatusOK},
+		{"8", http.StatusNotFound},
+		{"9", http.StatusInternalServerError},
+	} {
+		req, err := http.NewRequest(http.MethodDelete, "http://localhost:8080/api/v1/auth/tokens/"+test.url, nil)
+		suite.Nil(err)
+
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.url)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+
+		// The database error is only logged, never sent back
+		if test.status == http.StatusInternalServerError {
+			suite.Equal("Something went wrong, please try again.", problemDetail(retBody))
+		}
+	}
+}
+
+/*
+A client that hands back redirects instead of following them, so tests can
+look at where single sign-on sends the browser.
+*/
+func noRedirectClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func testOidcLogin(client auth.OidcClient) application.OidcLogin {
+	return application.OidcLogin{
+		Client:        client,
+		AllowedGroups: []string{"staff"},
+		RoleGroups: map[string]model.Role{
+			"staff":   model.RoleContributor,
+			"editors": model.RoleEditor,
+		},
+	}
+}
+
+func (suite *AppSuite) TestOidcLogin() {
+	defer suite.ctrl.Finish()
+
+	var state, nonce, verifier string
+	mockOidcClient := authmock.NewMockOidcClient(suite.ctrl)
+	mockOidcClient.EXPECT().
+		AuthCodeUrl(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(s, n, v string) string {
+			state, nonce, verifier = s, n, v
+			return "https://sso.example.com/authorize?state=" + s
+		}).
+		Times(1)
+	mockOidcClient.EXPECT().
+		Exchange(gomock.Any(), gomock.Eq("the-code"), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx interface{}, code, v, n string) (*auth.OidcIdentity, error) {
+			suite.Equal(verifier, v)
+			suite.Equal(nonce, n)
+			return &auth.OidcIdentity{Subject: "subject-1", Username: "Bobby", Groups: []string{"staff", "editors"}}, nil
+		}).
+		Times(1)
+
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		LoadByOidcSubject(gomock.Eq("subject-1")).
+		Return(nil).
+		Times(1)
+	mockUserDao.EXPECT().
+		Save(gomock.Eq(model.User{Username: "Bobby", Role: model.RoleEditor, OidcSubject: "subject-1"})).
+		Return(int64(2), nil).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	var saved model.Session
+	mockSessionDao := mock.NewMockSessionDao(suite.ctrl)
+	mockSessionDao.EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(session model.Session) error {
+			saved = session
+			return nil
+		}).
+		Times(1)
+	mockSessionDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User:    mockUserDao,
+		Session: mockSessionDao,
+	}
+
+	app := application.NewAppWithOidc(dbClient, server, testOidcLogin(mockOidcClient))
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	client := noRedirectClient()
+
+	resp, err := client.Get("http://localhost:8080/api/v1/auth/oidc/login")
+	suite.Nil(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusFound, resp.StatusCode)
+	suite.Equal("https://sso.example.com/authorize?state="+state, resp.Header.Get("Location"))
+
+	cookies := resp.Cookies()
+	suite.Len(cookies, 1)
+	suite.Equal("citadel_oidc", cookies[0].Name)
+	suite.Equal(state+"."+nonce+"."+verifier, cookies[0].Value)
+	suite.Equal("/api/v1/auth/oidc", cookies[0].Path)
+	suite.True(cookies[0].HttpOnly)
+	suite.True(cookies[0].Secure)
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/v1/auth/oidc/callback?code=the-code&state="+state, nil)
+	suite.Nil(err)
+	req.AddCookie(cookies[0])
+
+	resp, err = client.Do(req)
+	suite.Nil(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusFound, resp.StatusCode)
+	suite.Equal("/", resp.Header.Get("Location"))
+
+	names := make(map[string]*http.Cookie)
+	for _, cookie := range resp.Cookies() {
+		names[cookie.Name] = cookie
+	}
+	suite.Equal(-1, names["citadel_oidc"].MaxAge)
+	suite.Equal(auth.SessionId(names[auth.SessionCookieName].Value), saved.Id)
+	suite.Equal(int64(2), saved.UserId)
+}
+
+func (suite *AppSuite) TestOidcCallbackInvalid() {
+	defer suite.ctrl.Finish()
+
+	mockOidcClient := authmock.NewMockOidcClient(suite.ctrl)
+	mockOidcClient.EXPECT().
+		Exchange(gomock.Any(), gomock.Eq("bad-code"), gomock.Eq("verifier"), gomock.Eq("nonce")).
+		Return(nil, auth.ErrOidcNonce).
+		Times(1)
+	mockOidcClient.EXPECT().
+		Exchange(gomock.Any(), gomock.Eq("outsider"), gomock.Eq("verifier"), gomock.Eq("nonce")).
+		Return(&auth.OidcIdentity{Subject: "subject-2", Username: "Eve", Groups: []string{"guests"}}, nil).
+		Times(1)
+	mockOidcClient.EXPECT().
+		Exchange(gomock.Any(), gomock.Eq("local-name"), gomock.Eq("verifier"), gomock.Eq("nonce")).
+		Return(&auth.OidcIdentity{Subject: "subject-3", Username: "James", Groups: []string{"staff"}}, nil).
+		Times(1)
+
+	mockUserDao := mock.NewMockUserDao(suite.ctrl)
+	mockUserDao.EXPECT().
+		LoadByOidcSubject(gomock.Eq("subject-3")).
+		Return(nil).
+		Times(1)
+	mockUserDao.EXPECT().
+		Save(gomock.Eq(model.User{Username: "James", Role: model.RoleContributor, OidcSubject: "subject-3"})).
+		Return(int64(0), fmt.Errorf("%w: username", dao.ErrDuplicate)).
+		Times(1)
+	mockUserDao.EXPECT().Close().Times(1)
+
+	server := server.NewServer(suite.cfg)
+	dbClient := db.DatabaseClient{
+		User: mockUserDao,
+	}
+
+	app := application.NewAppWithOidc(withTestSession(suite.ctrl, dbClient), server, testOidcLogin(mockOidcClient))
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	client := noRedirectClient()
+
+	for _, test := range []struct {
+		query    string
+		cookie   string
+		status   int
+		expected string
+	}{
+		{"code=the-code&state=state", "", http.StatusBadRequest, "Single sign-on login expired or was started elsewhere, please try again."},
+		{"code=the-code&state=other", "state.nonce.verifier", http.StatusBadRequest, "Single sign-on login expired or was started elsewhere, please try again."},
+		{"error=access_denied&state=state", "state.nonce.verifier", http.StatusUnauthorized, "Single sign-on failed: access_denied."},
+		{"code=bad-code&state=state", "state.nonce.verifier", http.StatusUnauthorized, "Single sign-on failed."},
+		{"code=outsider&state=state", "state.nonce.verifier", http.StatusForbidden, "You are not in a group that is allowed to use the intranet."},
+		{"code=local-name&state=state", "state.nonce.verifier", http.StatusConflict, "A user named \"James\" already exists."},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/v1/auth/oidc/callback?"+test.query, nil)
+		suite.Nil(err)
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "citadel_oidc", Value: test.cookie})
+		}
+
+		resp, err := client.Do(req)
+		suite.Nil(err)
+		suite.Equal(test.status, resp.StatusCode, test.query)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody), test.query)
+	}
+}
+
+func (suite *AppSuite) TestRetrieveLoginMethods() {
+	defer suite.ctrl.Finish()
+
+	for _, test := range []struct {
+		oidc     application.OidcLogin
+		expected string
+	}{
+		{application.OidcLogin{}, "{\"password\":true,\"oidc\":false}"},
+		{testOidcLogin(authmock.NewMockOidcClient(suite.ctrl)), "{\"password\":true,\"oidc\":true}"},
+	} {
+		app := application.NewAppWithOidc(db.DatabaseClient{}, server.NewServer(suite.cfg), test.oidc)
+		app.Run()
+
+		resp, err := http.Get("http://localhost:8080/api/v1/auth/methods")
+		suite.Nil(err)
+		suite.Equal(http.StatusOK, resp.StatusCode)
+
+		retBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err)
+		suite.Equal(test.expected, problemDetail(retBody))
+
+		app.Close()
+		http.DefaultClient.CloseIdleConnections()
+	}
+}
+
+func TestNewOidcLogin(t *testing.T) {
+	assert := assert.New(t)
+
+	login, err := application.NewOidcLogin(nil, config.Config{
+		OidcAllowedGroups: []string{"staff"},
+		OidcRoleGroups:    map[string]string{"editors": "editor", "admins": "admin"},
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"staff"}, login.AllowedGroups)
+	assert.Equal(map[string]model.Role{"editors": model.RoleEditor, "admins": model.RoleAdmin}, login.RoleGroups)
+
+	_, err = application.NewOidcLogin(nil, config.Config{
+		OidcRoleGroups: map[string]string{"editors": "boss"},
+	})
+	assert.NotNil(err)
+}
+
+func (suite *AppSuite) TestRequestLoggingUser() {
+	defer suite.ctrl.Finish()
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	server := server.NewServer(suite.cfg)
+	app := application.NewApp(withTestSession(suite.ctrl, db.DatabaseClient{}), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/v1/auth/session", nil)
+	suite.Nil(err)
+	req.Header.Set("X-Request-ID", "trace-1")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Nil(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("trace-1", resp.Header.Get("X-Request-ID"))
+
+	suite.Eventually(func() bool {
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == "Handled request" {
+				return entry.Data["request_id"] == "trace-1" && entry.Data["user"] == "James"
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
 }
@@ -0,0 +1,214 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"citadel_intranet/src/auth"
+	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/logging"
+
+	"github.com/kataras/muxie"
+)
+
+/*
+How long a session lasts after logging in, before the user has to log in
+again.
+*/
+const SessionLifetime = 14 * 24 * time.Hour
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	apiTokenContextKey
+)
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type passwordChangeRequest struct {
+	Current string `json:"current"`
+	New     string `json:"new"`
+}
+
+/*
+The user that made a request. Only available to handlers registered behind
+requireUser, for which there is always a user.
+*/
+func currentUser(req *http.Request) *model.User {
+	user, _ := req.Context().Value(userContextKey).(*model.User)
+	return user
+}
+
+/*
+Look up the user owning the session named in the request's session cookie.
+Expired sessions are deleted as they are found.
+
+Returns nil if there is no cookie, or no live session for it
+*/
+func (this App) sessionUser(req *http.Request) *model.User {
+	cookie, err := req.Cookie(auth.SessionCookieName)
+	if err != nil {
+		return nil
+	}
+
+	sessionId := auth.SessionId(cookie.Value)
+	session := this.db.Session.Load(sessionId)
+	if session == nil {
+		return nil
+	}
+
+	if session.IsExpired(time.Now()) {
+		if _, err := this.db.Session.Delete(sessionId); err != nil {
+			logging.FromContext(req.Context()).Warn("Unable to delete expired session ", err.Error())
+		}
+		return nil
+	}
+
+	return this.db.User.Load(session.UserId)
+}
+
+/*
+Middleware rejecting any request that isn't from a logged in user, making the
+user available to the wrapped handler through currentUser. Users are logged in
+either through their session cookie, or an API token given as a bearer token.
+*/
+func (this App) requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(out http.ResponseWriter, req *http.Request) {
+		var user *model.User
+		var token *model.ApiToken
+
+		if header := req.Header.Get("Authorization"); header != "" {
+			user, token = this.apiTokenUser(req.Context(), header)
+			out.Header().Set("WWW-Authenticate", "Bearer")
+		} else {
+			user = this.sessionUser(req)
+		}
+
+		if user == nil {
+			writeProblem(out, req, ProblemUnauthenticated, "You must be logged in.")
+			return
+		}
+
+		logging.SetUser(req.Context(), user.Username)
+
+		ctx := context.WithValue(req.Context(), userContextKey, user)
+		if token != nil {
+			ctx = context.WithValue(ctx, apiTokenContextKey, token)
+		}
+
+		next.ServeHTTP(out, req.WithContext(ctx))
+	})
+}
+
+func setSessionCookie(out http.ResponseWriter, token string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     auth.SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if token == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expires
+	}
+
+	http.SetCookie(out, cookie)
+}
+
+/*
+Log a user in, creating a session for them and handing its token to the
+browser. Must be called before anything is written to the response.
+*/
+func (this App) startSession(out http.ResponseWriter, req *http.Request, user *model.User) error {
+	token, err := auth.NewSessionToken()
+	if err != nil {
+		return err
+	}
+
+	session := model.Session{
+		Id:        auth.SessionId(token),
+		UserId:    user.Id,
+		ExpiresAt: time.Now().UTC().Add(SessionLifetime),
+	}
+
+	if err = this.db.Session.Save(session); err != nil {
+		return err
+	}
+
+	setSessionCookie(out, token, session.ExpiresAt)
+	logging.SetUser(req.Context(), user.Username)
+	return nil
+}
+
+func (this App) login(out http.ResponseWriter, req *http.Request) {
+	// A body that can't be read is treated like any other failed login, so
+	// that nothing is given away about why it failed.
+	request := loginRequest{}
+	muxie.JSON.Bind(req, &request)
+
+	hash := ""
+	user := this.db.User.LoadByUsername(request.Username)
+	if user != nil {
+		hash = user.PasswordHash
+	}
+
+	if !auth.CheckPassword(hash, request.Password) {
+		writeProblem(out, req, ProblemInvalidCredentials, "Invalid username or password.")
+		return
+	}
+
+	if err := this.startSession(out, req, user); err != nil {
+		writeInternalError(out, req, err)
+		return
+	}
+
+	muxie.JSON.Dispatch(out, user)
+}
+
+func (this App) logout(out http.ResponseWriter, req *http.Request) {
+	if cookie, err := req.Cookie(auth.SessionCookieName); err == nil {
+		if _, err := this.db.Session.Delete(auth.SessionId(cookie.Value)); err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+	}
+
+	setSessionCookie(out, "", time.Time{})
+}
+
+func (this App) retrieveSessionUser(out http.ResponseWriter, req *http.Request) {
+	muxie.JSON.Dispatch(out, currentUser(req))
+}
+
+func (this App) changePassword(out http.ResponseWriter, req *http.Request) {
+	request := passwordChangeRequest{}
+	if !bindJSON(out, req, &request) {
+		return
+	}
+
+	user := *currentUser(req)
+	if !auth.CheckPassword(user.PasswordHash, request.Current) {
+		writeProblem(out, req, ProblemIncorrectPassword, "Current password is incorrect.")
+		return
+	}
+
+	hash, err := auth.HashPassword(request.New)
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidRequest, err.Error())
+		return
+	}
+
+	user.PasswordHash = hash
+	if _, err = this.db.User.Save(user); err != nil {
+		writeDaoError(out, req, err)
+	}
+}
@@ -0,0 +1,286 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/kataras/muxie"
+)
+
+/*
+Body of a request to change a comment, fields left out are left unchanged.
+*/
+type commentUpdateRequest struct {
+	Body     *string `json:"body"`
+	Resolved *bool   `json:"resolved"`
+}
+
+/*
+Fill in the comment count on each album, in a single round trip.
+
+Returns an error if the comments couldn't be counted, rather than leaving
+every album looking like it has none
+*/
+func (this App) fillCommentCounts(ctx context.Context, albums []model.Album) error {
+	if len(albums) == 0 {
+		return nil
+	}
+
+	albumIds := make([]int64, len(albums))
+	for index, album := range albums {
+		albumIds[index] = album.Id
+	}
+
+	counts, err := this.db.Comment.CountForAlbums(ctx, albumIds)
+	if err != nil {
+		return err
+	}
+
+	for index := range albums {
+		albums[index].CommentCount = counts[albums[index].Id]
+	}
+	return nil
+}
+
+/*
+Arrange a flat list of comments into threads, with each reply nested under
+the comment it replies to. Deleted comments are only kept when they still
+have replies.
+*/
+func threadComments(comments []model.Comment) []model.Comment {
+	replies := make(map[int64][]model.Comment)
+	for _, comment := range comments {
+		var parentId int64
+		if comment.ParentId != nil {
+			parentId = *comment.ParentId
+		}
+		replies[parentId] = append(replies[parentId], comment)
+	}
+
+	var thread func(int64) []model.Comment
+	thread = func(parentId int64) []model.Comment {
+		ret := make([]model.Comment, 0)
+		for _, comment := range replies[parentId] {
+			comment.Replies = thread(comment.Id)
+			if comment.IsDeleted() && len(comment.Replies) == 0 {
+				continue
+			}
+			ret = append(ret, comment)
+		}
+		return ret
+	}
+
+	return thread(0)
+}
+
+/*
+Load the comment named in the url.
+
+Returns nil, having already written the response, if there is no such comment
+*/
+func (this App) loadCommentFromUrl(out http.ResponseWriter, req *http.Request) *model.Comment {
+	var commentId int64
+	if commentId = parseIdFromUrl(out, req); commentId == 0 {
+		return nil
+	}
+
+	comment, err := this.db.Comment.Load(req.Context(), commentId)
+	if err != nil {
+		writeLoadError(out, req, err, "Comment not found.")
+		return nil
+	}
+
+	if comment.IsDeleted() {
+		writeProblem(out, req, ProblemNotFound, "Comment not found.")
+		return nil
+	}
+
+	return comment
+}
+
+/*
+Reload a comment after changing it and send it back.
+*/
+func (this App) writeComment(out http.ResponseWriter, req *http.Request, commentId int64, status int) {
+	comment, err := this.db.Comment.Load(req.Context(), commentId)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	out.WriteHeader(status)
+	muxie.JSON.Dispatch(out, comment)
+}
+
+func (this App) retrieveComments(target model.Target) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		var targetId int64
+		if targetId = parseIdFromUrl(out, req); targetId == 0 {
+			return
+		}
+
+		if _, err := this.loadTarget(req.Context(), target, targetId); err != nil {
+			writeTargetError(out, req, target, err)
+			return
+		}
+
+		comments, err := this.db.Comment.LoadFor(req.Context(), target, targetId)
+		if err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		muxie.JSON.Dispatch(out, threadComments(comments))
+	}
+}
+
+func (this App) createComment(target model.Target) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		var targetId int64
+		if targetId = parseIdFromUrl(out, req); targetId == 0 {
+			return
+		}
+
+		comment := model.Comment{}
+		if !bindJSON(out, req, &comment) {
+			return
+		}
+
+		check := this.validator()
+		check.body("body", comment.Body)
+		if err := check.err(); err != nil {
+			writeValidationError(out, req, err)
+			return
+		}
+
+		if _, err := this.loadTarget(req.Context(), target, targetId); err != nil {
+			writeTargetError(out, req, target, err)
+			return
+		}
+
+		if comment.ParentId != nil {
+			parent, err := this.db.Comment.Load(req.Context(), *comment.ParentId)
+			if err != nil && !errors.Is(err, dao.ErrNotFound) {
+				writeDaoError(out, req, err)
+				return
+			}
+
+			if parent == nil || parent.IsDeleted() {
+				writeProblem(out, req, ProblemInvalidRequest, "Invalid parent provided. Comment does not exist.")
+				return
+			}
+
+			if parent.Target != target || parent.TargetId != targetId {
+				writeProblem(out, req, ProblemInvalidRequest, "Invalid parent provided. Replies must be left on the same album or track.")
+				return
+			}
+		}
+
+		comment = model.Comment{
+			Target:   target,
+			TargetId: targetId,
+			ParentId: comment.ParentId,
+			Author:   currentUser(req).Username,
+			Body:     comment.Body,
+		}
+
+		commentId, err := this.db.Comment.Save(req.Context(), comment)
+		if err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		this.writeComment(out, req, commentId, http.StatusCreated)
+	}
+}
+
+func (this App) retrieveComment(out http.ResponseWriter, req *http.Request) {
+	comment := this.loadCommentFromUrl(out, req)
+	if comment == nil {
+		return
+	}
+
+	muxie.JSON.Dispatch(out, comment)
+}
+
+/*
+Check that the user making a request may change what a comment says. Anybody
+can change their own comments, only moderators can change other people's.
+
+Returns whether the request may go ahead
+*/
+func checkCommentOwner(out http.ResponseWriter, req *http.Request, comment *model.Comment) bool {
+	if comment.Author == currentUser(req).Username {
+		return true
+	}
+
+	return checkPermission(out, req, PermissionModerateComments)
+}
+
+func (this App) updateComment(out http.ResponseWriter, req *http.Request) {
+	comment := this.loadCommentFromUrl(out, req)
+	if comment == nil {
+		return
+	}
+
+	request := commentUpdateRequest{}
+	if !bindJSON(out, req, &request) {
+		return
+	}
+
+	if request.Body != nil && *request.Body != comment.Body {
+		if !checkCommentOwner(out, req, comment) {
+			return
+		}
+
+		check := this.validator()
+		check.body("body", *request.Body)
+		if err := check.err(); err != nil {
+			writeValidationError(out, req, err)
+			return
+		}
+
+		editedAt := time.Now().UTC()
+		comment.Body = *request.Body
+		comment.EditedAt = &editedAt
+	}
+
+	if request.Resolved != nil {
+		comment.Resolved = *request.Resolved
+	}
+
+	_, err := this.db.Comment.Save(req.Context(), *comment)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	this.writeComment(out, req, comment.Id, http.StatusOK)
+}
+
+func (this App) removeComment(out http.ResponseWriter, req *http.Request) {
+	comment := this.loadCommentFromUrl(out, req)
+	if comment == nil {
+		return
+	}
+
+	if !checkCommentOwner(out, req, comment) {
+		return
+	}
+
+	// Comments are only ever soft deleted so that any replies to them keep
+	// their place in the thread.
+	deletedAt := time.Now().UTC()
+	comment.Body = ""
+	comment.DeletedAt = &deletedAt
+
+	_, err := this.db.Comment.Save(req.Context(), *comment)
+	if err != nil {
+		writeDaoError(out, req, err)
+	}
+}
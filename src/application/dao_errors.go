@@ -0,0 +1,53 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/logging"
+)
+
+/*
+What clients are told when something unexpected goes wrong. The error itself
+is only logged, as it may say more about the server than clients should see.
+*/
+const internalErrorDetail = "Something went wrong, please try again."
+
+/*
+Write back an unexpected error as a 500.
+*/
+func writeInternalError(out http.ResponseWriter, req *http.Request, err error) {
+	logging.FromContext(req.Context()).WithError(err).Error("Request failed")
+	writeProblem(out, req, ProblemInternalError, internalErrorDetail)
+}
+
+/*
+Write back an error from a DAO call, as a 503 when the database couldn't be
+reached so that clients know it's worth trying again, or a 500 otherwise.
+*/
+func writeDaoError(out http.ResponseWriter, req *http.Request, err error) {
+	log := logging.FromContext(req.Context()).WithError(err)
+
+	if errors.Is(err, dao.ErrUnavailable) {
+		log.Warn("Database unavailable")
+		writeProblem(out, req, ProblemDatabaseUnavailable, "The database is unavailable, please try again later.")
+		return
+	}
+
+	log.Error("Database call failed")
+	writeProblem(out, req, ProblemInternalError, internalErrorDetail)
+}
+
+/*
+Write back an error from loading something, as a 404 with the notFound message
+when there's no such thing, or as any other DAO error.
+*/
+func writeLoadError(out http.ResponseWriter, req *http.Request, err error, notFound string) {
+	if errors.Is(err, dao.ErrNotFound) {
+		writeProblem(out, req, ProblemNotFound, notFound)
+		return
+	}
+
+	writeDaoError(out, req, err)
+}
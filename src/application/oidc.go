@@ -0,0 +1,219 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"citadel_intranet/src/auth"
+	"citadel_intranet/src/config"
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/logging"
+
+	"github.com/kataras/muxie"
+)
+
+const (
+	// Holds the state, nonce and PKCE verifier of a login in progress, while
+	// the browser is off at the provider.
+	oidcCookieName   = "citadel_oidc"
+	oidcCookiePath   = "/api/v1/auth/oidc"
+	oidcCookieMaxAge = 10 * 60
+)
+
+/*
+Everything needed to log people in through an OpenID Connect provider.
+*/
+type OidcLogin struct {
+	Client auth.OidcClient
+
+	// Users must be in one of these groups to log in, unless it's empty.
+	AllowedGroups []string
+
+	// Role given to members of each group.
+	RoleGroups map[string]model.Role
+}
+
+type loginMethods struct {
+	Password bool `json:"password"`
+	Oidc     bool `json:"oidc"`
+}
+
+/*
+Build the single sign-on settings for a client from the configuration,
+checking that every group is mapped to a real role.
+*/
+func NewOidcLogin(client auth.OidcClient, cfg config.Config) (OidcLogin, error) {
+	login := OidcLogin{
+		Client:        client,
+		AllowedGroups: cfg.OidcAllowedGroups,
+		RoleGroups:    make(map[string]model.Role),
+	}
+
+	for group, name := range cfg.OidcRoleGroups {
+		role, err := parseRole(name)
+		if err != nil {
+			return OidcLogin{}, fmt.Errorf("Group %q: %w", group, err)
+		}
+		login.RoleGroups[group] = role
+	}
+
+	return login, nil
+}
+
+/*
+Work out the role for someone in the given groups, the most powerful of the
+roles their groups map to.
+
+Returns the role, and whether they are allowed to log in at all
+*/
+func (this OidcLogin) roleFor(groups []string) (model.Role, bool) {
+	allowed := len(this.AllowedGroups) == 0
+	rank := 0
+
+	for _, group := range groups {
+		for _, allowedGroup := range this.AllowedGroups {
+			if group == allowedGroup {
+				allowed = true
+			}
+		}
+
+		if role, found := this.RoleGroups[group]; found {
+			for index, candidate := range model.Roles {
+				if candidate == role && index > rank {
+					rank = index
+				}
+			}
+		}
+	}
+
+	return model.Roles[rank], allowed
+}
+
+func setOidcCookie(out http.ResponseWriter, value string) {
+	cookie := &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   oidcCookieMaxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(out, cookie)
+}
+
+func (this App) retrieveLoginMethods(out http.ResponseWriter, req *http.Request) {
+	muxie.JSON.Dispatch(out, loginMethods{
+		Password: true,
+		Oidc:     this.oidc.Client != nil,
+	})
+}
+
+func (this App) startOidcLogin(out http.ResponseWriter, req *http.Request) {
+	values := make([]string, 3)
+	for index := range values {
+		value, err := auth.NewPkceVerifier()
+		if err != nil {
+			writeInternalError(out, req, err)
+			return
+		}
+		values[index] = value
+	}
+
+	state, nonce, verifier := values[0], values[1], values[2]
+	setOidcCookie(out, strings.Join(values, "."))
+	http.Redirect(out, req, this.oidc.Client.AuthCodeUrl(state, nonce, verifier), http.StatusFound)
+}
+
+func (this App) finishOidcLogin(out http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	cookie, err := req.Cookie(oidcCookieName)
+	setOidcCookie(out, "")
+
+	var parts []string
+	if err == nil {
+		parts = strings.Split(cookie.Value, ".")
+	}
+
+	if len(parts) != 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(query.Get("state"))) != 1 {
+		writeProblem(out, req, ProblemLoginExpired, "Single sign-on login expired or was started elsewhere, please try again.")
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		writeProblem(out, req, ProblemLoginFailed, fmt.Sprintf("Single sign-on failed: %s.", providerErr))
+		return
+	}
+
+	identity, err := this.oidc.Client.Exchange(req.Context(), query.Get("code"), parts[2], parts[1])
+	if err != nil {
+		logging.FromContext(req.Context()).Warn("Single sign-on failed ", err.Error())
+		writeProblem(out, req, ProblemLoginFailed, "Single sign-on failed.")
+		return
+	}
+
+	role, allowed := this.oidc.roleFor(identity.Groups)
+	if !allowed {
+		writeProblem(out, req, ProblemForbidden, "You are not in a group that is allowed to use the intranet.")
+		return
+	}
+
+	user, err := this.provisionOidcUser(req.Context(), identity, role)
+	if errors.Is(err, dao.ErrDuplicate) {
+		writeProblem(out, req, ProblemAlreadyExists, fmt.Sprintf("A user named %q already exists.", identity.Username))
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	if err = this.startSession(out, req, user); err != nil {
+		writeInternalError(out, req, err)
+		return
+	}
+
+	http.Redirect(out, req, "/", http.StatusFound)
+}
+
+/*
+Find the user for a single sign-on identity, creating them the first time they
+log in. Their username and role follow whatever the provider says each time.
+
+Local accounts are never taken over, if the username is already used by one
+the user isn't created and the error wraps ErrDuplicate.
+*/
+func (this App) provisionOidcUser(ctx context.Context, identity *auth.OidcIdentity, role model.Role) (*model.User, error) {
+	user := this.db.User.LoadByOidcSubject(identity.Subject)
+	if user == nil {
+		logging.FromContext(ctx).Info("Creating user ", identity.Username, " for single sign-on")
+		user = &model.User{
+			Username:    identity.Username,
+			Role:        role,
+			OidcSubject: identity.Subject,
+		}
+	} else if user.Username == identity.Username && user.Role == role {
+		return user, nil
+	}
+
+	user.Username = identity.Username
+	user.Role = role
+
+	var err error
+	user.Id, err = this.db.User.Save(*user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
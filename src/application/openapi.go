@@ -0,0 +1,19 @@
+package application
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+/*
+OpenAPI 3 description of every route under /api/v1. It's written by hand, and
+the tests check that it keeps up with the routes registered in Run and the
+fields of the models.
+*/
+//go:embed openapi.json
+var openApiDocument []byte
+
+func (this App) retrieveOpenApi(out http.ResponseWriter, req *http.Request) {
+	out.Header().Set("Content-Type", "application/json")
+	out.Write(openApiDocument)
+}
@@ -0,0 +1,121 @@
+package application_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"citadel_intranet/src/application"
+	authmock "citadel_intranet/src/auth/mock"
+	"citadel_intranet/src/db"
+	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/server"
+)
+
+type openApiDocument struct {
+	Paths      map[string]map[string]json.RawMessage
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage
+		}
+	}
+}
+
+var routeParam = regexp.MustCompile(`:(\w+)`)
+
+func (suite *AppSuite) retrieveOpenApi() openApiDocument {
+	resp, err := http.Get("http://localhost:8080/api/v1/openapi.json")
+	suite.Require().Nil(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal("application/json", resp.Header.Get("Content-Type"))
+
+	document := openApiDocument{}
+	suite.Require().Nil(json.NewDecoder(resp.Body).Decode(&document))
+	return document
+}
+
+func (suite *AppSuite) TestOpenApiRoutes() {
+	defer suite.ctrl.Finish()
+
+	server := server.NewServer(suite.cfg)
+	app := application.NewAppWithOidc(withTestSession(suite.ctrl, db.DatabaseClient{}), server, testOidcLogin(authmock.NewMockOidcClient(suite.ctrl)))
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	document := suite.retrieveOpenApi()
+
+	// Every route answers a method it doesn't handle with the ones it does,
+	// which have to match the operations the document gives its path.
+	registered := map[string]bool{}
+	for _, route := range server.Mux.Routes.Autocomplete("/api/v1/", nil) {
+		if strings.Contains(route, "*") {
+			continue
+		}
+
+		path := routeParam.ReplaceAllString(strings.TrimPrefix(route, "/api/v1"), "{$1}")
+		registered[path] = true
+
+		req, err := http.NewRequest("TRACE", "http://localhost:8080"+routeParam.ReplaceAllString(route, "1"), nil)
+		suite.Nil(err)
+		resp, err := http.DefaultClient.Do(req)
+		suite.Nil(err)
+		resp.Body.Close()
+		suite.Equal(http.StatusMethodNotAllowed, resp.StatusCode, route)
+
+		allowed := strings.Split(strings.ToLower(resp.Header.Get("Allow")), ", ")
+		documented := []string{}
+		for method := range document.Paths[path] {
+			documented = append(documented, method)
+		}
+		sort.Strings(allowed)
+		sort.Strings(documented)
+		suite.Equal(allowed, documented, "Operations documented for "+path)
+	}
+
+	suite.NotEmpty(registered)
+	for path := range document.Paths {
+		suite.True(registered[path], "Documented path "+path+" isn't registered")
+	}
+}
+
+func (suite *AppSuite) TestOpenApiModels() {
+	defer suite.ctrl.Finish()
+
+	server := server.NewServer(suite.cfg)
+	app := application.NewApp(withTestSession(suite.ctrl, db.DatabaseClient{}), server)
+	suite.NotNil(app)
+	defer app.Close()
+	app.Run()
+
+	document := suite.retrieveOpenApi()
+
+	// Fields are taken from the JSON the models are sent as, so that fields
+	// added when marshalling (like an album's published flag) are included.
+	for name, value := range map[string]interface{}{
+		"Album":  model.Album{},
+		"Artist": model.Artist{},
+		"Track":  model.Track{},
+	} {
+		body, err := json.Marshal(value)
+		suite.Nil(err)
+
+		fields := map[string]json.RawMessage{}
+		suite.Nil(json.Unmarshal(body, &fields))
+		suite.NotEmpty(fields)
+
+		schema, ok := document.Components.Schemas[name]
+		suite.True(ok, "Missing schema "+name)
+		for field := range fields {
+			_, ok := schema.Properties[field]
+			suite.True(ok, "Missing field "+name+"."+field)
+		}
+		for property := range schema.Properties {
+			_, ok := fields[property]
+			suite.True(ok, "Documented field "+name+"."+property+" isn't on the model")
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/logging"
+
+	"github.com/kataras/muxie"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+/*
+Apply an RFC 7386 merge patch to a JSON value. Objects in the patch are merged
+into the target key by key, a null removes the key, and anything else replaces
+the target outright.
+*/
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergeValue(targetObject[key], value)
+		}
+	}
+
+	return targetObject
+}
+
+/*
+Apply a merge patch to the JSON form of original, decoding the result into
+merged. Fields the patch removes are left at their zero value.
+*/
+func applyMergePatch(original interface{}, patch []byte, merged interface{}) error {
+	document, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+
+	var target, changes interface{}
+	if err = json.Unmarshal(document, &target); err != nil {
+		return err
+	}
+	if err = json.Unmarshal(patch, &changes); err != nil {
+		return err
+	}
+
+	document, err = json.Marshal(mergeValue(target, changes))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(document, merged)
+}
+
+/*
+Read a merge patch from the request body, writing back an error if it isn't
+one.
+
+Returns the patch, and whether it was read successfully
+*/
+func readMergePatch(out http.ResponseWriter, req *http.Request) ([]byte, bool) {
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+			writeProblem(out, req, ProblemUnsupportedMediaType, "Invalid Content-Type provided. Patches must be application/merge-patch+json.")
+			return nil, false
+		}
+	}
+
+	patch, err := ioutil.ReadAll(req.Body)
+	if err != nil || !json.Valid(patch) {
+		writeProblem(out, req, ProblemInvalidJson, "Invalid patch provided. Must be a JSON merge patch.")
+		return nil, false
+	}
+
+	return patch, true
+}
+
+/*
+Apply a merge patch read from the request to original, writing back an error
+if it can't be.
+
+Returns whether the patch was applied
+*/
+func mergePatchRequest(out http.ResponseWriter, req *http.Request, original interface{}, merged interface{}) bool {
+	patch, ok := readMergePatch(out, req)
+	if !ok {
+		return false
+	}
+
+	if err := applyMergePatch(original, patch, merged); err != nil {
+		writeDecodeError(out, req, err, "Invalid patch provided. Must be a JSON merge patch.")
+		return false
+	}
+
+	return true
+}
+
+func (this App) patchAlbum(out http.ResponseWriter, req *http.Request) {
+	var albumId int64
+	if albumId = parseIdFromUrl(out, req); albumId == 0 {
+		return
+	}
+
+	original, err := this.db.Album.Load(req.Context(), albumId)
+	if err != nil {
+		writeLoadError(out, req, err, "Album not found.")
+		return
+	}
+
+	if version, err := parseIfMatch(req); err != nil {
+		writeProblem(out, req, ProblemInvalidIfMatch, err.Error())
+		return
+	} else if version != 0 && version != original.Version {
+		this.writeStaleAlbum(out, req, albumId)
+		return
+	}
+
+	album := model.Album{}
+	if !mergePatchRequest(out, req, original, &album) {
+		return
+	}
+
+	// Only the album's own fields can be patched, its identity, state and
+	// tracks have endpoints of their own. The album is moved to another
+	// artist by patching the artist's id.
+	album.Id = albumId
+	album.State = original.State
+	album.Tracks = original.Tracks
+	album.Version = original.Version
+
+	check := this.validator()
+	check.album(album, false)
+	if err = check.artistExists(req.Context(), this.db.Artist, "artist.id", album.Artist.Id); err == nil {
+		err = check.err()
+	}
+	if err != nil {
+		writeValidationError(out, req, err)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Patching album with id=", albumId)
+	if err = this.saveAlbum(req.Context(), &album, false); errors.Is(err, dao.ErrConflict) {
+		this.writeStaleAlbum(out, req, albumId)
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	// Reload the album so that the response reflects what was saved, such as
+	// the name of an artist it was moved to.
+	patched, err := this.db.Album.Load(req.Context(), albumId)
+	if err != nil {
+		writeLoadError(out, req, err, "Album not found.")
+		return
+	}
+
+	albums := []model.Album{*patched}
+	if err = this.fillCommentCounts(req.Context(), albums); err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+	setETag(out, patched.Version)
+	muxie.JSON.Dispatch(out, albums[0])
+}
+
+func (this App) patchTrack(out http.ResponseWriter, req *http.Request) {
+	var trackId int64
+	if trackId = parseIdFromUrl(out, req); trackId == 0 {
+		return
+	}
+
+	original, err := this.db.Track.Load(req.Context(), trackId)
+	if err != nil {
+		writeLoadError(out, req, err, "Track not found.")
+		return
+	}
+
+	if version, err := parseIfMatch(req); err != nil {
+		writeProblem(out, req, ProblemInvalidIfMatch, err.Error())
+		return
+	} else if version != 0 && version != original.Version {
+		this.writeStaleTrack(out, req, trackId)
+		return
+	}
+
+	track := model.Track{}
+	if !mergePatchRequest(out, req, original, &track) {
+		return
+	}
+
+	track.Id = trackId
+	track.Version = original.Version
+
+	check := this.validator()
+	check.track("", track)
+
+	// Only a track that moves can land on a position that's already taken.
+	var tracks []model.Track
+	if track.AlbumId != original.AlbumId {
+		var album *model.Album
+		if album, err = check.albumExists(req.Context(), this.db.Album, "album", track.AlbumId); album != nil {
+			tracks = album.Tracks
+		}
+	} else if track.DiscNumber != original.DiscNumber || track.TrackNumber != original.TrackNumber {
+		tracks, err = this.db.Track.LoadForAlbum(req.Context(), track.AlbumId)
+	}
+	check.freePosition("", track, tracks)
+	if err == nil {
+		err = check.err()
+	}
+	if err != nil {
+		writeValidationError(out, req, err)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Patching track with id=", trackId)
+	if _, err = this.db.Track.Save(req.Context(), track); errors.Is(err, dao.ErrConflict) {
+		this.writeStaleTrack(out, req, trackId)
+		return
+	} else if errors.Is(err, dao.ErrDuplicate) {
+		this.writePositionTaken(out, req, track)
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	track.Version++
+	setETag(out, track.Version)
+	muxie.JSON.Dispatch(out, &track)
+}
+
+func (this App) patchArtist(out http.ResponseWriter, req *http.Request) {
+	var artistId int64
+	if artistId = parseIdFromUrl(out, req); artistId == 0 {
+		return
+	}
+
+	original, err := this.db.Artist.Load(req.Context(), artistId)
+	if err != nil {
+		writeLoadError(out, req, err, "Artist not found.")
+		return
+	}
+
+	artist := model.Artist{}
+	if !mergePatchRequest(out, req, original, &artist) {
+		return
+	}
+	artist.Id = artistId
+
+	if !this.saveArtist(out, req, &artist) {
+		return
+	}
+	muxie.JSON.Dispatch(out, &artist)
+}
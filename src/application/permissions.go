@@ -0,0 +1,156 @@
+package application
+
+import (
+	"fmt"
+	"net/http"
+
+	"citadel_intranet/src/db/model"
+)
+
+/*
+Something a user may or may not be allowed to do. Reading the catalogue is
+open to every logged in user, so only changes are listed here. The values
+double as the scopes API tokens are given.
+*/
+type Permission string
+
+const (
+	PermissionCreateAlbum      Permission = "albums:create"
+	PermissionEditAlbum        Permission = "albums:edit"
+	PermissionRemoveAlbum      Permission = "albums:remove"
+	PermissionChangeAlbumState Permission = "albums:state"
+	PermissionCreateTrack      Permission = "tracks:create"
+	PermissionEditTrack        Permission = "tracks:edit"
+	PermissionRemoveTrack      Permission = "tracks:remove"
+	PermissionCreateArtist     Permission = "artists:create"
+	PermissionEditArtist       Permission = "artists:edit"
+	PermissionRemoveArtist     Permission = "artists:remove"
+	PermissionComment          Permission = "comments:write"
+	PermissionModerateComments Permission = "comments:moderate"
+	PermissionPropose          Permission = "proposals:create"
+	PermissionVote             Permission = "proposals:vote"
+	PermissionAcceptProposal   Permission = "proposals:accept"
+	PermissionManageUsers      Permission = "users:manage"
+)
+
+/*
+What each permission allows, reading as the end of "You are not allowed to
+...", for when a request is denied.
+*/
+var permissionDescriptions = map[Permission]string{
+	PermissionCreateAlbum:      "create albums",
+	PermissionEditAlbum:        "edit albums",
+	PermissionRemoveAlbum:      "remove albums",
+	PermissionChangeAlbumState: "change the state of albums",
+	PermissionCreateTrack:      "create tracks",
+	PermissionEditTrack:        "edit tracks",
+	PermissionRemoveTrack:      "remove tracks",
+	PermissionCreateArtist:     "create artists",
+	PermissionEditArtist:       "edit artists",
+	PermissionRemoveArtist:     "remove artists",
+	PermissionComment:          "comment",
+	PermissionModerateComments: "change other people's comments",
+	PermissionPropose:          "propose titles",
+	PermissionVote:             "vote on titles",
+	PermissionAcceptProposal:   "accept title proposals",
+	PermissionManageUsers:      "manage users",
+}
+
+/*
+Everything each role is allowed to do. Roles don't inherit from each other,
+each one lists its permissions in full.
+*/
+var rolePermissions = map[model.Role][]Permission{
+	model.RoleViewer: {},
+	model.RoleContributor: {
+		PermissionCreateAlbum,
+		PermissionEditAlbum,
+		PermissionCreateTrack,
+		PermissionEditTrack,
+		PermissionCreateArtist,
+		PermissionComment,
+		PermissionPropose,
+		PermissionVote,
+	},
+	model.RoleEditor: {
+		PermissionCreateAlbum,
+		PermissionEditAlbum,
+		PermissionRemoveAlbum,
+		PermissionChangeAlbumState,
+		PermissionCreateTrack,
+		PermissionEditTrack,
+		PermissionRemoveTrack,
+		PermissionCreateArtist,
+		PermissionEditArtist,
+		PermissionRemoveArtist,
+		PermissionComment,
+		PermissionModerateComments,
+		PermissionPropose,
+		PermissionVote,
+		PermissionAcceptProposal,
+	},
+	model.RoleAdmin: {
+		PermissionCreateAlbum,
+		PermissionEditAlbum,
+		PermissionRemoveAlbum,
+		PermissionChangeAlbumState,
+		PermissionCreateTrack,
+		PermissionEditTrack,
+		PermissionRemoveTrack,
+		PermissionCreateArtist,
+		PermissionEditArtist,
+		PermissionRemoveArtist,
+		PermissionComment,
+		PermissionModerateComments,
+		PermissionPropose,
+		PermissionVote,
+		PermissionAcceptProposal,
+		PermissionManageUsers,
+	},
+}
+
+func can(user *model.User, permission Permission) bool {
+	if user == nil {
+		return false
+	}
+
+	for _, allowed := range rolePermissions[user.Role] {
+		if allowed == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Check that the user making a request has a permission, writing back a 403 if
+they don't. Requests made with an API token also need the token to be scoped
+for the permission.
+
+Returns whether the request may go ahead
+*/
+func checkPermission(out http.ResponseWriter, req *http.Request, permission Permission) bool {
+	if !can(currentUser(req), permission) {
+		writeProblem(out, req, ProblemForbidden, fmt.Sprintf("You are not allowed to %s.", permissionDescriptions[permission]))
+		return false
+	}
+
+	if token := currentApiToken(req); token != nil && !token.HasScope(string(permission)) {
+		writeProblem(out, req, ProblemTokenNotAllowed, fmt.Sprintf("This API token is not allowed to %s.", permissionDescriptions[permission]))
+		return false
+	}
+
+	return true
+}
+
+/*
+Wrap a handler so it's only run for users with the given permission.
+*/
+func allow(permission Permission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		if checkPermission(out, req, permission) {
+			handler(out, req)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package application
+
+import (
+	"net/http"
+
+	"citadel_intranet/src/logging"
+)
+
+const problemContentType = "application/problem+json"
+
+/*
+A stable, machine-readable code for each kind of problem the API reports.
+Clients branch on these rather than on the detail, which is written for people
+and may change.
+*/
+type ProblemCode string
+
+const (
+	ProblemInvalidRequest       ProblemCode = "invalid_request"
+	ProblemInvalidId            ProblemCode = "invalid_id"
+	ProblemInvalidJson          ProblemCode = "invalid_json"
+	ProblemInvalidParameter     ProblemCode = "invalid_parameter"
+	ProblemInvalidIfMatch       ProblemCode = "invalid_if_match"
+	ProblemInvalidTrackOrder    ProblemCode = "invalid_track_order"
+	ProblemIncorrectPassword    ProblemCode = "incorrect_password"
+	ProblemLoginExpired         ProblemCode = "login_expired"
+	ProblemUnauthenticated      ProblemCode = "unauthenticated"
+	ProblemInvalidCredentials   ProblemCode = "invalid_credentials"
+	ProblemLoginFailed          ProblemCode = "login_failed"
+	ProblemForbidden            ProblemCode = "forbidden"
+	ProblemTokenNotAllowed      ProblemCode = "token_not_allowed"
+	ProblemNotFound             ProblemCode = "not_found"
+	ProblemAlreadyExists        ProblemCode = "already_exists"
+	ProblemArtistHasAlbums      ProblemCode = "artist_has_albums"
+	ProblemUserStillNeeded      ProblemCode = "user_still_needed"
+	ProblemInvalidTransition    ProblemCode = "invalid_transition"
+	ProblemConcurrentChange     ProblemCode = "concurrent_change"
+	ProblemProposalClosed       ProblemCode = "proposal_closed"
+	ProblemStaleVersion         ProblemCode = "stale_version"
+	ProblemUnsupportedMediaType ProblemCode = "unsupported_media_type"
+	ProblemValidationFailed     ProblemCode = "validation_failed"
+	ProblemInternalError        ProblemCode = "internal_error"
+	ProblemDatabaseUnavailable  ProblemCode = "database_unavailable"
+)
+
+type problemType struct {
+	status int
+	title  string
+}
+
+/*
+The status and title that go with each code. Titles describe the kind of
+problem, and so never change between occurrences of it.
+*/
+var problemTypes = map[ProblemCode]problemType{
+	ProblemInvalidRequest:       {http.StatusBadRequest, "Invalid request"},
+	ProblemInvalidId:            {http.StatusBadRequest, "Invalid ID"},
+	ProblemInvalidJson:          {http.StatusBadRequest, "Invalid JSON"},
+	ProblemInvalidParameter:     {http.StatusBadRequest, "Invalid query parameter"},
+	ProblemInvalidIfMatch:       {http.StatusBadRequest, "Invalid If-Match header"},
+	ProblemInvalidTrackOrder:    {http.StatusBadRequest, "Invalid track order"},
+	ProblemIncorrectPassword:    {http.StatusBadRequest, "Incorrect password"},
+	ProblemLoginExpired:         {http.StatusBadRequest, "Single sign-on login expired"},
+	ProblemUnauthenticated:      {http.StatusUnauthorized, "Not logged in"},
+	ProblemInvalidCredentials:   {http.StatusUnauthorized, "Invalid username or password"},
+	ProblemLoginFailed:          {http.StatusUnauthorized, "Single sign-on failed"},
+	ProblemForbidden:            {http.StatusForbidden, "Not allowed"},
+	ProblemTokenNotAllowed:      {http.StatusForbidden, "Not allowed with an API token"},
+	ProblemNotFound:             {http.StatusNotFound, "Not found"},
+	ProblemAlreadyExists:        {http.StatusConflict, "Already exists"},
+	ProblemArtistHasAlbums:      {http.StatusConflict, "Artist still has albums"},
+	ProblemUserStillNeeded:      {http.StatusConflict, "User cannot be removed"},
+	ProblemInvalidTransition:    {http.StatusConflict, "Invalid album state change"},
+	ProblemConcurrentChange:     {http.StatusConflict, "Changed by someone else"},
+	ProblemProposalClosed:       {http.StatusConflict, "Proposal already closed"},
+	ProblemStaleVersion:         {http.StatusPreconditionFailed, "Out of date copy"},
+	ProblemUnsupportedMediaType: {http.StatusUnsupportedMediaType, "Unsupported Content-Type"},
+	ProblemValidationFailed:     {http.StatusUnprocessableEntity, "Invalid fields"},
+	ProblemInternalError:        {http.StatusInternalServerError, "Internal error"},
+	ProblemDatabaseUnavailable:  {http.StatusServiceUnavailable, "Database unavailable"},
+}
+
+/*
+An RFC 7807 problem details document, written back for every error. Fields and
+Current are extensions, set for invalid fields and out of date copies.
+*/
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	RequestId string       `json:"requestId,omitempty"`
+	Code      ProblemCode  `json:"code"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	Current   interface{}  `json:"current,omitempty"`
+}
+
+/*
+The type URI of the problems a code is given to.
+*/
+func ProblemTypeUri(code ProblemCode) string {
+	return "urn:citadel:problem:" + string(code)
+}
+
+func newProblem(req *http.Request, code ProblemCode, detail string) Problem {
+	problemType, ok := problemTypes[code]
+	if !ok {
+		problemType = problemTypes[ProblemInternalError]
+	}
+
+	return Problem{
+		Type:      ProblemTypeUri(code),
+		Title:     problemType.title,
+		Status:    problemType.status,
+		Detail:    detail,
+		RequestId: logging.RequestId(req.Context()),
+		Code:      code,
+	}
+}
+
+func (this Problem) write(out http.ResponseWriter) {
+	out.Header().Set("Content-Type", problemContentType)
+	out.WriteHeader(this.Status)
+	writeBack(out, this)
+}
+
+/*
+Write back a problem with the status that goes with its code.
+*/
+func writeProblem(out http.ResponseWriter, req *http.Request, code ProblemCode, detail string) {
+	newProblem(req, code, detail).write(out)
+}
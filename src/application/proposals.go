@@ -0,0 +1,191 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/kataras/muxie"
+)
+
+func parseProposalIdFromUrl(out http.ResponseWriter, req *http.Request) int64 {
+	proposalIdStr := muxie.GetParam(out, "proposal")
+	proposalId, err := strconv.ParseInt(proposalIdStr, 10, 64)
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidId, "Invalid proposal ID provided. Must be an integer.")
+		return 0
+	}
+
+	return proposalId
+}
+
+/*
+Parse both ids out of a proposal url, and load the proposal, making sure that
+it belongs to the album or track in the url.
+
+Returns nil, having already written the response, if the proposal can't be
+used
+*/
+func (this App) loadProposalFromUrl(out http.ResponseWriter, req *http.Request, target model.Target) *model.TitleProposal {
+	var targetId, proposalId int64
+	if targetId = parseIdFromUrl(out, req); targetId == 0 {
+		return nil
+	}
+	if proposalId = parseProposalIdFromUrl(out, req); proposalId == 0 {
+		return nil
+	}
+
+	proposal, err := this.db.Proposal.Load(req.Context(), proposalId)
+	if err != nil {
+		writeLoadError(out, req, err, "Proposal not found.")
+		return nil
+	}
+
+	if proposal.Target != target || proposal.TargetId != targetId {
+		writeProblem(out, req, ProblemNotFound, "Proposal not found.")
+		return nil
+	}
+
+	if proposal.Status != model.ProposalOpen {
+		writeProblem(out, req, ProblemProposalClosed, fmt.Sprintf("Proposal has already been %s.", proposal.Status))
+		return nil
+	}
+
+	return proposal
+}
+
+/*
+Reload a proposal after changing it, so that its score is up to date, and
+send it back.
+*/
+func (this App) writeProposal(out http.ResponseWriter, req *http.Request, proposalId int64, status int) {
+	proposal, err := this.db.Proposal.Load(req.Context(), proposalId)
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	out.WriteHeader(status)
+	muxie.JSON.Dispatch(out, proposal)
+}
+
+func (this App) retrieveProposals(target model.Target) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		var targetId int64
+		if targetId = parseIdFromUrl(out, req); targetId == 0 {
+			return
+		}
+
+		if _, err := this.loadTarget(req.Context(), target, targetId); err != nil {
+			writeTargetError(out, req, target, err)
+			return
+		}
+
+		proposals, err := this.db.Proposal.LoadFor(req.Context(), target, targetId)
+		if err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		muxie.JSON.Dispatch(out, proposals)
+	}
+}
+
+func (this App) createProposal(target model.Target) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		var targetId int64
+		if targetId = parseIdFromUrl(out, req); targetId == 0 {
+			return
+		}
+
+		proposal := model.TitleProposal{}
+		if !bindJSON(out, req, &proposal) {
+			return
+		}
+
+		check := this.validator()
+		check.title("title", proposal.Title)
+		if err := check.err(); err != nil {
+			writeValidationError(out, req, err)
+			return
+		}
+
+		title, err := this.loadTarget(req.Context(), target, targetId)
+		if err != nil {
+			writeTargetError(out, req, target, err)
+			return
+		}
+
+		if proposal.Title == title {
+			writeProblem(out, req, ProblemInvalidRequest, fmt.Sprintf("The %s is already titled %q.", target, title))
+			return
+		}
+
+		proposal.Id = 0
+		proposal.Target = target
+		proposal.TargetId = targetId
+		proposal.ProposedBy = currentUser(req).Username
+		proposal.Status = model.ProposalOpen
+
+		proposalId, err := this.db.Proposal.Save(req.Context(), proposal)
+		if err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		this.writeProposal(out, req, proposalId, http.StatusCreated)
+	}
+}
+
+func (this App) voteOnProposal(target model.Target) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		proposal := this.loadProposalFromUrl(out, req, target)
+		if proposal == nil {
+			return
+		}
+
+		vote := model.ProposalVote{}
+		if !bindJSON(out, req, &vote) {
+			return
+		}
+
+		if vote.Vote != 1 && vote.Vote != -1 {
+			writeProblem(out, req, ProblemInvalidRequest, "Invalid vote provided. Must be 1 or -1.")
+			return
+		}
+
+		vote.ProposalId = proposal.Id
+		vote.Voter = currentUser(req).Username
+
+		if err := this.db.Proposal.Vote(req.Context(), vote); err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		this.writeProposal(out, req, proposal.Id, http.StatusOK)
+	}
+}
+
+func (this App) acceptProposal(target model.Target) http.HandlerFunc {
+	return func(out http.ResponseWriter, req *http.Request) {
+		proposal := this.loadProposalFromUrl(out, req, target)
+		if proposal == nil {
+			return
+		}
+
+		err := this.db.Proposal.Accept(req.Context(), proposal.Id)
+		if errors.Is(err, dao.ErrConflict) {
+			writeProblem(out, req, ProblemProposalClosed, "Proposal was closed by someone else.")
+			return
+		} else if err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		this.writeProposal(out, req, proposal.Id, http.StatusOK)
+	}
+}
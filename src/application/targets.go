@@ -0,0 +1,43 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"citadel_intranet/src/db/model"
+)
+
+/*
+Load the current title of the album or track that proposals or comments are
+attached to.
+
+Returns the title and an error, which wraps dao.ErrNotFound if there is no
+such album or track
+*/
+func (this App) loadTarget(ctx context.Context, target model.Target, targetId int64) (string, error) {
+	switch target {
+	case model.TargetAlbum:
+		album, err := this.db.Album.Load(ctx, targetId)
+		if err != nil {
+			return "", err
+		}
+		return album.Title, nil
+	case model.TargetTrack:
+		track, err := this.db.Track.Load(ctx, targetId)
+		if err != nil {
+			return "", err
+		}
+		return track.Title, nil
+	}
+
+	return "", fmt.Errorf("Unknown target %q", target)
+}
+
+func writeTargetError(out http.ResponseWriter, req *http.Request, target model.Target, err error) {
+	if target == model.TargetAlbum {
+		writeLoadError(out, req, err, "Album not found.")
+	} else {
+		writeLoadError(out, req, err, "Track not found.")
+	}
+}
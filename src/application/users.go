@@ -0,0 +1,206 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"citadel_intranet/src/auth"
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/kataras/muxie"
+	"github.com/sirupsen/logrus"
+)
+
+type userRequest struct {
+	Username string     `json:"username"`
+	Password string     `json:"password"`
+	Role     model.Role `json:"role"`
+}
+
+func parseRole(value string) (model.Role, error) {
+	names := make([]string, len(model.Roles))
+	for index, role := range model.Roles {
+		if string(role) == value {
+			return role, nil
+		}
+		names[index] = string(role)
+	}
+
+	return "", fmt.Errorf("Invalid role provided. Must be one of %s.", strings.Join(names, ", "))
+}
+
+/*
+Make sure that a user exists with the given role, creating them with the given
+password if they don't. The password of an existing user is left alone.
+*/
+func EnsureUser(users dao.UserDao, username string, password string, role model.Role) error {
+	if user := users.LoadByUsername(username); user != nil {
+		if user.Role == role {
+			return nil
+		}
+
+		logrus.Info("Changing role of ", username, " to ", role)
+		user.Role = role
+		_, err := users.Save(*user)
+		return err
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	logrus.Info("Creating user ", username)
+	_, err = users.Save(model.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+	})
+
+	return err
+}
+
+func (this App) retrieveUsers(out http.ResponseWriter, req *http.Request) {
+	muxie.JSON.Dispatch(out, this.db.User.LoadAll())
+}
+
+func (this App) createUser(out http.ResponseWriter, req *http.Request) {
+	request := userRequest{Role: model.RoleViewer}
+	if !bindJSON(out, req, &request) {
+		return
+	}
+
+	if request.Username == "" {
+		writeProblem(out, req, ProblemInvalidRequest, "Users must have a username.")
+		return
+	}
+
+	role, err := parseRole(string(request.Role))
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidRequest, err.Error())
+		return
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidRequest, err.Error())
+		return
+	}
+
+	user := model.User{
+		Username:     request.Username,
+		PasswordHash: hash,
+		Role:         role,
+	}
+
+	user.Id, err = this.db.User.Save(user)
+	if errors.Is(err, dao.ErrDuplicate) {
+		writeProblem(out, req, ProblemAlreadyExists, "A user with that username already exists.")
+		return
+	} else if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	out.WriteHeader(http.StatusCreated)
+	muxie.JSON.Dispatch(out, &user)
+}
+
+func (this App) retrieveUser(out http.ResponseWriter, req *http.Request) {
+	var userId int64
+	if userId = parseIdFromUrl(out, req); userId == 0 {
+		return
+	}
+
+	user := this.db.User.Load(userId)
+	if user == nil {
+		writeProblem(out, req, ProblemNotFound, "User not found.")
+		return
+	}
+
+	muxie.JSON.Dispatch(out, user)
+}
+
+func (this App) removeUser(out http.ResponseWriter, req *http.Request) {
+	var userId int64
+	if userId = parseIdFromUrl(out, req); userId == 0 {
+		return
+	}
+
+	// Much like changing your own role, removing yourself or the last admin
+	// would lock everyone out of user management.
+	if userId == currentUser(req).Id {
+		writeProblem(out, req, ProblemUserStillNeeded, "You cannot remove yourself.")
+		return
+	}
+
+	user := this.db.User.Load(userId)
+	if user == nil {
+		writeProblem(out, req, ProblemNotFound, "User not found.")
+		return
+	}
+
+	if user.Role == model.RoleAdmin {
+		admins, err := this.db.User.CountByRole(model.RoleAdmin)
+		if err != nil {
+			writeDaoError(out, req, err)
+			return
+		}
+
+		if admins <= 1 {
+			writeProblem(out, req, ProblemUserStillNeeded, "You cannot remove the last admin.")
+			return
+		}
+	}
+
+	rows, err := this.db.User.Delete(model.User{Id: userId})
+	if err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	if rows == 0 {
+		writeProblem(out, req, ProblemNotFound, "User not found.")
+	}
+}
+
+func (this App) changeUserRole(out http.ResponseWriter, req *http.Request) {
+	var userId int64
+	if userId = parseIdFromUrl(out, req); userId == 0 {
+		return
+	}
+
+	request := userRequest{}
+	if !bindJSON(out, req, &request) {
+		return
+	}
+
+	role, err := parseRole(string(request.Role))
+	if err != nil {
+		writeProblem(out, req, ProblemInvalidRequest, err.Error())
+		return
+	}
+
+	// Stops the last admin from locking everyone out of user management.
+	if userId == currentUser(req).Id {
+		writeProblem(out, req, ProblemInvalidRequest, "You cannot change your own role.")
+		return
+	}
+
+	user := this.db.User.Load(userId)
+	if user == nil {
+		writeProblem(out, req, ProblemNotFound, "User not found.")
+		return
+	}
+
+	user.Role = role
+	if _, err = this.db.User.Save(*user); err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	muxie.JSON.Dispatch(out, user)
+}
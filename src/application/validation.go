@@ -0,0 +1,292 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/kataras/muxie"
+)
+
+/*
+Longest title or name that fits in the VARCHAR(255) columns they're stored in.
+*/
+const maxTitleLength = 255
+
+/*
+Longest comment body, in bytes, that fits in the TEXT column it's stored in.
+*/
+const maxBodyLength = 65535
+
+/*
+Ratings albums and tracks may be given, from Min to Max inclusive.
+*/
+type RatingRange struct {
+	Min uint
+	Max uint
+}
+
+var DefaultRatingRange = RatingRange{Min: 0, Max: 5}
+
+/*
+A problem with a single field of a request body. Fields are given as paths
+into the JSON body, such as "artist.name" or "tracks[2].title".
+*/
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+/*
+Every problem found with a request body.
+*/
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (this ValidationError) Error() string {
+	problems := make([]string, len(this.Fields))
+	for index, field := range this.Fields {
+		problems[index] = field.Field + " " + field.Message
+	}
+	return fmt.Sprintf("Invalid fields provided: %s.", strings.Join(problems, ", "))
+}
+
+/*
+Collects every problem with a request body, rather than stopping at the first.
+*/
+type validator struct {
+	ratings RatingRange
+	fields  []FieldError
+}
+
+func (this App) validator() *validator {
+	return &validator{ratings: this.ratings}
+}
+
+func (this *validator) fail(field string, message string) {
+	this.fields = append(this.fields, FieldError{Field: field, Message: message})
+}
+
+/*
+Check a title or name, which must be given and fit in its column.
+*/
+func (this *validator) title(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		this.fail(field, "must not be empty")
+	} else if utf8.RuneCountInString(value) > maxTitleLength {
+		this.fail(field, fmt.Sprintf("must be at most %d characters", maxTitleLength))
+	}
+}
+
+/*
+Check the body of a comment, which must have something in it and fit in its
+column.
+*/
+func (this *validator) body(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		this.fail(field, "must not be empty")
+	} else if len(value) > maxBodyLength {
+		this.fail(field, fmt.Sprintf("must be at most %d bytes", maxBodyLength))
+	}
+}
+
+func (this *validator) rating(field string, value uint) {
+	if value < this.ratings.Min || value > this.ratings.Max {
+		this.fail(field, fmt.Sprintf("must be from %d to %d", this.ratings.Min, this.ratings.Max))
+	}
+}
+
+/*
+Check an album's own fields, along with its tracks when withTracks is set. An
+album without an artist id brings a new artist along, named in artist.name.
+*/
+func (this *validator) album(album model.Album, withTracks bool) {
+	this.title("title", album.Title)
+	this.rating("rating", album.Rating)
+
+	if album.Artist.Id == 0 {
+		this.title("artist.name", album.Artist.Name)
+	}
+
+	if withTracks {
+		for index, track := range album.Tracks {
+			prefix := fmt.Sprintf("tracks[%d].", index)
+			this.track(prefix, track)
+			this.freePosition(prefix, track, album.Tracks[:index])
+		}
+	}
+}
+
+/*
+Check a track's own fields, each field path starting with prefix.
+*/
+func (this *validator) track(prefix string, track model.Track) {
+	this.title(prefix+"title", track.Title)
+	this.rating(prefix+"rating", track.Rating)
+	this.position(prefix+"disc", track.DiscNumber)
+	this.position(prefix+"number", track.TrackNumber)
+}
+
+/*
+Check a disc or track number, which are counted from 1.
+*/
+func (this *validator) position(field string, value uint) {
+	if value < 1 {
+		this.fail(field, "must be at least 1")
+	}
+}
+
+/*
+Check that none of the other tracks on an album is already at a track's
+position, the field path starting with prefix.
+*/
+func (this *validator) freePosition(prefix string, track model.Track, tracks []model.Track) {
+	for _, other := range tracks {
+		if track.Id != 0 && other.Id == track.Id {
+			continue
+		}
+
+		if other.DiscNumber == track.DiscNumber && other.TrackNumber == track.TrackNumber {
+			this.positionTaken(prefix, track)
+			return
+		}
+	}
+}
+
+/*
+Report that another track on the album is at a track's position, which the
+database may also find when the track is saved.
+*/
+func (this *validator) positionTaken(prefix string, track model.Track) {
+	this.fail(prefix+"number", fmt.Sprintf("must not be taken by another track on disc %d", track.DiscNumber))
+}
+
+func (this *validator) artist(artist model.Artist) {
+	this.title("name", artist.Name)
+}
+
+/*
+Check that an artist referred to by field exists.
+
+Returns an error if the artist couldn't be looked up
+*/
+func (this *validator) artistExists(ctx context.Context, artists dao.ArtistDao, field string, artistId int64) error {
+	if artistId == 0 {
+		return nil
+	}
+
+	_, err := artists.Load(ctx, artistId)
+	if errors.Is(err, dao.ErrNotFound) {
+		this.fail(field, "must refer to an existing artist")
+		return nil
+	}
+	return err
+}
+
+/*
+Check that an album referred to by field exists.
+
+Returns the album, nil if it doesn't exist, and an error if it couldn't be
+looked up
+*/
+func (this *validator) albumExists(ctx context.Context, albums dao.AlbumDao, field string, albumId int64) (*model.Album, error) {
+	if albumId == 0 {
+		this.fail(field, "must refer to an existing album")
+		return nil, nil
+	}
+
+	album, err := albums.Load(ctx, albumId)
+	if errors.Is(err, dao.ErrNotFound) {
+		this.fail(field, "must refer to an existing album")
+		return nil, nil
+	}
+	return album, err
+}
+
+/*
+Returns a ValidationError listing every problem found, or nil if there were
+none
+*/
+func (this *validator) err() error {
+	if len(this.fields) == 0 {
+		return nil
+	}
+	return ValidationError{Fields: this.fields}
+}
+
+/*
+Write back a ValidationError as a 422 listing the fields at fault, or any
+other error as an error from a DAO call.
+*/
+func writeValidationError(out http.ResponseWriter, req *http.Request, err error) {
+	var invalid ValidationError
+	if !errors.As(err, &invalid) {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	problem := newProblem(req, ProblemValidationFailed, invalid.Error())
+	problem.Fields = invalid.Fields
+	problem.write(out)
+}
+
+/*
+Describe the JSON a Go type is decoded from.
+*/
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "a whole number"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number, no less than 0"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+/*
+Decode a JSON request body into value, writing back a 400 if it isn't JSON at
+all, or a 422 if a field has the wrong type.
+
+Returns whether the body was decoded
+*/
+func bindJSON(out http.ResponseWriter, req *http.Request, value interface{}) bool {
+	if err := muxie.JSON.Bind(req, value); err != nil {
+		writeDecodeError(out, req, err, "Invalid JSON provided. The request body couldn't be read.")
+		return false
+	}
+	return true
+}
+
+/*
+Write back an error from decoding JSON, as a 422 naming the field when it had
+the wrong type, or a 400 with the invalid message otherwise.
+*/
+func writeDecodeError(out http.ResponseWriter, req *http.Request, err error, invalid string) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		writeValidationError(out, req, ValidationError{Fields: []FieldError{{
+			Field:   typeErr.Field,
+			Message: "must be " + jsonTypeName(typeErr.Type.Kind()),
+		}}})
+		return
+	}
+
+	writeProblem(out, req, ProblemInvalidJson, invalid)
+}
@@ -0,0 +1,85 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"citadel_intranet/src/db/model"
+)
+
+/*
+Tag the response with an album or track's version, which clients send back in
+If-Match to make sure they aren't overwriting someone else's changes.
+*/
+func setETag(out http.ResponseWriter, version int64) {
+	out.Header().Set("ETag", fmt.Sprintf("\"%d\"", version))
+}
+
+/*
+Parse the version out of a request's If-Match header.
+
+Returns the version, or 0 when there is no header (or it's "*") and any
+version may be overwritten, and an error if the header isn't one of our ETags
+*/
+func parseIfMatch(req *http.Request) (int64, error) {
+	header := strings.TrimSpace(req.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	invalid := errors.New("Invalid If-Match header provided. Must be the ETag of the copy being changed.")
+	if len(header) < 2 || !strings.HasPrefix(header, "\"") || !strings.HasSuffix(header, "\"") {
+		return 0, invalid
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, invalid
+	}
+	return version, nil
+}
+
+/*
+Let the client know their copy of an album is out of date, sending back the
+current one in the problem so they can reapply their changes to it.
+*/
+func (this App) writeStaleAlbum(out http.ResponseWriter, req *http.Request, albumId int64) {
+	album, err := this.db.Album.Load(req.Context(), albumId)
+	if err != nil {
+		writeLoadError(out, req, err, "Album not found.")
+		return
+	}
+
+	albums := []model.Album{*album}
+	if err = this.fillCommentCounts(req.Context(), albums); err != nil {
+		writeDaoError(out, req, err)
+		return
+	}
+
+	problem := newProblem(req, ProblemStaleVersion, fmt.Sprintf("Album %d has been changed by someone else since it was loaded.", albumId))
+	problem.Current = albums[0]
+
+	setETag(out, album.Version)
+	problem.write(out)
+}
+
+/*
+Let the client know their copy of a track is out of date, sending back the
+current one in the problem so they can reapply their changes to it.
+*/
+func (this App) writeStaleTrack(out http.ResponseWriter, req *http.Request, trackId int64) {
+	track, err := this.db.Track.Load(req.Context(), trackId)
+	if err != nil {
+		writeLoadError(out, req, err, "Track not found.")
+		return
+	}
+
+	problem := newProblem(req, ProblemStaleVersion, fmt.Sprintf("Track %d has been changed by someone else since it was loaded.", trackId))
+	problem.Current = track
+
+	setETag(out, track.Version)
+	problem.write(out)
+}
@@ -0,0 +1,62 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"citadel_intranet/src/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashPassword(t *testing.T) {
+	assert := assert.New(t)
+
+	hash, err := auth.HashPassword("correct horse")
+	assert.Nil(err)
+	assert.NotEqual("correct horse", hash)
+
+	assert.True(auth.CheckPassword(hash, "correct horse"))
+	assert.False(auth.CheckPassword(hash, "battery staple"))
+	assert.False(auth.CheckPassword("", "correct horse"))
+	assert.False(auth.CheckPassword("not a hash", "correct horse"))
+}
+
+func TestHashPasswordLength(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := auth.HashPassword("short")
+	assert.Equal(auth.ErrPasswordTooShort, err)
+
+	_, err = auth.HashPassword(strings.Repeat("a", auth.MaxPasswordLength+1))
+	assert.Equal(auth.ErrPasswordTooLong, err)
+}
+
+func TestSessionToken(t *testing.T) {
+	assert := assert.New(t)
+
+	first, err := auth.NewSessionToken()
+	assert.Nil(err)
+	second, err := auth.NewSessionToken()
+	assert.Nil(err)
+
+	assert.NotEqual(first, second)
+	assert.Len(auth.SessionId(first), 64)
+	assert.Equal(auth.SessionId(first), auth.SessionId(first))
+	assert.NotEqual(auth.SessionId(first), auth.SessionId(second))
+	assert.NotContains(auth.SessionId(first), first)
+}
+
+func TestApiToken(t *testing.T) {
+	assert := assert.New(t)
+
+	first, err := auth.NewApiToken()
+	assert.Nil(err)
+	second, err := auth.NewApiToken()
+	assert.Nil(err)
+
+	assert.NotEqual(first, second)
+	assert.True(strings.HasPrefix(first, auth.ApiTokenPrefix))
+	assert.Len(auth.ApiTokenHash(first), 64)
+	assert.NotEqual(auth.ApiTokenHash(first), auth.ApiTokenHash(second))
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"citadel_intranet/src/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrOidcNoIdToken = errors.New("Provider did not return an ID token")
+	ErrOidcNonce     = errors.New("ID token nonce does not match")
+)
+
+type oidcClient struct {
+	oauth       oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+	groupsClaim string
+}
+
+/*
+Set up a client for the provider configured in cfg, fetching the provider's
+discovery document to find its endpoints and signing keys.
+*/
+func NewOidcClient(ctx context.Context, cfg config.Config) (OidcClient, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.OidcIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcClient{
+		oauth: oauth2.Config{
+			ClientID:     cfg.OidcClientId,
+			ClientSecret: cfg.OidcClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  cfg.OidcRedirectUrl,
+			Scopes:       cfg.OidcScopes,
+		},
+		verifier:    provider.Verifier(&oidc.Config{ClientID: cfg.OidcClientId}),
+		groupsClaim: cfg.OidcGroupsClaim,
+	}, nil
+}
+
+/*
+Generate a new PKCE code verifier, for proving to the provider that whoever
+finishes a login is whoever started it.
+*/
+func NewPkceVerifier() (string, error) {
+	return randomToken()
+}
+
+/*
+The S256 challenge sent to the provider for a PKCE code verifier.
+*/
+func PkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (this oidcClient) AuthCodeUrl(state string, nonce string, verifier string) string {
+	return this.oauth.AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", PkceChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+}
+
+func (this oidcClient) Exchange(ctx context.Context, code string, verifier string, nonce string) (*OidcIdentity, error) {
+	token, err := this.oauth.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrOidcNoIdToken
+	}
+
+	idToken, err := this.verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, ErrOidcNonce
+	}
+
+	var claims map[string]interface{}
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := &OidcIdentity{
+		Subject:  idToken.Subject,
+		Username: idToken.Subject,
+		Groups:   make([]string, 0),
+	}
+
+	// Anybody can claim an email address they haven't proven is theirs, so
+	// it only stands in for the username once the provider has verified it.
+	if value, ok := claims["preferred_username"].(string); ok && value != "" {
+		identity.Username = value
+	} else if value, ok := claims["email"].(string); ok && value != "" && claims["email_verified"] == true {
+		identity.Username = value
+	}
+
+	switch groups := claims[this.groupsClaim].(type) {
+	case nil:
+	case string:
+		identity.Groups = append(identity.Groups, groups)
+	case []interface{}:
+		for _, group := range groups {
+			identity.Groups = append(identity.Groups, fmt.Sprint(group))
+		}
+	default:
+		return nil, fmt.Errorf("Unexpected %s claim in ID token", this.groupsClaim)
+	}
+
+	return identity, nil
+}
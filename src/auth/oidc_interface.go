@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+)
+
+/*
+What we learn about someone from an OpenID Connect provider after they log in.
+*/
+type OidcIdentity struct {
+	Subject  string
+	Username string
+	Groups   []string
+}
+
+type OidcClient interface {
+	/*
+	   The provider's login page to send the browser to. The state, nonce and
+	   PKCE verifier must be kept by the caller, to be checked against when
+	   the provider sends the browser back.
+	*/
+	AuthCodeUrl(state string, nonce string, verifier string) string
+
+	/*
+	   Trade the code the provider sent the browser back with for the
+	   identity of whoever logged in, checking the ID token's signature,
+	   audience, expiry and nonce along the way.
+
+	   Returns the identity and an error
+	*/
+	Exchange(ctx context.Context, code string, verifier string, nonce string) (*OidcIdentity, error)
+}
@@ -0,0 +1,321 @@
+// +build integration
+
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"citadel_intranet/src/auth"
+	"citadel_intranet/src/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testClientId     = "intranet"
+	testClientSecret = "secret"
+	testRedirectUrl  = "https://intranet.example.com/api/v1/auth/oidc/callback"
+)
+
+type pendingLogin struct {
+	challenge string
+	nonce     string
+}
+
+/*
+A bare bones OpenID Connect provider, just enough of one to log a single user
+in through the authorization code flow with PKCE.
+*/
+type testProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+
+	lock   sync.Mutex
+	logins map[string]pendingLogin
+}
+
+func newTestProvider(t *testing.T, claims map[string]interface{}) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &testProvider{
+		key:    key,
+		claims: claims,
+		logins: make(map[string]pendingLogin),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("/keys", provider.keys)
+	mux.HandleFunc("/authorize", provider.authorize)
+	mux.HandleFunc("/token", provider.token)
+	provider.server = httptest.NewServer(mux)
+
+	return provider
+}
+
+func (this *testProvider) Close() {
+	this.server.Close()
+}
+
+func (this *testProvider) config() config.Config {
+	return config.Config{
+		OidcIssuer:       this.server.URL,
+		OidcClientId:     testClientId,
+		OidcClientSecret: testClientSecret,
+		OidcRedirectUrl:  testRedirectUrl,
+		OidcScopes:       []string{"openid", "profile"},
+		OidcGroupsClaim:  "groups",
+	}
+}
+
+func (this *testProvider) discovery(out http.ResponseWriter, req *http.Request) {
+	json.NewEncoder(out).Encode(map[string]interface{}{
+		"issuer":                                this.server.URL,
+		"authorization_endpoint":                this.server.URL + "/authorize",
+		"token_endpoint":                        this.server.URL + "/token",
+		"jwks_uri":                              this.server.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (this *testProvider) keys(out http.ResponseWriter, req *http.Request) {
+	json.NewEncoder(out).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(this.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(this.key.E)).Bytes()),
+		}},
+	})
+}
+
+/*
+Logs the user straight in, sending them back to the client with a code.
+*/
+func (this *testProvider) authorize(out http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("client_id") != testClientId || query.Get("code_challenge_method") != "S256" {
+		http.Error(out, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	code := query.Get("state") + "-code"
+
+	this.lock.Lock()
+	this.logins[code] = pendingLogin{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+	}
+	this.lock.Unlock()
+
+	http.Redirect(out, req, query.Get("redirect_uri")+"?code="+code+"&state="+query.Get("state"), http.StatusFound)
+}
+
+func (this *testProvider) token(out http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(out, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	clientId, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		clientId, clientSecret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+	if clientId != testClientId || clientSecret != testClientSecret {
+		http.Error(out, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	this.lock.Lock()
+	login, found := this.logins[req.PostForm.Get("code")]
+	delete(this.logins, req.PostForm.Get("code"))
+	this.lock.Unlock()
+
+	if !found || auth.PkceChallenge(req.PostForm.Get("code_verifier")) != login.challenge {
+		out.Header().Set("Content-Type", "application/json")
+		out.WriteHeader(http.StatusBadRequest)
+		out.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	claims := map[string]interface{}{
+		"iss":   this.server.URL,
+		"aud":   testClientId,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": login.nonce,
+	}
+	for claim, value := range this.claims {
+		claims[claim] = value
+	}
+
+	out.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(out).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     this.sign(claims),
+	})
+}
+
+func (this *testProvider) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, this.key, crypto.SHA256, sum[:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+/*
+Follow an authorization URL to the provider, returning the code it hands back.
+*/
+func authorizeCode(t *testing.T, authUrl string) string {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(authUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location.Query().Get("code")
+}
+
+func TestOidcLogin(t *testing.T) {
+	assert := assert.New(t)
+
+	provider := newTestProvider(t, map[string]interface{}{
+		"sub":                "subject-1",
+		"preferred_username": "Bobby",
+		"groups":             []string{"staff", "editors"},
+	})
+	defer provider.Close()
+
+	client, err := auth.NewOidcClient(context.Background(), provider.config())
+	assert.Nil(err)
+
+	verifier, err := auth.NewPkceVerifier()
+	assert.Nil(err)
+
+	authUrl := client.AuthCodeUrl("state", "nonce", verifier)
+	assert.Contains(authUrl, "code_challenge="+auth.PkceChallenge(verifier))
+	assert.Contains(authUrl, "redirect_uri="+url.QueryEscape(testRedirectUrl))
+
+	identity, err := client.Exchange(context.Background(), authorizeCode(t, authUrl), verifier, "nonce")
+	assert.Nil(err)
+	assert.Equal(&auth.OidcIdentity{
+		Subject:  "subject-1",
+		Username: "Bobby",
+		Groups:   []string{"staff", "editors"},
+	}, identity)
+}
+
+func TestOidcLoginUsernameFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	provider := newTestProvider(t, map[string]interface{}{
+		"sub":            "subject-2",
+		"email":          "eve@example.com",
+		"email_verified": true,
+		"groups":         "staff",
+	})
+	defer provider.Close()
+
+	client, err := auth.NewOidcClient(context.Background(), provider.config())
+	assert.Nil(err)
+
+	authUrl := client.AuthCodeUrl("state", "nonce", "verifier")
+	identity, err := client.Exchange(context.Background(), authorizeCode(t, authUrl), "verifier", "nonce")
+	assert.Nil(err)
+	assert.Equal(&auth.OidcIdentity{
+		Subject:  "subject-2",
+		Username: "eve@example.com",
+		Groups:   []string{"staff"},
+	}, identity)
+}
+
+func TestOidcLoginUnverifiedEmail(t *testing.T) {
+	assert := assert.New(t)
+
+	provider := newTestProvider(t, map[string]interface{}{
+		"sub":            "subject-3",
+		"email":          "james@example.com",
+		"email_verified": false,
+	})
+	defer provider.Close()
+
+	client, err := auth.NewOidcClient(context.Background(), provider.config())
+	assert.Nil(err)
+
+	// An unverified email could belong to anyone, so the subject is used
+	authUrl := client.AuthCodeUrl("state", "nonce", "verifier")
+	identity, err := client.Exchange(context.Background(), authorizeCode(t, authUrl), "verifier", "nonce")
+	assert.Nil(err)
+	assert.Equal(&auth.OidcIdentity{
+		Subject:  "subject-3",
+		Username: "subject-3",
+		Groups:   []string{},
+	}, identity)
+}
+
+func TestOidcLoginRejected(t *testing.T) {
+	assert := assert.New(t)
+
+	provider := newTestProvider(t, map[string]interface{}{"sub": "subject-1"})
+	defer provider.Close()
+
+	client, err := auth.NewOidcClient(context.Background(), provider.config())
+	assert.Nil(err)
+
+	// The code was issued for a different verifier
+	authUrl := client.AuthCodeUrl("state", "nonce", "verifier")
+	_, err = client.Exchange(context.Background(), authorizeCode(t, authUrl), "someone else", "nonce")
+	assert.NotNil(err)
+
+	// The ID token was issued for a different login
+	authUrl = client.AuthCodeUrl("state", "nonce", "verifier")
+	_, err = client.Exchange(context.Background(), authorizeCode(t, authUrl), "verifier", "other nonce")
+	assert.Equal(auth.ErrOidcNonce, err)
+
+	// Codes can only be used once
+	authUrl = client.AuthCodeUrl("state", "nonce", "verifier")
+	code := authorizeCode(t, authUrl)
+	_, err = client.Exchange(context.Background(), code, "verifier", "nonce")
+	assert.Nil(err)
+	_, err = client.Exchange(context.Background(), code, "verifier", "nonce")
+	assert.NotNil(err)
+
+	_, err = auth.NewOidcClient(context.Background(), config.Config{OidcIssuer: provider.server.URL + "/elsewhere"})
+	assert.NotNil(err)
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinPasswordLength = 8
+
+	// bcrypt ignores anything past its first 72 bytes, so longer passwords
+	// are refused rather than silently truncated.
+	MaxPasswordLength = 72
+)
+
+var (
+	ErrPasswordTooShort = fmt.Errorf("Passwords must be at least %d characters long.", MinPasswordLength)
+	ErrPasswordTooLong  = fmt.Errorf("Passwords must be at most %d bytes long.", MaxPasswordLength)
+)
+
+/*
+A hash that no password matches, compared against when a username doesn't
+exist so that logging in takes as long for unknown users as for known ones.
+*/
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	if len(password) > MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+/*
+Check a password against a hash made by HashPassword. An empty hash is treated
+as an unknown user, and never matches.
+*/
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(password))
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	SessionCookieName = "citadel_session"
+
+	/*
+	   Every API token starts with this, making them easy to spot in config
+	   files and logs that they shouldn't be in.
+	*/
+	ApiTokenPrefix = "citadel_"
+
+	tokenBytes = 32
+)
+
+func randomToken() (string, error) {
+	buffer := make([]byte, tokenBytes)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+Generate a new random session token, to be handed to the browser in the
+session cookie.
+*/
+func NewSessionToken() (string, error) {
+	return randomToken()
+}
+
+/*
+The id a session is stored under for a given token. Only this hash is ever
+stored, so that the sessions table can't be used to log in.
+*/
+func SessionId(token string) string {
+	return hashToken(token)
+}
+
+/*
+Generate a new random API token. It is shown to its owner once, after which
+only its hash is kept.
+*/
+func NewApiToken() (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	return ApiTokenPrefix + token, nil
+}
+
+/*
+The hash an API token is stored and looked up by.
+*/
+func ApiTokenHash(token string) string {
+	return hashToken(token)
+}
@@ -1,16 +1,18 @@
 package config
 
 import (
-	"os"
-	"strconv"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 const (
+	ENV_CONFIG_FILE = "CONFIG_FILE"
+
 	ENV_SECRET_LOCATION             = "SECRET_LOCATION"
 	ENV_SECRET_LOCATION_ENVIRONMENT = "environment"
 	ENV_SECRET_LOCATION_SECRETS     = "secrets"
+	ENV_SECRET_PATH                 = "SECRET_PATH"
 
 	ENV_DATABASE_HOST = "DB_HOST"
 	ENV_DATABASE_USER = "DB_USER"
@@ -18,11 +20,28 @@ const (
 	ENV_DATABASE_PORT = "DB_PORT"
 	ENV_DATABASE_NAME = "DB_NAME"
 
+	ENV_DATABASE_QUERY_TIMEOUT = "DB_QUERY_TIMEOUT"
+
 	ENV_SERVER_HOST = "SERVER_HOST"
 	ENV_SERVER_PORT = "SERVER_PORT"
 	ENV_SERVER_PATH = "SERVER_PATH"
 
 	ENV_MIGRATIONS_PATH = "MIGRATIONS"
+
+	ENV_RATING_MIN = "RATING_MIN"
+	ENV_RATING_MAX = "RATING_MAX"
+
+	ENV_ADMIN_USER = "ADMIN_USER"
+	ENV_ADMIN_PASS = "ADMIN_PASS"
+
+	ENV_OIDC_ISSUER         = "OIDC_ISSUER"
+	ENV_OIDC_CLIENT_ID      = "OIDC_CLIENT_ID"
+	ENV_OIDC_CLIENT_SECRET  = "OIDC_CLIENT_SECRET"
+	ENV_OIDC_REDIRECT_URL   = "OIDC_REDIRECT_URL"
+	ENV_OIDC_SCOPES         = "OIDC_SCOPES"
+	ENV_OIDC_GROUPS_CLAIM   = "OIDC_GROUPS_CLAIM"
+	ENV_OIDC_ALLOWED_GROUPS = "OIDC_ALLOWED_GROUPS"
+	ENV_OIDC_ROLE_GROUPS    = "OIDC_ROLE_GROUPS"
 )
 
 type Config struct {
@@ -32,60 +51,122 @@ type Config struct {
 	DbPort uint16
 	DbName string
 
+	// How long the queries made for a request may take, 0 for no limit.
+	DbQueryTimeout time.Duration
+
 	ServerHost     string
 	ServerPort     uint16
 	ServerFilePath string
 
 	MigrationsPath string
+
+	// Ratings given to albums and tracks must fall within this range.
+	RatingMin uint16
+	RatingMax uint16
+
+	// Account created on startup if it doesn't already exist, so that there
+	// is always someone able to log in.
+	AdminUser string
+	AdminPass string
+
+	// Single sign-on through an OpenID Connect provider, only enabled when
+	// an issuer is set.
+	OidcIssuer       string
+	OidcClientId     string
+	OidcClientSecret string
+	OidcRedirectUrl  string
+	OidcScopes       []string
+	OidcGroupsClaim  string
+
+	// Users must be in one of these groups to log in, unless it's empty.
+	OidcAllowedGroups []string
+
+	// Role given to members of each group, the most powerful one winning
+	// for users in several. Everyone else is a viewer.
+	OidcRoleGroups map[string]string
 }
 
 /*
-Load a configuration from environment variables, providing default values
+Load a configuration, taking each setting from the first place it's found in:
+command line flags, environment variables, the config file, and finally the
+defaults. Passwords and other secrets are read from files instead when
+SECRET_LOCATION is "secrets", and are never taken from flags.
+
+Returns the configuration and an error, an InvalidConfigError listing every
+problem found with it, or flag.ErrHelp if help was asked for. The
+configuration is still returned alongside an InvalidConfigError
 */
-func LoadConfig() Config {
+func LoadConfig(args []string) (Config, error) {
+	src, err := newSources(args)
+	if err != nil {
+		return Config{}, err
+	}
+
 	cfg := Config{
-		DbHost: getEnvStringWithDefault(ENV_DATABASE_HOST, "localhost"),
-		DbUser: getEnvStringWithDefault(ENV_DATABASE_USER, ""),
-		DbPass: getEnvStringWithDefault(ENV_DATABASE_PASS, ""),
-		DbPort: getEnvUint16WithDefault(ENV_DATABASE_PORT, 3306),
-		DbName: getEnvStringWithDefault(ENV_DATABASE_NAME, ""),
+		DbHost: src.string(ENV_DATABASE_HOST, "localhost"),
+		DbUser: src.string(ENV_DATABASE_USER, ""),
+		DbPass: src.secret(ENV_DATABASE_PASS, true),
+		DbPort: src.uint16(ENV_DATABASE_PORT, 3306),
+		DbName: src.string(ENV_DATABASE_NAME, ""),
+
+		DbQueryTimeout: src.duration(ENV_DATABASE_QUERY_TIMEOUT, 10*time.Second),
+
+		ServerHost:     src.string(ENV_SERVER_HOST, "localhost"),
+		ServerPort:     src.uint16(ENV_SERVER_PORT, 8080),
+		ServerFilePath: src.string(ENV_SERVER_PATH, "/var/www"),
 
-		ServerHost:     getEnvStringWithDefault(ENV_SERVER_HOST, "localhost"),
-		ServerPort:     getEnvUint16WithDefault(ENV_SERVER_PORT, 8080),
-		ServerFilePath: getEnvStringWithDefault(ENV_SERVER_PATH, "/var/www"),
+		MigrationsPath: src.string(ENV_MIGRATIONS_PATH, "/var/migrations"),
 
-		MigrationsPath: getEnvStringWithDefault(ENV_MIGRATIONS_PATH, "/var/migrations"),
+		RatingMin: src.uint16(ENV_RATING_MIN, 0),
+		RatingMax: src.uint16(ENV_RATING_MAX, 5),
+
+		AdminUser: src.string(ENV_ADMIN_USER, ""),
+
+		OidcIssuer:        src.string(ENV_OIDC_ISSUER, ""),
+		OidcClientId:      src.string(ENV_OIDC_CLIENT_ID, ""),
+		OidcRedirectUrl:   src.string(ENV_OIDC_REDIRECT_URL, ""),
+		OidcScopes:        src.list(ENV_OIDC_SCOPES, []string{"openid", "profile", "email"}),
+		OidcGroupsClaim:   src.string(ENV_OIDC_GROUPS_CLAIM, "groups"),
+		OidcAllowedGroups: src.list(ENV_OIDC_ALLOWED_GROUPS, []string{}),
+		OidcRoleGroups:    src.stringMap(ENV_OIDC_ROLE_GROUPS, map[string]string{}),
 	}
 
+	// Only needed when the features using them are turned on
+	cfg.AdminPass = src.secret(ENV_ADMIN_PASS, cfg.AdminUser != "")
+	cfg.OidcClientSecret = src.secret(ENV_OIDC_CLIENT_SECRET, cfg.OidcIssuer != "")
+
 	logrus.WithFields(logrus.Fields{
-		ENV_DATABASE_HOST:   cfg.DbHost,
-		ENV_DATABASE_NAME:   cfg.DbName,
-		ENV_DATABASE_PORT:   cfg.DbPort,
-		ENV_DATABASE_USER:   cfg.DbUser,
-		ENV_DATABASE_PASS:   "*****",
-		ENV_SERVER_HOST:     cfg.ServerHost,
-		ENV_SERVER_PORT:     cfg.ServerPort,
-		ENV_SERVER_PATH:     cfg.ServerFilePath,
-		ENV_MIGRATIONS_PATH: cfg.MigrationsPath,
-	}).Info("Configuration info loaded")
-	return cfg
-}
+		ENV_CONFIG_FILE:     src.string(ENV_CONFIG_FILE, ""),
+		ENV_SECRET_LOCATION: src.secretLocation,
+		ENV_SECRET_PATH:     src.secretPath,
 
-func getEnvStringWithDefault(key string, defaultValue string) string {
-	if val, found := os.LookupEnv(key); found {
-		return val
-	}
-	return defaultValue
-}
+		ENV_DATABASE_HOST:          cfg.DbHost,
+		ENV_DATABASE_NAME:          cfg.DbName,
+		ENV_DATABASE_PORT:          cfg.DbPort,
+		ENV_DATABASE_USER:          cfg.DbUser,
+		ENV_DATABASE_PASS:          "*****",
+		ENV_DATABASE_QUERY_TIMEOUT: cfg.DbQueryTimeout.String(),
+		ENV_SERVER_HOST:            cfg.ServerHost,
+		ENV_SERVER_PORT:            cfg.ServerPort,
+		ENV_SERVER_PATH:            cfg.ServerFilePath,
+		ENV_MIGRATIONS_PATH:        cfg.MigrationsPath,
+		ENV_RATING_MIN:             cfg.RatingMin,
+		ENV_RATING_MAX:             cfg.RatingMax,
+		ENV_ADMIN_USER:             cfg.AdminUser,
+		ENV_ADMIN_PASS:             "*****",
+
+		ENV_OIDC_ISSUER:         cfg.OidcIssuer,
+		ENV_OIDC_CLIENT_ID:      cfg.OidcClientId,
+		ENV_OIDC_CLIENT_SECRET:  "*****",
+		ENV_OIDC_REDIRECT_URL:   cfg.OidcRedirectUrl,
+		ENV_OIDC_SCOPES:         cfg.OidcScopes,
+		ENV_OIDC_GROUPS_CLAIM:   cfg.OidcGroupsClaim,
+		ENV_OIDC_ALLOWED_GROUPS: cfg.OidcAllowedGroups,
+		ENV_OIDC_ROLE_GROUPS:    cfg.OidcRoleGroups,
+	}).Info("Configuration info loaded")
 
-func getEnvUint16WithDefault(key string, defaultValue uint16) uint16 {
-	if val, found := os.LookupEnv(key); found {
-		ret, err := strconv.ParseUint(val, 10, 16)
-		if err == nil {
-			return uint16(ret)
-		} else {
-			logrus.Warn("Unable to parse uint16 value, returning default.", err.Error())
-		}
+	if problems := append(src.problems, cfg.Validate()...); len(problems) > 0 {
+		return cfg, InvalidConfigError{Problems: problems}
 	}
-	return defaultValue
+	return cfg, nil
 }
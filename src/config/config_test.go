@@ -1,87 +1,482 @@
 package config_test
 
 import (
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"citadel_intranet/src/config"
 )
 
+/*
+Set environment variables for a test, returning a function that unsets them
+again so that tests don't leak settings into each other.
+*/
+func setEnv(t *testing.T, values map[string]string) func() {
+	for key, value := range values {
+		if err := os.Setenv(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return func() {
+		for key := range values {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+/*
+Create a temporary directory holding the given files, returning its path and a
+function that removes it.
+*/
+func tempDir(t *testing.T, files map[string]string) (string, func()) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+/*
+The minimum needed for a configuration to be valid.
+*/
+func validEnv(t *testing.T) func() {
+	dir, removeDir := tempDir(t, map[string]string{})
+	unset := setEnv(t, map[string]string{
+		config.ENV_DATABASE_USER:   "bobby",
+		config.ENV_DATABASE_NAME:   "db1",
+		config.ENV_SERVER_PATH:     dir,
+		config.ENV_MIGRATIONS_PATH: dir,
+	})
+
+	return func() {
+		unset()
+		removeDir()
+	}
+}
+
 func TestLoadConfigEmpty(t *testing.T) {
 	assert := assert.New(t)
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(nil)
 
 	assert.Equal("localhost", cfg.DbHost)
 	assert.Equal("", cfg.DbUser)
 	assert.Equal("", cfg.DbPass)
 	assert.Equal(uint16(3306), cfg.DbPort)
 	assert.Equal("", cfg.DbName)
+	assert.Equal(10*time.Second, cfg.DbQueryTimeout)
 
 	assert.Equal("localhost", cfg.ServerHost)
 	assert.Equal(uint16(8080), cfg.ServerPort)
 	assert.Equal("/var/www", cfg.ServerFilePath)
 
 	assert.Equal("/var/migrations", cfg.MigrationsPath)
+
+	assert.Equal(uint16(0), cfg.RatingMin)
+	assert.Equal(uint16(5), cfg.RatingMax)
+
+	assert.Equal("", cfg.AdminUser)
+	assert.Equal("", cfg.AdminPass)
+
+	assert.Equal("", cfg.OidcIssuer)
+	assert.Equal([]string{"openid", "profile", "email"}, cfg.OidcScopes)
+	assert.Equal("groups", cfg.OidcGroupsClaim)
+	assert.Equal([]string{}, cfg.OidcAllowedGroups)
+	assert.Equal(map[string]string{}, cfg.OidcRoleGroups)
+
+	// The defaults alone don't say which database to use
+	invalid, ok := err.(config.InvalidConfigError)
+	assert.True(ok)
+	assert.Contains(invalid.Problems, "DB_NAME must be set")
+	assert.Contains(invalid.Problems, "DB_USER must be set")
 }
 
 func TestLoadConfigSetValues(t *testing.T) {
 	assert := assert.New(t)
 
-	assert.Nil(os.Setenv(config.ENV_DATABASE_HOST, "database.local"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_USER, "bobby"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_PASS, "tables"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_PORT, "23306"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_NAME, "db1"))
+	serverPath, removeServerPath := tempDir(t, map[string]string{})
+	defer removeServerPath()
 
-	assert.Nil(os.Setenv(config.ENV_SERVER_HOST, "webserver.local"))
-	assert.Nil(os.Setenv(config.ENV_SERVER_PORT, "80"))
-	assert.Nil(os.Setenv(config.ENV_SERVER_PATH, "/var/www/site1"))
+	migrationsPath, removeMigrationsPath := tempDir(t, map[string]string{})
+	defer removeMigrationsPath()
 
-	assert.Nil(os.Setenv(config.ENV_MIGRATIONS_PATH, "/opt/citadel/migrations"))
+	defer setEnv(t, map[string]string{
+		config.ENV_DATABASE_HOST: "database.local",
+		config.ENV_DATABASE_USER: "bobby",
+		config.ENV_DATABASE_PASS: "tables",
+		config.ENV_DATABASE_PORT: "23306",
+		config.ENV_DATABASE_NAME: "db1",
 
-	cfg := config.LoadConfig()
+		config.ENV_DATABASE_QUERY_TIMEOUT: "2m30s",
+
+		config.ENV_SERVER_HOST: "webserver.local",
+		config.ENV_SERVER_PORT: "80",
+		config.ENV_SERVER_PATH: serverPath,
+
+		config.ENV_MIGRATIONS_PATH: migrationsPath,
+
+		config.ENV_RATING_MIN: "1",
+		config.ENV_RATING_MAX: "10",
+
+		config.ENV_ADMIN_USER: "admin",
+		config.ENV_ADMIN_PASS: "hunter22",
+
+		config.ENV_OIDC_ISSUER:         "https://sso.citadel.local",
+		config.ENV_OIDC_CLIENT_ID:      "intranet",
+		config.ENV_OIDC_CLIENT_SECRET:  "shh",
+		config.ENV_OIDC_REDIRECT_URL:   "https://intranet.citadel.local/api/v1/auth/oidc/callback",
+		config.ENV_OIDC_SCOPES:         "openid, groups",
+		config.ENV_OIDC_GROUPS_CLAIM:   "roles",
+		config.ENV_OIDC_ALLOWED_GROUPS: "staff,,contractors",
+		config.ENV_OIDC_ROLE_GROUPS:    "a&r=editor, it=admin",
+	})()
+
+	cfg, err := config.LoadConfig(nil)
+	assert.Nil(err)
 
 	assert.Equal("database.local", cfg.DbHost)
 	assert.Equal("bobby", cfg.DbUser)
 	assert.Equal("tables", cfg.DbPass)
 	assert.Equal(uint16(23306), cfg.DbPort)
 	assert.Equal("db1", cfg.DbName)
+	assert.Equal(150*time.Second, cfg.DbQueryTimeout)
 
 	assert.Equal("webserver.local", cfg.ServerHost)
 	assert.Equal(uint16(80), cfg.ServerPort)
-	assert.Equal("/var/www/site1", cfg.ServerFilePath)
+	assert.Equal(serverPath, cfg.ServerFilePath)
+
+	assert.Equal(migrationsPath, cfg.MigrationsPath)
 
-	assert.Equal("/opt/citadel/migrations", cfg.MigrationsPath)
+	assert.Equal(uint16(1), cfg.RatingMin)
+	assert.Equal(uint16(10), cfg.RatingMax)
+
+	assert.Equal("admin", cfg.AdminUser)
+	assert.Equal("hunter22", cfg.AdminPass)
+
+	assert.Equal("https://sso.citadel.local", cfg.OidcIssuer)
+	assert.Equal("intranet", cfg.OidcClientId)
+	assert.Equal("shh", cfg.OidcClientSecret)
+	assert.Equal("https://intranet.citadel.local/api/v1/auth/oidc/callback", cfg.OidcRedirectUrl)
+	assert.Equal([]string{"openid", "groups"}, cfg.OidcScopes)
+	assert.Equal("roles", cfg.OidcGroupsClaim)
+	assert.Equal([]string{"staff", "contractors"}, cfg.OidcAllowedGroups)
+	assert.Equal(map[string]string{"a&r": "editor", "it": "admin"}, cfg.OidcRoleGroups)
 }
 
 func TestLoadConfigSetValuesInvalidPort(t *testing.T) {
 	assert := assert.New(t)
 
-	assert.Nil(os.Setenv(config.ENV_DATABASE_HOST, "database.local"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_USER, "bobby"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_PASS, "tables"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_PORT, "NotANumber"))
-	assert.Nil(os.Setenv(config.ENV_DATABASE_NAME, "db1"))
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_DATABASE_PORT: "NotANumber",
+		config.ENV_SERVER_PORT:   "waggles",
+	})()
 
-	assert.Nil(os.Setenv(config.ENV_SERVER_HOST, "webserver.local"))
-	assert.Nil(os.Setenv(config.ENV_SERVER_PORT, "waggles"))
-	assert.Nil(os.Setenv(config.ENV_SERVER_PATH, "/var/www/site1"))
+	cfg, err := config.LoadConfig(nil)
 
-	assert.Nil(os.Setenv(config.ENV_MIGRATIONS_PATH, "/var/migrations"))
+	assert.Equal(uint16(3306), cfg.DbPort)
+	assert.Equal(uint16(8080), cfg.ServerPort)
 
-	cfg := config.LoadConfig()
+	assert.Equal(config.InvalidConfigError{Problems: []string{
+		"DB_PORT must be a number from 0 to 65535, not \"NotANumber\"",
+		"SERVER_PORT must be a number from 0 to 65535, not \"waggles\"",
+	}}, err)
+}
 
-	assert.Equal("database.local", cfg.DbHost)
-	assert.Equal("bobby", cfg.DbUser)
-	assert.Equal("tables", cfg.DbPass)
-	assert.Equal(uint16(3306), cfg.DbPort)
+func TestLoadConfigInvalidQueryTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, value := range []string{"10", "soon", "-1s"} {
+		restore := setEnv(t, map[string]string{config.ENV_DATABASE_QUERY_TIMEOUT: value})
+		restoreValid := validEnv(t)
+
+		cfg, err := config.LoadConfig(nil)
+		assert.Equal(10*time.Second, cfg.DbQueryTimeout)
+		assert.Equal(config.InvalidConfigError{Problems: []string{
+			fmt.Sprintf("DB_QUERY_TIMEOUT must be a duration such as \"10s\", not %q", value),
+		}}, err)
+
+		restoreValid()
+		restore()
+	}
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{config.ENV_DATABASE_QUERY_TIMEOUT: "0"})()
+
+	cfg, err := config.LoadConfig(nil)
+	assert.Nil(err)
+	assert.Equal(time.Duration(0), cfg.DbQueryTimeout)
+}
+
+func TestLoadConfigInvalidRatingRange(t *testing.T) {
+	assert := assert.New(t)
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_RATING_MIN: "40000",
+		config.ENV_RATING_MAX: "40000",
+	})()
+
+	_, err := config.LoadConfig(nil)
+	assert.Equal(config.InvalidConfigError{Problems: []string{
+		"RATING_MAX must be at most 32767",
+	}}, err)
+
+	defer setEnv(t, map[string]string{
+		config.ENV_RATING_MIN: "6",
+		config.ENV_RATING_MAX: "5",
+	})()
+
+	_, err = config.LoadConfig(nil)
+	assert.Equal(config.InvalidConfigError{Problems: []string{
+		"RATING_MIN must not be more than RATING_MAX",
+	}}, err)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, removeDir := tempDir(t, map[string]string{
+		"config.yaml": `
+db_host: file.local
+db_user: file
+db_name: file
+db_port: 1
+server_port: 2
+oidc_scopes: [openid, groups]
+oidc_role_groups:
+  it: admin
+  a&r: editor
+`,
+	})
+	defer removeDir()
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_DATABASE_USER: "env",
+		config.ENV_DATABASE_PORT: "3",
+		config.ENV_SERVER_PORT:   "4",
+	})()
+
+	cfg, err := config.LoadConfig([]string{
+		"-config-file", filepath.Join(dir, "config.yaml"),
+		"-server-port", "5",
+	})
+	assert.Nil(err)
+
+	// Flags win over everything
+	assert.Equal(uint16(5), cfg.ServerPort)
+
+	// Then the environment
+	assert.Equal("env", cfg.DbUser)
+	assert.Equal(uint16(3), cfg.DbPort)
+
+	// Then the file, except DB_NAME which validEnv sets
+	assert.Equal("file.local", cfg.DbHost)
 	assert.Equal("db1", cfg.DbName)
+	assert.Equal([]string{"openid", "groups"}, cfg.OidcScopes)
+	assert.Equal(map[string]string{"a&r": "editor", "it": "admin"}, cfg.OidcRoleGroups)
 
-	assert.Equal("webserver.local", cfg.ServerHost)
-	assert.Equal(uint16(8080), cfg.ServerPort)
-	assert.Equal("/var/www/site1", cfg.ServerFilePath)
+	// And finally the defaults
+	assert.Equal("localhost", cfg.ServerHost)
+}
 
-	assert.Equal("/var/migrations", cfg.MigrationsPath)
+func TestLoadConfigFileInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, removeDir := tempDir(t, map[string]string{
+		"config.yaml":  "db_hots: typo.local\n",
+		"broken.yaml":  "db_host: [\n",
+		"unknown.yaml": "config_file: other.yaml\n",
+	})
+	defer removeDir()
+
+	defer validEnv(t)()
+
+	for _, test := range []struct {
+		file     string
+		expected string
+	}{
+		{"config.yaml", "Unknown setting \"db_hots\" in config file"},
+		{"unknown.yaml", "Unknown setting \"config_file\" in config file"},
+		{"broken.yaml", "Unable to parse config file"},
+		{"missing.yaml", "Unable to read config file"},
+	} {
+		_, err := config.LoadConfig([]string{"-config-file", filepath.Join(dir, test.file)})
+
+		invalid, ok := err.(config.InvalidConfigError)
+		assert.True(ok, test.file)
+		assert.Len(invalid.Problems, 1, test.file)
+		assert.Contains(err.Error(), test.expected, test.file)
+	}
+}
+
+func TestLoadConfigFlags(t *testing.T) {
+	assert := assert.New(t)
+
+	defer validEnv(t)()
+
+	_, err := config.LoadConfig([]string{"-h"})
+	assert.Equal(flag.ErrHelp, err)
+
+	_, err = config.LoadConfig([]string{"-not-a-setting", "1"})
+	assert.NotNil(err)
+
+	// Secrets would show up in process listings
+	_, err = config.LoadConfig([]string{"-db-pass", "tables"})
+	assert.NotNil(err)
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, removeDir := tempDir(t, map[string]string{"file": ""})
+	defer removeDir()
+
+	defer setEnv(t, map[string]string{
+		config.ENV_DATABASE_HOST:     "",
+		config.ENV_SERVER_PORT:       "0",
+		config.ENV_SERVER_PATH:       filepath.Join(dir, "missing"),
+		config.ENV_MIGRATIONS_PATH:   filepath.Join(dir, "file"),
+		config.ENV_OIDC_ISSUER:       "sso.citadel.local",
+		config.ENV_OIDC_REDIRECT_URL: "https://intranet.citadel.local/api/v1/auth/oidc/callback",
+		config.ENV_OIDC_ROLE_GROUPS:  "it=boss",
+	})()
+
+	_, err := config.LoadConfig(nil)
+
+	invalid, ok := err.(config.InvalidConfigError)
+	assert.True(ok)
+	assert.Len(invalid.Problems, 9)
+
+	for _, expected := range []string{
+		"DB_HOST must be set",
+		"DB_USER must be set",
+		"DB_NAME must be set",
+		"SERVER_PORT must not be 0",
+		"SERVER_PATH must be a readable directory",
+		"MIGRATIONS must be a readable directory",
+		"OIDC_ISSUER must be an absolute URL, not \"sso.citadel.local\"",
+		"OIDC_CLIENT_ID must be set to use single sign-on",
+		"OIDC_ROLE_GROUPS gives group \"it\" the unknown role \"boss\"",
+	} {
+		assert.Contains(err.Error(), expected)
+	}
+}
+
+func TestLoadConfigSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, removeDir := tempDir(t, map[string]string{
+		config.ENV_DATABASE_PASS:      "tables\n",
+		config.ENV_ADMIN_PASS:         "hunter22",
+		config.ENV_OIDC_CLIENT_SECRET: "shh\r\n",
+	})
+	defer removeDir()
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_SECRET_LOCATION: config.ENV_SECRET_LOCATION_SECRETS,
+		config.ENV_SECRET_PATH:     dir,
+		config.ENV_DATABASE_PASS:   "from the environment",
+	})()
+
+	cfg, err := config.LoadConfig(nil)
+	assert.Nil(err)
+
+	assert.Equal("tables", cfg.DbPass)
+	assert.Equal("hunter22", cfg.AdminPass)
+	assert.Equal("shh", cfg.OidcClientSecret)
+}
+
+func TestLoadConfigSecretsFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, removeDir := tempDir(t, map[string]string{})
+	defer removeDir()
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_SECRET_LOCATION: config.ENV_SECRET_LOCATION_SECRETS,
+		config.ENV_SECRET_PATH:     dir,
+		config.ENV_DATABASE_PASS:   "tables",
+	})()
+
+	cfg, err := config.LoadConfig(nil)
+	assert.Nil(err)
+
+	assert.Equal("tables", cfg.DbPass)
+	assert.Equal("", cfg.AdminPass)
+	assert.Equal("", cfg.OidcClientSecret)
+}
+
+func TestLoadConfigSecretsMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, removeDir := tempDir(t, map[string]string{
+		config.ENV_DATABASE_PASS: "tables",
+	})
+	defer removeDir()
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_SECRET_LOCATION: config.ENV_SECRET_LOCATION_SECRETS,
+		config.ENV_SECRET_PATH:     dir,
+		config.ENV_ADMIN_USER:      "admin",
+	})()
+
+	_, err := config.LoadConfig(nil)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "Missing secret ADMIN_PASS")
+	assert.NotContains(err.Error(), config.ENV_DATABASE_PASS)
+	assert.NotContains(err.Error(), config.ENV_OIDC_CLIENT_SECRET)
+}
+
+func TestLoadConfigSecretsUnreadable(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, removeDir := tempDir(t, map[string]string{})
+	defer removeDir()
+
+	// A directory where the file should be can't be read by anyone
+	assert.Nil(os.Mkdir(filepath.Join(dir, config.ENV_DATABASE_PASS), 0700))
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_SECRET_LOCATION: config.ENV_SECRET_LOCATION_SECRETS,
+		config.ENV_SECRET_PATH:     dir,
+	})()
+
+	_, err := config.LoadConfig(nil)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "Unable to read secret DB_PASS")
+}
+
+func TestLoadConfigSecretLocationInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	defer validEnv(t)()
+	defer setEnv(t, map[string]string{
+		config.ENV_SECRET_LOCATION: "vault",
+	})()
+
+	_, err := config.LoadConfig(nil)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "SECRET_LOCATION")
 }
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+Read a secret, from a file named after it in the secret path when
+SECRET_LOCATION is "secrets", Docker and Kubernetes style. A missing file
+falls back to the other sources, and is only a problem if the secret is
+required.
+*/
+func (this *sources) secret(key string, required bool) string {
+	if this.secretLocation != ENV_SECRET_LOCATION_SECRETS {
+		return this.string(key, "")
+	}
+
+	file := filepath.Join(this.secretPath, key)
+	contents, err := ioutil.ReadFile(file)
+	if err == nil {
+		// Editors like to end files with a newline, which is never part of
+		// the secret.
+		return strings.TrimRight(string(contents), "\r\n")
+	}
+
+	if !os.IsNotExist(err) {
+		this.problem("Unable to read secret %s: %s", key, err.Error())
+		return ""
+	}
+
+	if val, found := this.lookup(key); found {
+		logrus.Warn("No secret file at ", file, ", using ", key, " from the environment or config file instead")
+		return val
+	}
+
+	if required {
+		this.problem("Missing secret %s: no file at %s and %s is not set", key, file, key)
+	}
+	return ""
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+A setting that can be configured, and what it's for.
+*/
+type setting struct {
+	key   string
+	usage string
+
+	// Secrets can't be given as flags, where anyone able to list processes
+	// could read them.
+	secret bool
+}
+
+var settings = []setting{
+	{key: ENV_CONFIG_FILE, usage: "YAML file to read settings from"},
+	{key: ENV_SECRET_LOCATION, usage: "where to read secrets from, environment or secrets"},
+	{key: ENV_SECRET_PATH, usage: "directory holding secret files"},
+
+	{key: ENV_DATABASE_HOST, usage: "hostname or IP address of the database"},
+	{key: ENV_DATABASE_USER, usage: "username for the database"},
+	{key: ENV_DATABASE_PASS, secret: true},
+	{key: ENV_DATABASE_PORT, usage: "port number of the database"},
+	{key: ENV_DATABASE_NAME, usage: "name of the database to use"},
+	{key: ENV_DATABASE_QUERY_TIMEOUT, usage: "longest a request may spend on the database, 0 for no limit"},
+
+	{key: ENV_SERVER_HOST, usage: "hostname to bind the server to"},
+	{key: ENV_SERVER_PORT, usage: "port number to serve on"},
+	{key: ENV_SERVER_PATH, usage: "directory to serve static files from"},
+
+	{key: ENV_MIGRATIONS_PATH, usage: "directory to look for database migrations in"},
+
+	{key: ENV_RATING_MIN, usage: "lowest rating an album or track may be given"},
+	{key: ENV_RATING_MAX, usage: "highest rating an album or track may be given"},
+
+	{key: ENV_ADMIN_USER, usage: "admin user to create on startup"},
+	{key: ENV_ADMIN_PASS, secret: true},
+
+	{key: ENV_OIDC_ISSUER, usage: "issuer URL of the OpenID Connect provider"},
+	{key: ENV_OIDC_CLIENT_ID, usage: "client ID registered with the provider"},
+	{key: ENV_OIDC_CLIENT_SECRET, secret: true},
+	{key: ENV_OIDC_REDIRECT_URL, usage: "where the provider sends people back to"},
+	{key: ENV_OIDC_SCOPES, usage: "comma separated scopes to ask the provider for"},
+	{key: ENV_OIDC_GROUPS_CLAIM, usage: "ID token claim holding the user's groups"},
+	{key: ENV_OIDC_ALLOWED_GROUPS, usage: "comma separated groups allowed to log in"},
+	{key: ENV_OIDC_ROLE_GROUPS, usage: "comma separated group=role pairs"},
+}
+
+/*
+The flag for a setting, e.g. DB_HOST is -db-host
+*/
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+/*
+The name of a setting in a config file, e.g. DB_HOST is db_host
+*/
+func fileKey(key string) string {
+	return strings.ToLower(key)
+}
+
+/*
+Everywhere settings can come from, looked at in order: flags, environment
+variables, the config file, and finally secret files for secrets.
+
+Anything wrong with the values found is collected in problems rather than
+stopping at the first one.
+*/
+type sources struct {
+	flags map[string]string
+	file  map[string]string
+
+	secretLocation string
+	secretPath     string
+
+	problems []string
+}
+
+/*
+Parse the command line flags, and the config file if one was given.
+
+Returns the sources, and an error if the flags couldn't be parsed, which is
+flag.ErrHelp if help was asked for
+*/
+func newSources(args []string) (*sources, error) {
+	this := &sources{
+		flags: make(map[string]string),
+		file:  make(map[string]string),
+	}
+
+	flags := flag.NewFlagSet("citadel_intranet", flag.ContinueOnError)
+	keys := make(map[string]string)
+	for _, setting := range settings {
+		if !setting.secret {
+			flags.String(flagName(setting.key), "", fmt.Sprintf("%s (%s)", setting.usage, setting.key))
+			keys[flagName(setting.key)] = setting.key
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Only flags that were actually given, so that they don't hide the
+	// other sources with empty values
+	flags.Visit(func(given *flag.Flag) {
+		this.flags[keys[given.Name]] = given.Value.String()
+	})
+
+	if path := this.string(ENV_CONFIG_FILE, ""); path != "" {
+		this.readFile(path)
+	}
+
+	this.secretLocation = this.string(ENV_SECRET_LOCATION, ENV_SECRET_LOCATION_ENVIRONMENT)
+	this.secretPath = this.string(ENV_SECRET_PATH, "/run/secrets")
+
+	if this.secretLocation != ENV_SECRET_LOCATION_ENVIRONMENT && this.secretLocation != ENV_SECRET_LOCATION_SECRETS {
+		this.problem("%s must be %q or %q, not %q", ENV_SECRET_LOCATION,
+			ENV_SECRET_LOCATION_ENVIRONMENT, ENV_SECRET_LOCATION_SECRETS, this.secretLocation)
+	}
+
+	return this, nil
+}
+
+func (this *sources) problem(format string, args ...interface{}) {
+	this.problems = append(this.problems, fmt.Sprintf(format, args...))
+}
+
+/*
+Read settings from a YAML file of lowercase setting names, lists and maps are
+allowed for settings that take them.
+*/
+func (this *sources) readFile(path string) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		this.problem("Unable to read config file: %s", err.Error())
+		return
+	}
+
+	var values map[string]interface{}
+	if err = yaml.Unmarshal(contents, &values); err != nil {
+		this.problem("Unable to parse config file %s: %s", path, err.Error())
+		return
+	}
+
+	known := make(map[string]string)
+	for _, setting := range settings {
+		known[fileKey(setting.key)] = setting.key
+	}
+
+	for name, value := range values {
+		key, found := known[name]
+		if !found || key == ENV_CONFIG_FILE {
+			this.problem("Unknown setting %q in config file %s", name, path)
+			continue
+		}
+
+		switch value := value.(type) {
+		case []interface{}:
+			entries := make([]string, len(value))
+			for index, entry := range value {
+				entries[index] = fmt.Sprint(entry)
+			}
+			this.file[key] = strings.Join(entries, ",")
+		case map[string]interface{}:
+			entries := make([]string, 0, len(value))
+			for entry, entryValue := range value {
+				entries = append(entries, fmt.Sprintf("%s=%v", entry, entryValue))
+			}
+			sort.Strings(entries)
+			this.file[key] = strings.Join(entries, ",")
+		case nil:
+			this.file[key] = ""
+		default:
+			this.file[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+func (this *sources) lookup(key string) (string, bool) {
+	if val, found := this.flags[key]; found {
+		return val, true
+	}
+
+	if val, found := os.LookupEnv(key); found {
+		return val, true
+	}
+
+	val, found := this.file[key]
+	return val, found
+}
+
+func (this *sources) string(key string, defaultValue string) string {
+	if val, found := this.lookup(key); found {
+		return val
+	}
+	return defaultValue
+}
+
+func (this *sources) uint16(key string, defaultValue uint16) uint16 {
+	val, found := this.lookup(key)
+	if !found {
+		return defaultValue
+	}
+
+	ret, err := strconv.ParseUint(strings.TrimSpace(val), 10, 16)
+	if err != nil {
+		this.problem("%s must be a number from 0 to 65535, not %q", key, val)
+		return defaultValue
+	}
+	return uint16(ret)
+}
+
+/*
+Read a duration such as "10s" or "1m30s", negative durations aren't allowed
+*/
+func (this *sources) duration(key string, defaultValue time.Duration) time.Duration {
+	val, found := this.lookup(key)
+	if !found {
+		return defaultValue
+	}
+
+	ret, err := time.ParseDuration(strings.TrimSpace(val))
+	if err != nil || ret < 0 {
+		this.problem("%s must be a duration such as \"10s\", not %q", key, val)
+		return defaultValue
+	}
+	return ret
+}
+
+/*
+Read a comma separated list, ignoring blank entries
+*/
+func (this *sources) list(key string, defaultValue []string) []string {
+	val, found := this.lookup(key)
+	if !found {
+		return defaultValue
+	}
+
+	ret := make([]string, 0)
+	for _, entry := range strings.Split(val, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			ret = append(ret, entry)
+		}
+	}
+	return ret
+}
+
+/*
+Read a comma separated list of key=value pairs, ignoring blank entries
+*/
+func (this *sources) stringMap(key string, defaultValue map[string]string) map[string]string {
+	if _, found := this.lookup(key); !found {
+		return defaultValue
+	}
+
+	ret := make(map[string]string)
+	for _, entry := range this.list(key, nil) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			this.problem("%s entries must look like key=value, not %q", key, entry)
+			continue
+		}
+		ret[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return ret
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"citadel_intranet/src/db/model"
+)
+
+const maxRating = 32767
+
+/*
+Every problem found with a configuration, so that they can all be fixed in one
+go rather than one restart at a time.
+*/
+type InvalidConfigError struct {
+	Problems []string
+}
+
+func (this InvalidConfigError) Error() string {
+	return fmt.Sprintf("Invalid configuration:\n  - %s", strings.Join(this.Problems, "\n  - "))
+}
+
+/*
+Check that a configuration is usable, returning a description of everything
+that isn't
+*/
+func (this Config) Validate() []string {
+	problems := make([]string, 0)
+
+	for key, value := range map[string]string{
+		ENV_DATABASE_HOST: this.DbHost,
+		ENV_DATABASE_USER: this.DbUser,
+		ENV_DATABASE_NAME: this.DbName,
+	} {
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s must be set", key))
+		}
+	}
+
+	if this.ServerPort == 0 {
+		problems = append(problems, fmt.Sprintf("%s must not be 0", ENV_SERVER_PORT))
+	}
+
+	// Ratings are stored in a signed SMALLINT
+	if this.RatingMax > maxRating {
+		problems = append(problems, fmt.Sprintf("%s must be at most %d", ENV_RATING_MAX, maxRating))
+	}
+	if this.RatingMin > this.RatingMax {
+		problems = append(problems, fmt.Sprintf("%s must not be more than %s", ENV_RATING_MIN, ENV_RATING_MAX))
+	}
+
+	for key, path := range map[string]string{
+		ENV_SERVER_PATH:     this.ServerFilePath,
+		ENV_MIGRATIONS_PATH: this.MigrationsPath,
+	} {
+		if err := checkDirectory(path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s must be a readable directory: %s", key, err.Error()))
+		}
+	}
+
+	if this.OidcIssuer != "" {
+		problems = append(problems, this.validateOidc()...)
+	}
+
+	// Map iteration order is random, keep the output stable
+	sort.Strings(problems)
+	return problems
+}
+
+func (this Config) validateOidc() []string {
+	problems := make([]string, 0)
+
+	for key, value := range map[string]string{
+		ENV_OIDC_ISSUER:       this.OidcIssuer,
+		ENV_OIDC_REDIRECT_URL: this.OidcRedirectUrl,
+	} {
+		if parsed, err := url.Parse(value); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s must be an absolute URL, not %q", key, value))
+		}
+	}
+
+	if this.OidcClientId == "" {
+		problems = append(problems, fmt.Sprintf("%s must be set to use single sign-on", ENV_OIDC_CLIENT_ID))
+	}
+
+	for group, role := range this.OidcRoleGroups {
+		valid := false
+		for _, candidate := range model.Roles {
+			valid = valid || string(candidate) == role
+		}
+
+		if !valid {
+			problems = append(problems, fmt.Sprintf("%s gives group %q the unknown role %q", ENV_OIDC_ROLE_GROUPS, group, role))
+		}
+	}
+
+	return problems
+}
+
+func checkDirectory(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	// Reading an entry proves that it's a directory we're allowed to list
+	if _, err = dir.Readdirnames(1); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"citadel_intranet/src/config"
 	"citadel_intranet/src/db/dao"
@@ -13,22 +15,39 @@ import (
 )
 
 type DatabaseClient struct {
-	Db     *sql.DB
-	Artist dao.ArtistDao
-	Album  dao.AlbumDao
-	Track  dao.TrackDao
+	Db       *sql.DB
+	Artist   dao.ArtistDao
+	Album    dao.AlbumDao
+	Track    dao.TrackDao
+	Proposal dao.ProposalDao
+	Comment  dao.CommentDao
+	User     dao.UserDao
+	Session  dao.SessionDao
+	ApiToken dao.ApiTokenDao
+
+	// Hands out artist, album and track DAOs bound to a single transaction.
+	Transactor dao.Transactor
+
+	// How long the queries made for a single request may take altogether,
+	// there's no limit when it's 0.
+	QueryTimeout time.Duration
 }
 
 func NewDatabaseClientFromConnection(db *sql.DB) DatabaseClient {
-	client := DatabaseClient{
-		Db:     db,
-		Artist: mysql.NewArtistDao(db),
-		Album:  nil,
-		Track:  mysql.NewTrackDao(db),
-	}
-	client.Album = mysql.NewAlbumDao(client.Db, client.Artist, client.Track)
+	work := newUnitOfWork(db)
 
-	return client
+	return DatabaseClient{
+		Db:         db,
+		Artist:     work.Artist,
+		Album:      work.Album,
+		Track:      work.Track,
+		Proposal:   mysql.NewProposalDao(db),
+		Comment:    mysql.NewCommentDao(db),
+		User:       mysql.NewUserDao(db),
+		Session:    mysql.NewSessionDao(db),
+		ApiToken:   mysql.NewApiTokenDao(db),
+		Transactor: NewTransactor(db),
+	}
 }
 
 func NewDatabaseClient(cfg config.Config) DatabaseClient {
@@ -37,7 +56,33 @@ func NewDatabaseClient(cfg config.Config) DatabaseClient {
 		logrus.Panic("Unable to connect to database: ", err.Error())
 	}
 
-	return NewDatabaseClientFromConnection(db)
+	client := NewDatabaseClientFromConnection(db)
+	client.QueryTimeout = cfg.DbQueryTimeout
+
+	return client
+}
+
+/*
+Check that the database can be reached.
+*/
+func (this DatabaseClient) Ping(ctx context.Context) error {
+	return this.Db.PingContext(ctx)
+}
+
+/*
+Check that every migration in migrationsPath has been applied.
+*/
+func (this DatabaseClient) CheckMigrations(ctx context.Context, migrationsPath string) error {
+	return CheckMigrations(ctx, this.Db, migrationsPath)
+}
+
+/*
+Run work within a single transaction, handing it artist, album and track DAOs
+bound to that transaction. Everything saved through them is committed when
+work returns nil and rolled back otherwise.
+*/
+func (this DatabaseClient) InTransaction(ctx context.Context, work func(dao.UnitOfWork) error) error {
+	return this.Transactor.InTransaction(ctx, work)
 }
 
 func (this DatabaseClient) Close() {
@@ -53,6 +98,26 @@ func (this DatabaseClient) Close() {
 		this.Track.Close()
 	}
 
+	if this.Proposal != nil {
+		this.Proposal.Close()
+	}
+
+	if this.Comment != nil {
+		this.Comment.Close()
+	}
+
+	if this.User != nil {
+		this.User.Close()
+	}
+
+	if this.Session != nil {
+		this.Session.Close()
+	}
+
+	if this.ApiToken != nil {
+		this.ApiToken.Close()
+	}
+
 	if this.Db != nil {
 		this.Db.Close()
 	}
@@ -60,7 +125,7 @@ func (this DatabaseClient) Close() {
 
 func getConnectionString(cfg config.Config) string {
 	return fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s",
+		"%s:%s@tcp(%s:%d)/%s?parseTime=true",
 		cfg.DbUser,
 		cfg.DbPass,
 		cfg.DbHost,
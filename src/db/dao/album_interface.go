@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"context"
+
 	"citadel_intranet/src/db/model"
 )
 
@@ -13,26 +15,57 @@ type AlbumDao interface {
 	/*
 	   Load all albums from the database.
 	*/
-	LoadAll() []model.Album
+	LoadAll(context.Context) ([]model.Album, error)
 
 	/*
-	   Load a single album based on id, will return nil if the album cannot be
-	   found.
+	   Load a single album based on id.
+
+	   Returns the album and an error, which wraps ErrNotFound if there's no
+	   such album
 	*/
-	Load(int64) *model.Album
+	Load(context.Context, int64) (*model.Album, error)
+
+	/*
+	   Load the albums matching a query, filtering, sorting, and paging on the
+	   database side.
+
+	   Returns the requested page of albums, the total number of albums
+	   matching the query (ignoring paging), and an error
+	*/
+	Query(context.Context, AlbumQuery) ([]model.Album, int64, error)
 
 	/*
 	   Save an album. This should perform an upsert style insert or update to an
-	   album in the case where it already exists.
+	   album in the case where it already exists. The state of an album is
+	   never changed by saving it, new albums always start as drafts.
+
+	   Every save bumps the album's version. When an existing album is saved
+	   with a version, it is only updated if that is still its current
+	   version.
 
-	   Returns the last inserted id and an error
+	   Returns the last inserted id and an error, which wraps ErrConflict if
+	   the album has changed since (or no longer exists)
+	*/
+	Save(context.Context, model.Album) (int64, error)
+
+	/*
+	   Move an album from one workflow state to another, recording who did so
+	   in the album's history. Both happen within a single transaction.
+
+	   Returns an error, which wraps ErrConflict if the album was no longer in
+	   the expected state
+	*/
+	SetState(ctx context.Context, change model.AlbumStateChange) error
+
+	/*
+	   Load the workflow history for an album, oldest change first.
 	*/
-	Save(model.Album) (int64, error)
+	LoadHistory(context.Context, int64) ([]model.AlbumStateChange, error)
 
 	/*
 	   Delete an album, based on the id of the album.
 
 	   Returns the affected rows and an error
 	*/
-	Delete(model.Album) (int64, error)
+	Delete(context.Context, model.Album) (int64, error)
 }
@@ -0,0 +1,67 @@
+package dao
+
+import (
+	"citadel_intranet/src/db/model"
+)
+
+/*
+Keys that albums can be sorted by when querying.
+*/
+type AlbumSortKey string
+
+const (
+	AlbumSortId     AlbumSortKey = "id"
+	AlbumSortTitle  AlbumSortKey = "title"
+	AlbumSortRating AlbumSortKey = "rating"
+	AlbumSortState  AlbumSortKey = "state"
+)
+
+/*
+All of the sort keys a backend is expected to support.
+*/
+var AlbumSortKeys = []AlbumSortKey{
+	AlbumSortId,
+	AlbumSortTitle,
+	AlbumSortRating,
+	AlbumSortState,
+}
+
+/*
+Names sort keys used to go by, still accepted so that older clients keep
+working. Albums were sorted by their published flag before they had states.
+*/
+var AlbumSortAliases = map[string]AlbumSortKey{
+	"published": AlbumSortState,
+}
+
+/*
+Filtering, sorting and paging options used when querying for albums. The zero
+value matches every album, sorted by id.
+*/
+type AlbumQuery struct {
+	// Only include albums by this artist, 0 matches any artist.
+	ArtistId int64
+
+	// Only include albums in this state, "" matches any state.
+	State model.AlbumState
+
+	// Only include albums that are (or aren't) published, nil matches both.
+	Published *bool
+
+	// Only include albums rated at least this highly.
+	MinRating uint
+
+	// Only include albums whose title contains this substring.
+	Title string
+
+	// Field to sort on, defaults to the album id. Ties are always broken by id
+	// so that paging is stable.
+	SortBy     AlbumSortKey
+	Descending bool
+
+	// Maximum number of albums to return, 0 returns every matching album.
+	Limit uint
+
+	// Number of matching albums to skip, ignored when Limit is 0.
+	Offset uint
+}
@@ -0,0 +1,45 @@
+package dao
+
+import (
+	"time"
+
+	"citadel_intranet/src/db/model"
+)
+
+type ApiTokenDao interface {
+	BaseDao
+
+	/*
+	   Load all of a user's tokens, expired ones included
+	*/
+	LoadForUser(int64) []model.ApiToken
+
+	/*
+	   Load a token from the hash of its secret
+
+	   Returns nil if no token is found
+	*/
+	LoadByHash(string) *model.ApiToken
+
+	/*
+	   Save a new token
+
+	   Returns the token's id and an error
+	*/
+	Save(model.ApiToken) (int64, error)
+
+	/*
+	   Record when a token was last used
+
+	   Returns an error
+	*/
+	Touch(int64, time.Time) error
+
+	/*
+	   Delete a token based on its id, as long as it belongs to the token's
+	   user
+
+	   Returns rows affected and an error
+	*/
+	Delete(model.ApiToken) (int64, error)
+}
@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"context"
+
 	"citadel_intranet/src/db/model"
 )
 
@@ -13,26 +15,42 @@ type ArtistDao interface {
 	/*
 	   Load all artists
 	*/
-	LoadAll() []model.Artist
+	LoadAll(context.Context) ([]model.Artist, error)
 
 	/*
 	   Load an artist from their id
 
-	   Returns nil if no artist is found
+	   Returns the artist and an error, which wraps ErrNotFound if no artist
+	   is found
+	*/
+	Load(context.Context, int64) (*model.Artist, error)
+
+	/*
+	   Load an artist by their (unique) name
+
+	   Returns the artist and an error, which wraps ErrNotFound if no artist
+	   is found
+	*/
+	LoadByName(context.Context, string) (*model.Artist, error)
+
+	/*
+	   Load every artist whose id is in the given list, in a single round trip.
+	   Ids that cannot be found are skipped.
 	*/
-	Load(int64) *model.Artist
+	LoadMany(context.Context, []int64) ([]model.Artist, error)
 
 	/*
 	   Save an artist via upsert.
 
-	   Returns the last inserted id and an error
+	   Returns the last inserted id and an error, the error wraps ErrDuplicate
+	   when the name is already taken by another artist.
 	*/
-	Save(model.Artist) (int64, error)
+	Save(context.Context, model.Artist) (int64, error)
 
 	/*
 	   Delete an artist based on its id
 
 	   Returns rows affected and an error
 	*/
-	Delete(model.Artist) (int64, error)
+	Delete(context.Context, model.Artist) (int64, error)
 }
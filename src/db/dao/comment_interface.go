@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"context"
+
+	"citadel_intranet/src/db/model"
+)
+
+type CommentDao interface {
+	BaseDao
+
+	/*
+	   Load a comment from its id, deleted comments included
+
+	   Returns the comment and an error, which wraps ErrNotFound if no comment
+	   is found
+	*/
+	Load(context.Context, int64) (*model.Comment, error)
+
+	/*
+	   Load every comment on an album or track, oldest first, deleted comments
+	   included. Comments are returned flat, without their Replies filled in.
+	*/
+	LoadFor(context.Context, model.Target, int64) ([]model.Comment, error)
+
+	/*
+	   Count the comments, ignoring deleted ones, left directly on each of the
+	   given albums in a single round trip.
+
+	   Returns the counts keyed by album id, albums without any comments are
+	   left out of the map, and an error
+	*/
+	CountForAlbums(context.Context, []int64) (map[int64]int64, error)
+
+	/*
+	   Save a comment via upsert. Only the body, resolution and edit and delete
+	   timestamps of an existing comment are updated.
+
+	   Returns the last inserted id and an error
+	*/
+	Save(context.Context, model.Comment) (int64, error)
+}
@@ -0,0 +1,28 @@
+package dao
+
+import (
+	"errors"
+)
+
+/*
+Returned (possibly wrapped) when a write would violate a uniqueness
+constraint, such as two artists sharing a name.
+*/
+var ErrDuplicate = errors.New("Duplicate entry")
+
+/*
+Returned (possibly wrapped) when a write was based on data that has since been
+changed by someone else.
+*/
+var ErrConflict = errors.New("Conflicting update")
+
+/*
+Returned (possibly wrapped) when loading something that doesn't exist.
+*/
+var ErrNotFound = errors.New("Not found")
+
+/*
+Returned (possibly wrapped) when the database can't be reached, or didn't
+answer in time. Unlike other errors, trying again later may well work.
+*/
+var ErrUnavailable = errors.New("Database unavailable")
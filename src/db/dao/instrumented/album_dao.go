@@ -0,0 +1,84 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+)
+
+const albumDaoName = "album"
+
+type albumDao struct {
+	dao dao.AlbumDao
+}
+
+/*
+Wrap an album DAO, recording how long each call takes and how often it fails.
+*/
+func NewAlbumDao(inner dao.AlbumDao) dao.AlbumDao {
+	return albumDao{
+		dao: inner,
+	}
+}
+
+func (this albumDao) Close() {
+	this.dao.Close()
+}
+
+func (this albumDao) LoadAll(ctx context.Context) ([]model.Album, error) {
+	start := time.Now()
+	albums, err := this.dao.LoadAll(ctx)
+	observe(albumDaoName, "LoadAll", start, err)
+
+	return albums, err
+}
+
+func (this albumDao) Load(ctx context.Context, id int64) (*model.Album, error) {
+	start := time.Now()
+	album, err := this.dao.Load(ctx, id)
+	observe(albumDaoName, "Load", start, err)
+
+	return album, err
+}
+
+func (this albumDao) Query(ctx context.Context, query dao.AlbumQuery) ([]model.Album, int64, error) {
+	start := time.Now()
+	albums, total, err := this.dao.Query(ctx, query)
+	observe(albumDaoName, "Query", start, err)
+
+	return albums, total, err
+}
+
+func (this albumDao) Save(ctx context.Context, album model.Album) (int64, error) {
+	start := time.Now()
+	id, err := this.dao.Save(ctx, album)
+	observe(albumDaoName, "Save", start, err)
+
+	return id, err
+}
+
+func (this albumDao) SetState(ctx context.Context, change model.AlbumStateChange) error {
+	start := time.Now()
+	err := this.dao.SetState(ctx, change)
+	observe(albumDaoName, "SetState", start, err)
+
+	return err
+}
+
+func (this albumDao) LoadHistory(ctx context.Context, id int64) ([]model.AlbumStateChange, error) {
+	start := time.Now()
+	history, err := this.dao.LoadHistory(ctx, id)
+	observe(albumDaoName, "LoadHistory", start, err)
+
+	return history, err
+}
+
+func (this albumDao) Delete(ctx context.Context, album model.Album) (int64, error) {
+	start := time.Now()
+	rows, err := this.dao.Delete(ctx, album)
+	observe(albumDaoName, "Delete", start, err)
+
+	return rows, err
+}
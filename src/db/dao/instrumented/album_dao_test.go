@@ -0,0 +1,78 @@
+package instrumented_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/dao/instrumented"
+	"citadel_intranet/src/db/dao/mock"
+	"citadel_intranet/src/db/model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlbumDao(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	album := model.Album{Id: 1, Title: "Waffle Irons"}
+	failure := errors.New("Something bad happened")
+
+	inner := mock.NewMockAlbumDao(ctrl)
+	inner.EXPECT().LoadAll(gomock.Eq(ctx)).Return([]model.Album{album}, nil).Times(1)
+	inner.EXPECT().Load(gomock.Eq(ctx), gomock.Eq(int64(1))).Return(nil, fmt.Errorf("%w: album 1", dao.ErrNotFound)).Times(1)
+	inner.EXPECT().Query(gomock.Eq(ctx), gomock.Any()).Return([]model.Album{album}, int64(1), nil).Times(1)
+	inner.EXPECT().Save(gomock.Eq(ctx), gomock.Eq(album)).Return(int64(1), nil).Times(1)
+	inner.EXPECT().Save(gomock.Eq(ctx), gomock.Eq(model.Album{})).Return(int64(0), failure).Times(1)
+	inner.EXPECT().SetState(gomock.Eq(ctx), gomock.Any()).Return(failure).Times(1)
+	inner.EXPECT().LoadHistory(gomock.Eq(ctx), gomock.Eq(int64(1))).Return(nil, failure).Times(1)
+	inner.EXPECT().Delete(gomock.Eq(ctx), gomock.Eq(album)).Return(int64(1), nil).Times(1)
+	inner.EXPECT().Close().Times(1)
+
+	albumDao := instrumented.NewAlbumDao(inner)
+	defer albumDao.Close()
+
+	albums, err := albumDao.LoadAll(ctx)
+	assert.Equal([]model.Album{album}, albums)
+	assert.Nil(err)
+
+	_, err = albumDao.Load(ctx, 1)
+	assert.True(errors.Is(err, dao.ErrNotFound))
+
+	albums, total, err := albumDao.Query(ctx, dao.AlbumQuery{})
+	assert.Equal([]model.Album{album}, albums)
+	assert.Equal(int64(1), total)
+	assert.Nil(err)
+
+	id, err := albumDao.Save(ctx, album)
+	assert.Equal(int64(1), id)
+	assert.Nil(err)
+
+	_, err = albumDao.Save(ctx, model.Album{})
+	assert.Equal(failure, err)
+
+	assert.Equal(failure, albumDao.SetState(ctx, model.AlbumStateChange{}))
+
+	_, err = albumDao.LoadHistory(ctx, 1)
+	assert.Equal(failure, err)
+
+	rows, err := albumDao.Delete(ctx, album)
+	assert.Equal(int64(1), rows)
+	assert.Nil(err)
+
+	for method, count := range map[string]uint64{"LoadAll": 1, "Load": 1, "Query": 1, "Save": 2, "SetState": 1, "LoadHistory": 1, "Delete": 1} {
+		assert.Equal(count, callCount("album", method), method)
+	}
+
+	assert.Equal(float64(0), errorCount("album", "Load"))
+	assert.Equal(float64(1), errorCount("album", "Save"))
+	assert.Equal(float64(1), errorCount("album", "SetState"))
+	assert.Equal(float64(1), errorCount("album", "LoadHistory"))
+	assert.Equal(float64(0), errorCount("album", "Delete"))
+}
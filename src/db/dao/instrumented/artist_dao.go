@@ -0,0 +1,76 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+)
+
+const artistDaoName = "artist"
+
+type artistDao struct {
+	dao dao.ArtistDao
+}
+
+/*
+Wrap an artist DAO, recording how long each call takes and how often it fails.
+*/
+func NewArtistDao(inner dao.ArtistDao) dao.ArtistDao {
+	return artistDao{
+		dao: inner,
+	}
+}
+
+func (this artistDao) Close() {
+	this.dao.Close()
+}
+
+func (this artistDao) LoadAll(ctx context.Context) ([]model.Artist, error) {
+	start := time.Now()
+	artists, err := this.dao.LoadAll(ctx)
+	observe(artistDaoName, "LoadAll", start, err)
+
+	return artists, err
+}
+
+func (this artistDao) Load(ctx context.Context, id int64) (*model.Artist, error) {
+	start := time.Now()
+	artist, err := this.dao.Load(ctx, id)
+	observe(artistDaoName, "Load", start, err)
+
+	return artist, err
+}
+
+func (this artistDao) LoadByName(ctx context.Context, name string) (*model.Artist, error) {
+	start := time.Now()
+	artist, err := this.dao.LoadByName(ctx, name)
+	observe(artistDaoName, "LoadByName", start, err)
+
+	return artist, err
+}
+
+func (this artistDao) LoadMany(ctx context.Context, ids []int64) ([]model.Artist, error) {
+	start := time.Now()
+	artists, err := this.dao.LoadMany(ctx, ids)
+	observe(artistDaoName, "LoadMany", start, err)
+
+	return artists, err
+}
+
+func (this artistDao) Save(ctx context.Context, artist model.Artist) (int64, error) {
+	start := time.Now()
+	id, err := this.dao.Save(ctx, artist)
+	observe(artistDaoName, "Save", start, err)
+
+	return id, err
+}
+
+func (this artistDao) Delete(ctx context.Context, artist model.Artist) (int64, error) {
+	start := time.Now()
+	rows, err := this.dao.Delete(ctx, artist)
+	observe(artistDaoName, "Delete", start, err)
+
+	return rows, err
+}
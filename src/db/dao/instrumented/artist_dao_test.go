@@ -0,0 +1,68 @@
+package instrumented_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/dao/instrumented"
+	"citadel_intranet/src/db/dao/mock"
+	"citadel_intranet/src/db/model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtistDao(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	artist := model.Artist{Id: 42, Name: "James"}
+	failure := errors.New("Something bad happened")
+
+	inner := mock.NewMockArtistDao(ctrl)
+	inner.EXPECT().LoadAll(gomock.Eq(ctx)).Return([]model.Artist{artist}, nil).Times(1)
+	inner.EXPECT().Load(gomock.Eq(ctx), gomock.Eq(int64(42))).Return(&artist, nil).Times(1)
+	inner.EXPECT().LoadByName(gomock.Eq(ctx), gomock.Eq("Bobby")).Return(nil, dao.ErrNotFound).Times(1)
+	inner.EXPECT().LoadMany(gomock.Eq(ctx), gomock.Eq([]int64{42})).Return(nil, failure).Times(1)
+	inner.EXPECT().Save(gomock.Eq(ctx), gomock.Eq(artist)).Return(int64(0), dao.ErrDuplicate).Times(1)
+	inner.EXPECT().Delete(gomock.Eq(ctx), gomock.Eq(artist)).Return(int64(1), nil).Times(1)
+	inner.EXPECT().Close().Times(1)
+
+	artistDao := instrumented.NewArtistDao(inner)
+	defer artistDao.Close()
+
+	artists, err := artistDao.LoadAll(ctx)
+	assert.Equal([]model.Artist{artist}, artists)
+	assert.Nil(err)
+
+	loaded, err := artistDao.Load(ctx, 42)
+	assert.Equal(&artist, loaded)
+	assert.Nil(err)
+
+	_, err = artistDao.LoadByName(ctx, "Bobby")
+	assert.Equal(dao.ErrNotFound, err)
+
+	_, err = artistDao.LoadMany(ctx, []int64{42})
+	assert.Equal(failure, err)
+
+	_, err = artistDao.Save(ctx, artist)
+	assert.Equal(dao.ErrDuplicate, err)
+
+	rows, err := artistDao.Delete(ctx, artist)
+	assert.Equal(int64(1), rows)
+	assert.Nil(err)
+
+	for _, method := range []string{"LoadAll", "Load", "LoadByName", "LoadMany", "Save", "Delete"} {
+		assert.Equal(uint64(1), callCount("artist", method), method)
+	}
+
+	// Looking up something that isn't there isn't a failure
+	assert.Equal(float64(0), errorCount("artist", "LoadByName"))
+	assert.Equal(float64(1), errorCount("artist", "LoadMany"))
+	assert.Equal(float64(1), errorCount("artist", "Save"))
+	assert.Equal(float64(0), errorCount("artist", "Delete"))
+}
@@ -0,0 +1,22 @@
+package instrumented_test
+
+import (
+	"citadel_intranet/src/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+/*
+How many calls have been timed for a DAO method.
+*/
+func callCount(dao string, method string) uint64 {
+	var metric dto.Metric
+	metrics.DaoCallDuration.WithLabelValues(dao, method).(prometheus.Histogram).Write(&metric)
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func errorCount(dao string, method string) float64 {
+	return testutil.ToFloat64(metrics.DaoErrors.WithLabelValues(dao, method))
+}
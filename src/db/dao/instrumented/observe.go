@@ -0,0 +1,21 @@
+package instrumented
+
+import (
+	"errors"
+	"time"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/metrics"
+)
+
+/*
+Record a DAO call, without counting a load of something that isn't there as a
+failure.
+*/
+func observe(daoName string, method string, start time.Time, err error) {
+	if errors.Is(err, dao.ErrNotFound) {
+		err = nil
+	}
+
+	metrics.ObserveDaoCall(daoName, method, start, err)
+}
@@ -0,0 +1,84 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+)
+
+const trackDaoName = "track"
+
+type trackDao struct {
+	dao dao.TrackDao
+}
+
+/*
+Wrap a track DAO, recording how long each call takes and how often it fails.
+*/
+func NewTrackDao(inner dao.TrackDao) dao.TrackDao {
+	return trackDao{
+		dao: inner,
+	}
+}
+
+func (this trackDao) Close() {
+	this.dao.Close()
+}
+
+func (this trackDao) LoadAll(ctx context.Context) ([]model.Track, error) {
+	start := time.Now()
+	tracks, err := this.dao.LoadAll(ctx)
+	observe(trackDaoName, "LoadAll", start, err)
+
+	return tracks, err
+}
+
+func (this trackDao) Load(ctx context.Context, id int64) (*model.Track, error) {
+	start := time.Now()
+	track, err := this.dao.Load(ctx, id)
+	observe(trackDaoName, "Load", start, err)
+
+	return track, err
+}
+
+func (this trackDao) LoadForAlbum(ctx context.Context, id int64) ([]model.Track, error) {
+	start := time.Now()
+	tracks, err := this.dao.LoadForAlbum(ctx, id)
+	observe(trackDaoName, "LoadForAlbum", start, err)
+
+	return tracks, err
+}
+
+func (this trackDao) LoadForAlbums(ctx context.Context, ids []int64) (map[int64][]model.Track, error) {
+	start := time.Now()
+	tracks, err := this.dao.LoadForAlbums(ctx, ids)
+	observe(trackDaoName, "LoadForAlbums", start, err)
+
+	return tracks, err
+}
+
+func (this trackDao) Save(ctx context.Context, track model.Track) (int64, error) {
+	start := time.Now()
+	id, err := this.dao.Save(ctx, track)
+	observe(trackDaoName, "Save", start, err)
+
+	return id, err
+}
+
+func (this trackDao) Reorder(ctx context.Context, albumId int64, positions []model.TrackPosition) error {
+	start := time.Now()
+	err := this.dao.Reorder(ctx, albumId, positions)
+	observe(trackDaoName, "Reorder", start, err)
+
+	return err
+}
+
+func (this trackDao) Delete(ctx context.Context, track model.Track) (int64, error) {
+	start := time.Now()
+	rows, err := this.dao.Delete(ctx, track)
+	observe(trackDaoName, "Delete", start, err)
+
+	return rows, err
+}
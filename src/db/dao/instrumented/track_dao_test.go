@@ -0,0 +1,70 @@
+package instrumented_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"citadel_intranet/src/db/dao/instrumented"
+	"citadel_intranet/src/db/dao/mock"
+	"citadel_intranet/src/db/model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackDao(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	track := model.Track{Id: 1, Title: "Track 1.1", AlbumId: 1}
+	failure := errors.New("Something bad happened")
+
+	inner := mock.NewMockTrackDao(ctrl)
+	inner.EXPECT().LoadAll(gomock.Eq(ctx)).Return([]model.Track{track}, nil).Times(1)
+	inner.EXPECT().Load(gomock.Eq(ctx), gomock.Eq(int64(1))).Return(&track, nil).Times(1)
+	inner.EXPECT().LoadForAlbum(gomock.Eq(ctx), gomock.Eq(int64(1))).Return(nil, failure).Times(1)
+	inner.EXPECT().LoadForAlbums(gomock.Eq(ctx), gomock.Eq([]int64{1})).Return(map[int64][]model.Track{1: {track}}, nil).Times(1)
+	inner.EXPECT().Save(gomock.Eq(ctx), gomock.Eq(track)).Return(int64(1), nil).Times(1)
+	inner.EXPECT().Reorder(gomock.Eq(ctx), gomock.Eq(int64(1)), gomock.Any()).Return(failure).Times(1)
+	inner.EXPECT().Delete(gomock.Eq(ctx), gomock.Eq(track)).Return(int64(0), failure).Times(1)
+	inner.EXPECT().Close().Times(1)
+
+	trackDao := instrumented.NewTrackDao(inner)
+	defer trackDao.Close()
+
+	tracks, err := trackDao.LoadAll(ctx)
+	assert.Equal([]model.Track{track}, tracks)
+	assert.Nil(err)
+
+	loaded, err := trackDao.Load(ctx, 1)
+	assert.Equal(&track, loaded)
+	assert.Nil(err)
+
+	_, err = trackDao.LoadForAlbum(ctx, 1)
+	assert.Equal(failure, err)
+
+	byAlbum, err := trackDao.LoadForAlbums(ctx, []int64{1})
+	assert.Equal(map[int64][]model.Track{1: {track}}, byAlbum)
+	assert.Nil(err)
+
+	id, err := trackDao.Save(ctx, track)
+	assert.Equal(int64(1), id)
+	assert.Nil(err)
+
+	assert.Equal(failure, trackDao.Reorder(ctx, 1, []model.TrackPosition{}))
+
+	_, err = trackDao.Delete(ctx, track)
+	assert.Equal(failure, err)
+
+	for _, method := range []string{"LoadAll", "Load", "LoadForAlbum", "LoadForAlbums", "Save", "Reorder", "Delete"} {
+		assert.Equal(uint64(1), callCount("track", method), method)
+	}
+
+	assert.Equal(float64(1), errorCount("track", "LoadForAlbum"))
+	assert.Equal(float64(0), errorCount("track", "Save"))
+	assert.Equal(float64(1), errorCount("track", "Reorder"))
+	assert.Equal(float64(1), errorCount("track", "Delete"))
+}
@@ -1,21 +1,25 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/logging"
 
 	"github.com/sirupsen/logrus"
 )
 
 type albumDao struct {
-	db        *sql.DB
+	db        Executor
 	artistDao dao.ArtistDao
 	trackDao  dao.TrackDao
 }
 
-func NewAlbumDao(db *sql.DB, artistDao dao.ArtistDao, trackDao dao.TrackDao) dao.AlbumDao {
+func NewAlbumDao(db Executor, artistDao dao.ArtistDao, trackDao dao.TrackDao) dao.AlbumDao {
 	return albumDao{
 		db:        db,
 		artistDao: artistDao,
@@ -27,21 +31,85 @@ func (this albumDao) Close() {
 	logrus.Debug("Closing Album DAO")
 }
 
-func (this albumDao) loadArtistAndTracksForAlbum(album *model.Album, artistId int64) {
-	artist := this.artistDao.Load(artistId)
-	if artist == nil {
-		logrus.Error("Unable to find artist with ID=", artistId)
-	} else {
-		album.Artist = *artist
+/*
+Fill in the artist and tracks for each album. Rather than going back to the
+database once per album, every artist and every track is loaded in a single
+query each, so the number of round trips doesn't grow with the number of
+albums.
+
+artistIds must line up with albums, index for index.
+*/
+func (this albumDao) loadArtistsAndTracks(ctx context.Context, albums []model.Album, artistIds []int64) error {
+	if len(albums) == 0 {
+		return nil
+	}
+
+	albumIds := make([]int64, len(albums))
+	for index, album := range albums {
+		albumIds[index] = album.Id
+	}
+
+	loaded, err := this.artistDao.LoadMany(ctx, artistIds)
+	if err != nil {
+		return translateError(err)
+	}
+
+	artists := make(map[int64]model.Artist)
+	for _, artist := range loaded {
+		artists[artist.Id] = artist
+	}
+
+	tracks, err := this.trackDao.LoadForAlbums(ctx, albumIds)
+	if err != nil {
+		return translateError(err)
+	}
+
+	for index := range albums {
+		album := &albums[index]
+
+		if artist, found := artists[artistIds[index]]; found {
+			album.Artist = artist
+		} else {
+			logging.FromContext(ctx).Error("Unable to find artist with ID=", artistIds[index])
+		}
+
+		album.Tracks = tracks[album.Id]
+		if album.Tracks == nil {
+			album.Tracks = make([]model.Track, 0)
+		}
 	}
 
-	album.Tracks = this.trackDao.LoadForAlbum(album.Id)
+	return nil
 }
 
-func (this albumDao) Load(id int64) *model.Album {
-	var album *model.Album = &model.Album{}
+/*
+Scan every album row, returning the albums along with the artist id for each
+of them. The rows are closed once they've all been read.
+*/
+func (this albumDao) scanAll(rows *sql.Rows) ([]model.Album, []int64, error) {
+	defer rows.Close()
 
-	row := this.db.QueryRow(`
+	var albums []model.Album = make([]model.Album, 0)
+	var artistIds []int64 = make([]int64, 0)
+
+	for rows.Next() {
+		var album model.Album
+		var artistId int64
+		if err := rows.Scan(&album.Id, &album.Title, &artistId, &album.State, &album.Rating, &album.Version); err != nil {
+			return nil, nil, err
+		}
+
+		albums = append(albums, album)
+		artistIds = append(artistIds, artistId)
+	}
+
+	return albums, artistIds, rows.Err()
+}
+
+func (this albumDao) Load(ctx context.Context, id int64) (*model.Album, error) {
+	var album model.Album
+
+	row := this.db.QueryRowContext(ctx, `
         SELECT
             *
         FROM album
@@ -49,92 +117,349 @@ func (this albumDao) Load(id int64) *model.Album {
     `, id)
 
 	var artistId int64
-	err := row.Scan(&album.Id, &album.Title, &artistId, &album.Published, &album.Rating)
+	err := row.Scan(&album.Id, &album.Title, &artistId, &album.State, &album.Rating, &album.Version)
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: album %d", dao.ErrNotFound, id)
+	}
 	if err != nil {
-		logrus.Warn("Loading failed for ", id, " ", err.Error())
-		return nil
+		return nil, fmt.Errorf("Unable to load album %d: %w", id, translateError(err))
 	}
 
-	this.loadArtistAndTracksForAlbum(album, artistId)
+	albums := []model.Album{album}
+	if err = this.loadArtistsAndTracks(ctx, albums, []int64{artistId}); err != nil {
+		return nil, fmt.Errorf("Unable to load album %d: %w", id, err)
+	}
 
-	return album
+	return &albums[0], nil
 }
 
-func (this albumDao) LoadAll() []model.Album {
-	var ret []model.Album = make([]model.Album, 0)
-
-	rows, err := this.db.Query(`
+func (this albumDao) LoadAll(ctx context.Context) ([]model.Album, error) {
+	rows, err := this.db.QueryContext(ctx, `
         SELECT
             *
         FROM album
     `)
 
 	if err != nil {
-		logrus.Warn("Unable to load albums ", err.Error())
-		return nil
+		return nil, fmt.Errorf("Unable to load albums: %w", translateError(err))
 	}
 
-	var artistId int64
+	albums, artistIds, err := this.scanAll(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load albums: %w", translateError(err))
+	}
+
+	if err = this.loadArtistsAndTracks(ctx, albums, artistIds); err != nil {
+		return nil, fmt.Errorf("Unable to load albums: %w", err)
+	}
+
+	return albums, nil
+}
+
+var albumSortColumns = map[dao.AlbumSortKey]string{
+	dao.AlbumSortId:     "id",
+	dao.AlbumSortTitle:  "title",
+	dao.AlbumSortRating: "rating",
+	dao.AlbumSortState:  "state",
+}
+
+// Escape the LIKE wildcards in a user supplied substring so that they are
+// matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func albumWhereClause(query dao.AlbumQuery) (string, []interface{}) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if query.ArtistId != 0 {
+		conditions = append(conditions, "artist = ?")
+		args = append(args, query.ArtistId)
+	}
+
+	if query.State != "" {
+		conditions = append(conditions, "state = ?")
+		args = append(args, query.State)
+	}
+
+	if query.Published != nil {
+		if *query.Published {
+			conditions = append(conditions, "state = ?")
+		} else {
+			conditions = append(conditions, "state <> ?")
+		}
+		args = append(args, model.AlbumPublished)
+	}
+
+	if query.MinRating != 0 {
+		conditions = append(conditions, "rating >= ?")
+		args = append(args, query.MinRating)
+	}
+
+	if query.Title != "" {
+		conditions = append(conditions, "title LIKE ?")
+		args = append(args, "%"+likeEscaper.Replace(query.Title)+"%")
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return `
+        WHERE ` + strings.Join(conditions, `
+            AND `), args
+}
+
+func albumOrderByClause(query dao.AlbumQuery) (string, error) {
+	sortBy := query.SortBy
+	if sortBy == "" {
+		sortBy = dao.AlbumSortId
+	}
+
+	column, found := albumSortColumns[sortBy]
+	if !found {
+		return "", fmt.Errorf("Unable to sort albums by %q", sortBy)
+	}
+
+	direction := "ASC"
+	if query.Descending {
+		direction = "DESC"
+	}
+
+	if column == "id" {
+		return fmt.Sprintf(`
+        ORDER BY
+            id %s`, direction), nil
+	}
+
+	return fmt.Sprintf(`
+        ORDER BY
+            %s %s,
+            id %s`, column, direction, direction), nil
+}
+
+func (this albumDao) Query(ctx context.Context, query dao.AlbumQuery) ([]model.Album, int64, error) {
+	where, args := albumWhereClause(query)
+	orderBy, err := albumOrderByClause(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err = this.db.QueryRowContext(ctx, `
+        SELECT
+            COUNT(*)
+        FROM album`+where+`
+    `, args...).Scan(&total)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("Unable to count albums: %w", translateError(err))
+	}
+
+	paging := ""
+	if query.Limit != 0 {
+		paging = `
+        LIMIT ?
+        OFFSET ?`
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := this.db.QueryContext(ctx, `
+        SELECT
+            *
+        FROM album`+where+orderBy+paging+`
+    `, args...)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("Unable to load albums: %w", translateError(err))
+	}
+
+	albums, artistIds, err := this.scanAll(rows)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Unable to load albums: %w", translateError(err))
+	}
+
+	if err = this.loadArtistsAndTracks(ctx, albums, artistIds); err != nil {
+		return nil, 0, fmt.Errorf("Unable to load albums: %w", err)
+	}
+
+	return albums, total, nil
+}
+
+func (this albumDao) SetState(ctx context.Context, change model.AlbumStateChange) error {
+	return inTransaction(ctx, this.db, func(tx Executor) error {
+		result, err := tx.ExecContext(ctx, `
+        UPDATE album
+        SET
+            state = ?,
+            version = version + 1
+        WHERE id = ?
+            AND state = ?
+    `,
+			change.To,
+			change.AlbumId,
+			change.From,
+		)
+
+		if err != nil {
+			return translateError(err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return translateError(err)
+		}
+
+		if rowsAffected == 0 {
+			return fmt.Errorf("%w: album %d is no longer %s", dao.ErrConflict, change.AlbumId, change.From)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+        INSERT INTO album_state_history(
+            album,
+            from_state,
+            to_state,
+            changed_by
+        )
+        VALUES(
+            ?,
+            ?,
+            ?,
+            ?
+        )
+    `,
+			change.AlbumId,
+			change.From,
+			change.To,
+			change.ChangedBy,
+		)
+
+		return translateError(err)
+	})
+}
+
+func (this albumDao) LoadHistory(ctx context.Context, albumId int64) ([]model.AlbumStateChange, error) {
+	rows, err := this.db.QueryContext(ctx, `
+        SELECT
+            *
+        FROM album_state_history
+        WHERE album = ?
+        ORDER BY
+            changed_at ASC,
+            id ASC
+    `, albumId)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load history for album %d: %w", albumId, translateError(err))
+	}
+	defer rows.Close()
+
+	var history []model.AlbumStateChange = make([]model.AlbumStateChange, 0)
 	for rows.Next() {
-		var album model.Album
-		err := rows.Scan(&album.Id, &album.Title, &artistId, &album.Published, &album.Rating)
+		var change model.AlbumStateChange
+		err := rows.Scan(
+			&change.Id,
+			&change.AlbumId,
+			&change.From,
+			&change.To,
+			&change.ChangedBy,
+			&change.ChangedAt,
+		)
 
 		if err != nil {
-			logrus.Warn(err.Error())
-		} else {
-			this.loadArtistAndTracksForAlbum(&album, artistId)
-			ret = append(ret, album)
+			return nil, fmt.Errorf("Unable to load history for album %d: %w", albumId, err)
 		}
+		history = append(history, change)
 	}
 
-	return ret
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to load history for album %d: %w", albumId, translateError(err))
+	}
+	return history, nil
 }
 
-func (this albumDao) Delete(album model.Album) (int64, error) {
-	result, err := this.db.Exec(`
+func (this albumDao) Delete(ctx context.Context, album model.Album) (int64, error) {
+	result, err := this.db.ExecContext(ctx, `
         DELETE
         FROM album
         WHERE id = ?
     `, album.Id)
 
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return result.RowsAffected()
 }
 
-func (this albumDao) Save(album model.Album) (int64, error) {
-	result, err := this.db.Exec(`
+func (this albumDao) Save(ctx context.Context, album model.Album) (int64, error) {
+	if album.Id != 0 && album.Version != 0 {
+		return this.update(ctx, album)
+	}
+
+	result, err := this.db.ExecContext(ctx, `
         INSERT INTO album(
             id,
             title,
             artist,
-            published,
             rating
         )
         VALUES(
             ?,
             ?,
             ?,
-            ?,
             ?
         )
         ON DUPLICATE KEY UPDATE
             title = VALUES(title),
             artist = VALUES(artist),
-            published = VALUES(published),
-            rating = VALUES(rating)
+            rating = VALUES(rating),
+            version = version + 1
     `,
 		album.Id,
 		album.Title,
 		album.Artist.Id,
-		album.Published,
 		album.Rating,
 	)
 
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return result.LastInsertId()
 }
+
+/*
+Update an existing album, but only if it's still at the version it was read
+at.
+*/
+func (this albumDao) update(ctx context.Context, album model.Album) (int64, error) {
+	result, err := this.db.ExecContext(ctx, `
+        UPDATE album
+        SET
+            title = ?,
+            artist = ?,
+            rating = ?,
+            version = version + 1
+        WHERE id = ?
+            AND version = ?
+    `,
+		album.Title,
+		album.Artist.Id,
+		album.Rating,
+		album.Id,
+		album.Version,
+	)
+
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	if rowsAffected == 0 {
+		return 0, fmt.Errorf("%w: album %d is no longer at version %d", dao.ErrConflict, album.Id, album.Version)
+	}
+	return album.Id, nil
+}
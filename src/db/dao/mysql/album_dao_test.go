@@ -1,9 +1,13 @@
 package mysql_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	daopkg "citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/dao/mock"
 	"citadel_intranet/src/db/dao/mysql"
 	"citadel_intranet/src/db/model"
@@ -31,7 +35,33 @@ func TestAlbumDaoLoadError(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, nil, nil)
 	defer dao.Close()
 
-	result := dao.Load(int64(1))
+	result, err := dao.Load(context.Background(), int64(1))
+	assert.NotNil(err)
+	assert.Nil(result)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestAlbumDaoLoadNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM album
+        WHERE id = \?
+    `).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}))
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	result, err := dao.Load(context.Background(), int64(1))
+	assert.True(errors.Is(err, daopkg.ErrNotFound))
 	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -50,8 +80,8 @@ func TestAlbumDaoLoad(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, mockArtistDao, mockTrackDao)
 	defer dao.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "published", "rating"}).
-		AddRow(1, "Waffle Irons", 42, false, 5)
+	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+		AddRow(1, "Waffle Irons", 42, "draft", 5, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
@@ -60,28 +90,29 @@ func TestAlbumDaoLoad(t *testing.T) {
     `).WithArgs(1).WillReturnRows(mockRows)
 
 	mockArtistDao.EXPECT().
-		Load(gomock.Eq(int64(42))).
-		DoAndReturn(func(id int64) *model.Artist {
-			return &model.Artist{
-				Id:   id,
+		LoadMany(gomock.Any(), gomock.Eq([]int64{42})).
+		DoAndReturn(func(_ context.Context, ids []int64) ([]model.Artist, error) {
+			return []model.Artist{{
+				Id:   ids[0],
 				Name: "Bobby",
-			}
+			}}, nil
 		}).Times(1)
 
 	mockTrackDao.EXPECT().
-		LoadForAlbum(gomock.Eq(int64(1))).
-		DoAndReturn(func(_ int64) []model.Track {
-			return []model.Track{}
+		LoadForAlbums(gomock.Any(), gomock.Eq([]int64{1})).
+		DoAndReturn(func(_ context.Context, _ []int64) (map[int64][]model.Track, error) {
+			return map[int64][]model.Track{}, nil
 		}).Times(1)
 
-	album := dao.Load(int64(1))
+	album, err := dao.Load(context.Background(), int64(1))
+	assert.Nil(err)
 	assert.NotNil(album)
 
 	assert.Equal(int64(1), album.Id)
 	assert.Equal(int64(42), album.Artist.Id)
 	assert.Equal("Bobby", album.Artist.Name)
 	assert.Equal("Waffle Irons", album.Title)
-	assert.Equal(false, album.Published)
+	assert.Equal(model.AlbumDraft, album.State)
 	assert.Equal(uint(5), album.Rating)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -100,8 +131,8 @@ func TestAlbumDaoLoadNoArtist(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, mockArtistDao, mockTrackDao)
 	defer dao.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "published", "rating"}).
-		AddRow(1, "Waffle Irons", 42, false, 5)
+	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+		AddRow(1, "Waffle Irons", 42, "draft", 5, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
@@ -110,25 +141,26 @@ func TestAlbumDaoLoadNoArtist(t *testing.T) {
     `).WithArgs(1).WillReturnRows(mockRows)
 
 	mockArtistDao.EXPECT().
-		Load(gomock.Eq(int64(42))).
-		DoAndReturn(func(id int64) *model.Artist {
-			return nil
+		LoadMany(gomock.Any(), gomock.Eq([]int64{42})).
+		DoAndReturn(func(_ context.Context, _ []int64) ([]model.Artist, error) {
+			return []model.Artist{}, nil
 		}).Times(1)
 
 	mockTrackDao.EXPECT().
-		LoadForAlbum(gomock.Eq(int64(1))).
-		DoAndReturn(func(_ int64) []model.Track {
-			return []model.Track{}
+		LoadForAlbums(gomock.Any(), gomock.Eq([]int64{1})).
+		DoAndReturn(func(_ context.Context, _ []int64) (map[int64][]model.Track, error) {
+			return map[int64][]model.Track{}, nil
 		}).Times(1)
 
-	album := dao.Load(int64(1))
+	album, err := dao.Load(context.Background(), int64(1))
+	assert.Nil(err)
 	assert.NotNil(album)
 
 	assert.Equal(int64(1), album.Id)
 	assert.Equal(int64(0), album.Artist.Id)
 	assert.Equal("", album.Artist.Name)
 	assert.Equal("Waffle Irons", album.Title)
-	assert.Equal(false, album.Published)
+	assert.Equal(model.AlbumDraft, album.State)
 	assert.Equal(uint(5), album.Rating)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -157,7 +189,7 @@ func TestAlbumDaoDeleteError(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, nil, nil)
 	defer dao.Close()
 
-	rowsAffected, err := dao.Delete(album)
+	rowsAffected, err := dao.Delete(context.Background(), album)
 	assert.Equal(int64(0), rowsAffected)
 	assert.NotNil(err)
 
@@ -181,35 +213,81 @@ func TestAlbumDaoSaveError(t *testing.T) {
             id,
             title,
             artist,
-            published,
             rating
         \)
         VALUES\(
             \?,
             \?,
             \?,
-            \?,
             \?
         \)
         ON DUPLICATE KEY UPDATE
             title = VALUES\(title\),
             artist = VALUES\(artist\),
-            published = VALUES\(published\),
-            rating = VALUES\(rating\)
+            rating = VALUES\(rating\),
+            version = version \+ 1
     `).
-		WithArgs(album.Id, album.Title, album.Artist.Id, album.Published, album.Rating).
+		WithArgs(album.Id, album.Title, album.Artist.Id, album.Rating).
 		WillReturnError(errors.New("Album save died"))
 
 	dao := mysql.NewAlbumDao(db, nil, nil)
 	defer dao.Close()
 
-	lastId, err := dao.Save(album)
+	lastId, err := dao.Save(context.Background(), album)
 	assert.Equal(int64(0), lastId)
 	assert.NotNil(err)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
 
+func TestAlbumDaoSaveVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	album := model.Album{
+		Id:      42,
+		Title:   "Waffle Irons",
+		Artist:  model.Artist{Id: 7},
+		Rating:  5,
+		Version: 3,
+	}
+
+	update := `
+        UPDATE album
+        SET
+            title = \?,
+            artist = \?,
+            rating = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND version = \?
+    `
+	mock.ExpectExec(update).
+		WithArgs(album.Title, album.Artist.Id, album.Rating, album.Id, album.Version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(update).
+		WithArgs(album.Title, album.Artist.Id, album.Rating, album.Id, album.Version).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	lastId, err := dao.Save(context.Background(), album)
+	assert.Equal(int64(42), lastId)
+	assert.Nil(err)
+
+	// Someone else has saved the album since version 3 was read.
+	lastId, err = dao.Save(context.Background(), album)
+	assert.Equal(int64(0), lastId)
+	assert.True(errors.Is(err, daopkg.ErrConflict))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
 func TestAlbumDao(t *testing.T) {
 	assert := assert.New(t)
 
@@ -227,23 +305,21 @@ func TestAlbumDao(t *testing.T) {
             id,
             title,
             artist,
-            published,
             rating
         \)
         VALUES\(
             \?,
             \?,
             \?,
-            \?,
             \?
         \)
         ON DUPLICATE KEY UPDATE
             title = VALUES\(title\),
             artist = VALUES\(artist\),
-            published = VALUES\(published\),
-            rating = VALUES\(rating\)
+            rating = VALUES\(rating\),
+            version = version \+ 1
     `).
-		WithArgs(album.Id, album.Title, album.Artist.Id, album.Published, album.Rating).
+		WithArgs(album.Id, album.Title, album.Artist.Id, album.Rating).
 		WillReturnResult(sqlmock.NewResult(42, 1))
 	mock.ExpectExec(`
         DELETE
@@ -256,11 +332,11 @@ func TestAlbumDao(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, nil, nil)
 	defer dao.Close()
 
-	lastId, err := dao.Save(album)
+	lastId, err := dao.Save(context.Background(), album)
 	assert.Equal(int64(42), lastId)
 	assert.Nil(err)
 
-	affectedRows, err := dao.Delete(album)
+	affectedRows, err := dao.Delete(context.Background(), album)
 	assert.Equal(int64(1), affectedRows)
 	assert.Nil(err)
 
@@ -285,7 +361,8 @@ func TestAlbumDaoLoadAllError(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, nil, nil)
 	defer dao.Close()
 
-	result := dao.LoadAll()
+	result, err := dao.LoadAll(context.Background())
+	assert.NotNil(err)
 	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -304,10 +381,10 @@ func TestAlbumDaoLoadAll(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, mockArtistDao, mockTrackDao)
 	defer dao.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "published", "rating"}).
-		AddRow(1, "Waffle Irons", 42, false, 5).
-		AddRow(2, "Something New", 42, false, 3).
-		AddRow(3, "Something New (Deluxe)", 42, true, 5)
+	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+		AddRow(1, "Waffle Irons", 42, "draft", 5, 1).
+		AddRow(2, "Something New", 42, "draft", 3, 1).
+		AddRow(3, "Something New (Deluxe)", 42, "published", 5, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
@@ -316,44 +393,50 @@ func TestAlbumDaoLoadAll(t *testing.T) {
 		WillReturnRows(mockRows)
 
 	mockArtistDao.EXPECT().
-		Load(gomock.Eq(int64(42))).
-		DoAndReturn(func(id int64) *model.Artist {
-			return &model.Artist{
-				Id:   id,
+		LoadMany(gomock.Any(), gomock.Eq([]int64{42, 42, 42})).
+		DoAndReturn(func(_ context.Context, _ []int64) ([]model.Artist, error) {
+			return []model.Artist{{
+				Id:   42,
 				Name: "Bobby",
-			}
+			}}, nil
 		}).
-		Times(3)
-
-	for i := 1; i < 4; i++ {
-		mockTrackDao.EXPECT().
-			LoadForAlbum(gomock.Eq(int64(i))).
-			DoAndReturn(func(_ int64) []model.Track {
-				return []model.Track{}
-			}).Times(1)
-	}
+		Times(1)
 
-	albums := dao.LoadAll()
+	mockTrackDao.EXPECT().
+		LoadForAlbums(gomock.Any(), gomock.Eq([]int64{1, 2, 3})).
+		DoAndReturn(func(_ context.Context, _ []int64) (map[int64][]model.Track, error) {
+			return map[int64][]model.Track{
+				2: {{Id: 7, Title: "Track 2.1", AlbumId: 2}},
+			}, nil
+		}).
+		Times(1)
+
+	albums, err := dao.LoadAll(context.Background())
+	assert.Nil(err)
 	assert.Len(albums, 3)
 
 	for index, row := range []struct {
-		Title     string
-		Published bool
-		Rating    uint
+		Title  string
+		State  model.AlbumState
+		Rating uint
 	}{
-		{"Waffle Irons", false, 5},
-		{"Something New", false, 3},
-		{"Something New (Deluxe)", true, 5},
+		{"Waffle Irons", model.AlbumDraft, 5},
+		{"Something New", model.AlbumDraft, 3},
+		{"Something New (Deluxe)", model.AlbumPublished, 5},
 	} {
 		album := albums[index]
 		assert.Equal(int64(index+1), album.Id)
 		assert.Equal(int64(42), album.Artist.Id)
 		assert.Equal("Bobby", album.Artist.Name)
 		assert.Equal(row.Title, album.Title)
-		assert.Equal(row.Published, album.Published)
+		assert.Equal(row.State, album.State)
 		assert.Equal(row.Rating, album.Rating)
 	}
 
+	assert.Len(albums[0].Tracks, 0)
+	assert.Len(albums[1].Tracks, 1)
+	assert.Len(albums[2].Tracks, 0)
+
 	assert.Nil(mock.ExpectationsWereMet())
 }
 
@@ -366,8 +449,8 @@ func TestAlbumDaoLoadAllScanError(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, nil, nil)
 	defer dao.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "published", "rating"}).
-		AddRow("cat", "Waffle Irons", 42, false, 5)
+	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+		AddRow("cat", "Waffle Irons", 42, "draft", 5, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
@@ -375,13 +458,340 @@ func TestAlbumDaoLoadAllScanError(t *testing.T) {
     `).
 		WillReturnRows(mockRows)
 
-	albums := dao.LoadAll()
+	albums, err := dao.LoadAll(context.Background())
+	assert.NotNil(err)
+	assert.Nil(albums)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestAlbumDaoQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	assert := assert.New(t)
+
+	mockArtistDao := mock.NewMockArtistDao(ctrl)
+	mockTrackDao := mock.NewMockTrackDao(ctrl)
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	dao := mysql.NewAlbumDao(db, mockArtistDao, mockTrackDao)
+	defer dao.Close()
+
+	published := false
+	query := daopkg.AlbumQuery{
+		ArtistId:   42,
+		State:      model.AlbumInReview,
+		Published:  &published,
+		MinRating:  3,
+		Title:      "100%_new",
+		SortBy:     daopkg.AlbumSortTitle,
+		Descending: true,
+		Limit:      10,
+		Offset:     20,
+	}
+
+	mock.ExpectQuery(`
+        SELECT
+            COUNT\(\*\)
+        FROM album
+        WHERE artist = \?
+            AND state = \?
+            AND state <> \?
+            AND rating >= \?
+            AND title LIKE \?
+    `).
+		WithArgs(42, "in_review", "published", 3, `%100\%\_new%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+
+	mockRows := sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+		AddRow(7, "100%_new", 42, "draft", 3, 1)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM album
+        WHERE artist = \?
+            AND state = \?
+            AND state <> \?
+            AND rating >= \?
+            AND title LIKE \?
+        ORDER BY
+            title DESC,
+            id DESC
+        LIMIT \?
+        OFFSET \?
+    `).
+		WithArgs(42, "in_review", "published", 3, `%100\%\_new%`, 10, 20).
+		WillReturnRows(mockRows)
+
+	mockArtistDao.EXPECT().
+		LoadMany(gomock.Any(), gomock.Eq([]int64{42})).
+		Return([]model.Artist{{Id: 42, Name: "Bobby"}}, nil).
+		Times(1)
+	mockTrackDao.EXPECT().
+		LoadForAlbums(gomock.Any(), gomock.Eq([]int64{7})).
+		Return(map[int64][]model.Track{}, nil).
+		Times(1)
+
+	albums, total, err := dao.Query(context.Background(), query)
+	assert.Nil(err)
+	assert.Equal(int64(21), total)
+	assert.Len(albums, 1)
+	assert.Equal(int64(7), albums[0].Id)
+	assert.Equal("Bobby", albums[0].Artist.Name)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestAlbumDaoQueryNoLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            COUNT\(\*\)
+        FROM album
+    `).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM album
+        ORDER BY
+            id ASC
+    `).
+		WithArgs().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}))
+
+	albums, total, err := dao.Query(context.Background(), daopkg.AlbumQuery{})
+	assert.Nil(err)
+	assert.Equal(int64(0), total)
 	assert.NotNil(albums)
 	assert.Len(albums, 0)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
 
+func TestAlbumDaoQueryInvalidSort(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	albums, total, err := dao.Query(context.Background(), daopkg.AlbumQuery{SortBy: "artist"})
+	assert.NotNil(err)
+	assert.Nil(albums)
+	assert.Equal(int64(0), total)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestAlbumDaoQueryCountError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            COUNT\(\*\)
+        FROM album
+    `).
+		WillReturnError(errors.New("Something bad happened"))
+
+	albums, _, err := dao.Query(context.Background(), daopkg.AlbumQuery{})
+	assert.NotNil(err)
+	assert.Nil(albums)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestAlbumDaoQueryScanError(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, mockRows := range map[string]*sqlmock.Rows{
+		"bad value": sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+			AddRow(1, "Album 1", 42, "draft", 5, 1).
+			AddRow("cat", "Waffle Irons", 42, "draft", 5, 1),
+		"row error": sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"}).
+			AddRow(1, "Album 1", 42, "draft", 5, 1).
+			AddRow(2, "Little Bobby Tables", 42, "draft", 5, 1).
+			RowError(1, errors.New("Keep him away from the tables!")),
+	} {
+		db, mock, err := sqlmock.New()
+		assert.Nil(err)
+
+		dao := mysql.NewAlbumDao(db, nil, nil)
+
+		mock.ExpectQuery(`
+        SELECT
+            COUNT\(\*\)
+        FROM album
+    `).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM album
+        ORDER BY
+            id ASC
+    `).
+			WillReturnRows(mockRows)
+
+		// A row that can't be read fails the whole page, rather than coming
+		// back short of the total.
+		albums, total, err := dao.Query(context.Background(), daopkg.AlbumQuery{})
+		assert.NotNil(err, name)
+		assert.Nil(albums, name)
+		assert.Equal(int64(0), total, name)
+
+		assert.Nil(mock.ExpectationsWereMet(), name)
+		dao.Close()
+	}
+}
+
+const (
+	catalogueArtistCount   = 7
+	catalogueTracksOnAlbum = 3
+)
+
+/*
+Expect the queries needed to load a catalogue of albumCount albums, spread
+across a handful of artists with a few tracks each. Only three queries are
+expected no matter how large the catalogue is.
+*/
+func expectCatalogue(mock sqlmock.Sqlmock, albumCount int) {
+	albumRows := sqlmock.NewRows([]string{"id", "title", "artist", "state", "rating", "version"})
+	artistRows := sqlmock.NewRows([]string{"id", "name"})
+	trackRows := sqlmock.NewRows([]string{"id", "title", "album", "rating", "disc_number", "track_number", "version"})
+
+	for i := 1; i <= albumCount; i++ {
+		albumRows.AddRow(i, fmt.Sprintf("Album %d", i), i%catalogueArtistCount+1, "draft", 3, 1)
+		for j := 1; j <= catalogueTracksOnAlbum; j++ {
+			trackRows.AddRow((i-1)*catalogueTracksOnAlbum+j, fmt.Sprintf("Track %d.%d", i, j), i, 5, 1, j, 1)
+		}
+	}
+
+	for i := 1; i <= catalogueArtistCount; i++ {
+		artistRows.AddRow(i, fmt.Sprintf("Artist %d", i))
+	}
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM album
+    `).
+		WillReturnRows(albumRows)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM artist
+        WHERE id IN \((\?, )*\?\)
+    `).
+		WillReturnRows(artistRows)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM track
+        WHERE album IN \((\?, )*\?\)
+        ORDER BY
+            album ASC,
+            disc_number ASC,
+            track_number ASC,
+            id ASC
+    `).
+		WillReturnRows(trackRows)
+}
+
+func catalogueComplete(albums []model.Album, albumCount int) bool {
+	if len(albums) != albumCount {
+		return false
+	}
+
+	for _, album := range albums {
+		if album.Artist.Name == "" || len(album.Tracks) != catalogueTracksOnAlbum {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestAlbumDaoLoadAllQueryCount(t *testing.T) {
+	for _, albumCount := range []int{1, 10, 500} {
+		assert := assert.New(t)
+
+		db, mock, err := sqlmock.New()
+		assert.Nil(err)
+
+		dao := mysql.NewAlbumDao(db, mysql.NewArtistDao(db), mysql.NewTrackDao(db))
+
+		expectCatalogue(mock, albumCount)
+
+		albums, err := dao.LoadAll(context.Background())
+		assert.Nil(err)
+		assert.True(catalogueComplete(albums, albumCount), "albums=%d", albumCount)
+		assert.Nil(mock.ExpectationsWereMet(), "albums=%d", albumCount)
+
+		dao.Close()
+		db.Close()
+	}
+}
+
+/*
+Loading the catalogue should take the same number of queries regardless of
+its size, only the amount of data coming back should grow. Any extra query
+runs past the mocked expectations and fails the benchmark.
+*/
+func BenchmarkAlbumDaoLoadAll(b *testing.B) {
+	for _, albumCount := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("albums=%d", albumCount), func(b *testing.B) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+
+			dao := mysql.NewAlbumDao(db, mysql.NewArtistDao(db), mysql.NewTrackDao(db))
+			defer dao.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				expectCatalogue(mock, albumCount)
+				b.StartTimer()
+
+				albums, err := dao.LoadAll(context.Background())
+
+				b.StopTimer()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if !catalogueComplete(albums, albumCount) {
+					b.Fatal("Catalogue was not fully loaded")
+				}
+				if err := mock.ExpectationsWereMet(); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+
+			b.ReportMetric(3, "queries/op")
+		})
+	}
+}
+
 func disableTestAlbumDaoLoadAll(t *testing.T) {
 	assert := assert.New(t)
 
@@ -405,9 +815,134 @@ func disableTestAlbumDaoLoadAll(t *testing.T) {
 	dao := mysql.NewAlbumDao(db, nil, nil)
 	defer dao.Close()
 
-	result := dao.LoadAll()
+	result, err := dao.LoadAll(context.Background())
+	assert.Nil(err)
 	assert.NotNil(result)
 	assert.Len(result, 1)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
+
+func TestAlbumDaoSetState(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	change := model.AlbumStateChange{
+		AlbumId:   42,
+		From:      model.AlbumDraft,
+		To:        model.AlbumInReview,
+		ChangedBy: "James",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`
+        UPDATE album
+        SET
+            state = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND state = \?
+    `).
+		WithArgs("in_review", 42, "draft").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`
+        INSERT INTO album_state_history\(
+            album,
+            from_state,
+            to_state,
+            changed_by
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?,
+            \?
+        \)
+    `).
+		WithArgs(42, "draft", "in_review", "James").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	assert.Nil(dao.SetState(context.Background(), change))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestAlbumDaoSetStateConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`
+        UPDATE album
+        SET
+            state = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND state = \?
+    `).
+		WithArgs("in_review", 42, "draft").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	err = dao.SetState(context.Background(), model.AlbumStateChange{
+		AlbumId:   42,
+		From:      model.AlbumDraft,
+		To:        model.AlbumInReview,
+		ChangedBy: "James",
+	})
+	assert.True(errors.Is(err, daopkg.ErrConflict))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestAlbumDaoLoadHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	changedAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	mockRows := sqlmock.NewRows([]string{"id", "album", "from_state", "to_state", "changed_by", "changed_at"}).
+		AddRow(1, 42, "draft", "in_review", "James", changedAt).
+		AddRow(2, 42, "in_review", "approved", "Bobby", changedAt)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM album_state_history
+        WHERE album = \?
+        ORDER BY
+            changed_at ASC,
+            id ASC
+    `).
+		WithArgs(42).
+		WillReturnRows(mockRows)
+
+	dao := mysql.NewAlbumDao(db, nil, nil)
+	defer dao.Close()
+
+	history, err := dao.LoadHistory(context.Background(), int64(42))
+	assert.Nil(err)
+	assert.Equal([]model.AlbumStateChange{
+		{Id: 1, AlbumId: 42, From: model.AlbumDraft, To: model.AlbumInReview, ChangedBy: "James", ChangedAt: changedAt},
+		{Id: 2, AlbumId: 42, From: model.AlbumInReview, To: model.AlbumApproved, ChangedBy: "Bobby", ChangedAt: changedAt},
+	}, history)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,158 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type apiTokenDao struct {
+	db *sql.DB
+}
+
+func NewApiTokenDao(db *sql.DB) dao.ApiTokenDao {
+	return apiTokenDao{
+		db: db,
+	}
+}
+
+func (this apiTokenDao) Close() {
+	logrus.Debug("Closing API Token DAO")
+}
+
+func scanApiToken(row rowScanner) (model.ApiToken, error) {
+	var token model.ApiToken
+	var scopes string
+	var lastUsedAt sql.NullTime
+
+	err := row.Scan(
+		&token.Id,
+		&token.UserId,
+		&token.Name,
+		&token.TokenHash,
+		&scopes,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&lastUsedAt,
+	)
+
+	token.Scopes = make([]string, 0)
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return token, err
+}
+
+func (this apiTokenDao) LoadForUser(userId int64) []model.ApiToken {
+	rows, err := this.db.Query(`
+        SELECT
+            *
+        FROM api_token
+        WHERE user = ?
+        ORDER BY
+            created_at ASC,
+            id ASC
+    `, userId)
+
+	if err != nil {
+		logrus.Warn("Unable to load API tokens ", err.Error())
+		return nil
+	}
+	defer rows.Close()
+
+	var ret []model.ApiToken = make([]model.ApiToken, 0)
+	for rows.Next() {
+		token, err := scanApiToken(rows)
+
+		if err != nil {
+			logrus.Warn(err.Error())
+		} else {
+			ret = append(ret, token)
+		}
+	}
+
+	return ret
+}
+
+func (this apiTokenDao) LoadByHash(hash string) *model.ApiToken {
+	row := this.db.QueryRow(`
+        SELECT
+            *
+        FROM api_token
+        WHERE token_hash = ?
+    `, hash)
+
+	token, err := scanApiToken(row)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Warn("Unable to load API token ", err.Error())
+		}
+		return nil
+	}
+
+	return &token
+}
+
+func (this apiTokenDao) Save(token model.ApiToken) (int64, error) {
+	result, err := this.db.Exec(`
+        INSERT INTO api_token(
+            user,
+            name,
+            token_hash,
+            scopes,
+            expires_at
+        )
+        VALUES(
+            ?,
+            ?,
+            ?,
+            ?,
+            ?
+        )
+    `,
+		token.UserId,
+		token.Name,
+		token.TokenHash,
+		strings.Join(token.Scopes, ","),
+		token.ExpiresAt,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (this apiTokenDao) Touch(id int64, at time.Time) error {
+	_, err := this.db.Exec(`
+        UPDATE api_token
+        SET
+            last_used_at = ?
+        WHERE id = ?
+    `, at, id)
+
+	return err
+}
+
+func (this apiTokenDao) Delete(token model.ApiToken) (int64, error) {
+	result, err := this.db.Exec(`
+        DELETE
+        FROM api_token
+        WHERE id = ?
+            AND user = ?
+    `, token.Id, token.UserId)
+
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,171 @@
+package mysql_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"citadel_intranet/src/db/dao/mysql"
+	"citadel_intranet/src/db/model"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiTokenColumns = []string{"id", "user", "name", "token_hash", "scopes", "created_at", "expires_at", "last_used_at"}
+
+func TestApiTokenDao(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	expiresAt := createdAt.AddDate(0, 0, 90)
+	token := model.ApiToken{
+		UserId:    1,
+		Name:      "CI",
+		TokenHash: "abc123",
+		Scopes:    []string{"tracks:create", "tracks:edit"},
+		ExpiresAt: expiresAt,
+	}
+
+	mock.ExpectExec(`
+        INSERT INTO api_token\(
+            user,
+            name,
+            token_hash,
+            scopes,
+            expires_at
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?,
+            \?,
+            \?
+        \)
+    `).
+		WithArgs(token.UserId, token.Name, token.TokenHash, "tracks:create,tracks:edit", token.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM api_token
+        WHERE token_hash = \?
+    `).
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows(apiTokenColumns).
+			AddRow(7, 1, "CI", "abc123", "tracks:create,tracks:edit", createdAt, expiresAt, nil))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM api_token
+        WHERE user = \?
+        ORDER BY
+            created_at ASC,
+            id ASC
+    `).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(apiTokenColumns).
+			AddRow(7, 1, "CI", "abc123", "tracks:create,tracks:edit", createdAt, expiresAt, nil).
+			AddRow(8, 1, "Reader", "def456", "", createdAt, expiresAt, createdAt))
+
+	mock.ExpectExec(`
+        UPDATE api_token
+        SET
+            last_used_at = \?
+        WHERE id = \?
+    `).
+		WithArgs(createdAt, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(`
+        DELETE
+        FROM api_token
+        WHERE id = \?
+            AND user = \?
+    `).
+		WithArgs(7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dao := mysql.NewApiTokenDao(db)
+	defer dao.Close()
+
+	id, err := dao.Save(token)
+	assert.Nil(err)
+	assert.Equal(int64(7), id)
+
+	token.Id = id
+	token.CreatedAt = createdAt
+	assert.Equal(&token, dao.LoadByHash("abc123"))
+
+	tokens := dao.LoadForUser(1)
+	assert.Len(tokens, 2)
+	assert.Equal(token, tokens[0])
+	assert.Equal([]string{}, tokens[1].Scopes)
+	assert.Equal(&createdAt, tokens[1].LastUsedAt)
+
+	assert.Nil(dao.Touch(7, createdAt))
+
+	rows, err := dao.Delete(token)
+	assert.Nil(err)
+	assert.Equal(int64(1), rows)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestApiTokenDaoErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM api_token
+        WHERE token_hash = \?
+    `).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(apiTokenColumns))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM api_token
+        WHERE user = \?
+        ORDER BY
+            created_at ASC,
+            id ASC
+    `).
+		WithArgs(1).
+		WillReturnError(errors.New("Something bad happened"))
+
+	mock.ExpectExec(`
+        DELETE
+        FROM api_token
+        WHERE id = \?
+            AND user = \?
+    `).
+		WithArgs(7, 1).
+		WillReturnError(errors.New("Something bad happened"))
+
+	dao := mysql.NewApiTokenDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.LoadByHash("missing"))
+	assert.Nil(dao.LoadForUser(1))
+
+	rows, err := dao.Delete(model.ApiToken{Id: 7, UserId: 1})
+	assert.Equal(int64(0), rows)
+	assert.NotNil(err)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
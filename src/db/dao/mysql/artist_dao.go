@@ -1,7 +1,9 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	"citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/model"
@@ -10,10 +12,10 @@ import (
 )
 
 type artistDao struct {
-	db *sql.DB
+	db Executor
 }
 
-func NewArtistDao(db *sql.DB) dao.ArtistDao {
+func NewArtistDao(db Executor) dao.ArtistDao {
 	return artistDao{
 		db: db,
 	}
@@ -23,10 +25,28 @@ func (this artistDao) Close() {
 	logrus.Debug("Closing Album DAO")
 }
 
-func (this artistDao) Load(id int64) *model.Artist {
+/*
+Scan every artist row, closing the rows once they've all been read.
+*/
+func (this artistDao) scanAll(rows *sql.Rows) ([]model.Artist, error) {
+	defer rows.Close()
+
+	var ret []model.Artist = make([]model.Artist, 0)
+	for rows.Next() {
+		var artist model.Artist
+		if err := rows.Scan(&artist.Id, &artist.Name); err != nil {
+			return nil, err
+		}
+		ret = append(ret, artist)
+	}
+
+	return ret, rows.Err()
+}
+
+func (this artistDao) Load(ctx context.Context, id int64) (*model.Artist, error) {
 	var artist *model.Artist = &model.Artist{}
 
-	row := this.db.QueryRow(`
+	row := this.db.QueryRowContext(ctx, `
         SELECT
             *
         FROM artist
@@ -35,57 +55,96 @@ func (this artistDao) Load(id int64) *model.Artist {
 
 	err := row.Scan(&artist.Id, &artist.Name)
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: artist %d", dao.ErrNotFound, id)
+	}
 	if err != nil {
-		logrus.Warn("Loading failed for ", id, " ", err.Error())
-		return nil
+		return nil, fmt.Errorf("Unable to load artist %d: %w", id, translateError(err))
 	}
 
-	return artist
+	return artist, nil
 }
 
-func (this artistDao) LoadAll() []model.Artist {
-	var ret []model.Artist = make([]model.Artist, 0)
+func (this artistDao) LoadByName(ctx context.Context, name string) (*model.Artist, error) {
+	var artist *model.Artist = &model.Artist{}
 
-	rows, err := this.db.Query(`
+	row := this.db.QueryRowContext(ctx, `
         SELECT
             *
         FROM artist
-    `)
+        WHERE name = ?
+    `, name)
 
+	err := row.Scan(&artist.Id, &artist.Name)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: artist %q", dao.ErrNotFound, name)
+	}
 	if err != nil {
-		logrus.Warn("Unable to load artists ", err.Error())
-		return nil
+		return nil, fmt.Errorf("Unable to load artist %q: %w", name, translateError(err))
 	}
 
-	for rows.Next() {
-		var artist model.Artist
-		err := rows.Scan(&artist.Id, &artist.Name)
+	return artist, nil
+}
 
-		if err != nil {
-			logrus.Warn(err.Error())
-		} else {
-			ret = append(ret, artist)
-		}
+func (this artistDao) LoadMany(ctx context.Context, ids []int64) ([]model.Artist, error) {
+	ids = uniqueIds(ids)
+	if len(ids) == 0 {
+		return make([]model.Artist, 0), nil
 	}
 
-	return ret
+	in, args := inClause(ids)
+	rows, err := this.db.QueryContext(ctx, `
+        SELECT
+            *
+        FROM artist
+        WHERE id IN `+in+`
+    `, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load artists: %w", translateError(err))
+	}
+
+	artists, err := this.scanAll(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load artists: %w", translateError(err))
+	}
+	return artists, nil
+}
+
+func (this artistDao) LoadAll(ctx context.Context) ([]model.Artist, error) {
+	rows, err := this.db.QueryContext(ctx, `
+        SELECT
+            *
+        FROM artist
+    `)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load artists: %w", translateError(err))
+	}
+
+	artists, err := this.scanAll(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load artists: %w", translateError(err))
+	}
+	return artists, nil
 }
 
-func (this artistDao) Delete(artist model.Artist) (int64, error) {
-	result, err := this.db.Exec(`
+func (this artistDao) Delete(ctx context.Context, artist model.Artist) (int64, error) {
+	result, err := this.db.ExecContext(ctx, `
         DELETE
         FROM artist
         WHERE id = ?
     `, artist.Id)
 
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return result.RowsAffected()
 }
 
-func (this artistDao) Save(artist model.Artist) (int64, error) {
-	result, err := this.db.Exec(`
+func (this artistDao) Save(ctx context.Context, artist model.Artist) (int64, error) {
+	result, err := this.db.ExecContext(ctx, `
         INSERT INTO artist(
             id,
             name
@@ -102,7 +161,7 @@ func (this artistDao) Save(artist model.Artist) (int64, error) {
 	)
 
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return result.LastInsertId()
 }
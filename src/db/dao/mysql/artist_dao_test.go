@@ -1,13 +1,17 @@
 package mysql_test
 
 import (
+	"context"
 	"errors"
+	"net"
 	"testing"
 
+	daopkg "citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/dao/mysql"
 	"citadel_intranet/src/db/model"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -60,15 +64,16 @@ func TestArtistDao(t *testing.T) {
 	dao := mysql.NewArtistDao(db)
 	defer dao.Close()
 
-	rows, err := dao.Save(artist)
+	rows, err := dao.Save(context.Background(), artist)
 	assert.Nil(err)
 	assert.Equal(int64(1), rows)
 
-	result := dao.Load(int64(1))
+	result, err := dao.Load(context.Background(), int64(1))
+	assert.Nil(err)
 	assert.NotNil(result)
 	assert.Equal(artist.Name, result.Name)
 
-	rows, err = dao.Delete(artist)
+	rows, err = dao.Delete(context.Background(), artist)
 	assert.Nil(err)
 	assert.Equal(int64(1), rows)
 
@@ -95,12 +100,73 @@ func TestArtistDaoLoadError(t *testing.T) {
 	dao := mysql.NewArtistDao(db)
 	defer dao.Close()
 
-	result := dao.Load(int64(1))
+	result, err := dao.Load(context.Background(), int64(1))
+	assert.NotNil(err)
 	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
 
+func TestArtistDaoLoadNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM artist
+        WHERE id = \?
+    `).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+	dao := mysql.NewArtistDao(db)
+	defer dao.Close()
+
+	result, err := dao.Load(context.Background(), int64(1))
+	assert.True(errors.Is(err, daopkg.ErrNotFound))
+	assert.False(errors.Is(err, daopkg.ErrUnavailable))
+	assert.Nil(result)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestArtistDaoLoadUnavailable(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, cause := range []error{
+		driver.ErrInvalidConn,
+		context.DeadlineExceeded,
+		&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+		&driver.MySQLError{Number: 1040, Message: "Too many connections"},
+	} {
+		db, mock, err := sqlmock.New()
+		assert.Nil(err)
+
+		mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM artist
+    `).
+			WillReturnError(cause)
+
+		dao := mysql.NewArtistDao(db)
+
+		result, err := dao.LoadAll(context.Background())
+		assert.True(errors.Is(err, daopkg.ErrUnavailable), "%v", cause)
+		assert.True(errors.Is(err, cause), "%v", cause)
+		assert.Nil(result)
+
+		assert.Nil(mock.ExpectationsWereMet())
+		dao.Close()
+		db.Close()
+	}
+}
+
 func TestArtistDaoDeleteError(t *testing.T) {
 	assert := assert.New(t)
 
@@ -125,7 +191,7 @@ func TestArtistDaoDeleteError(t *testing.T) {
 	dao := mysql.NewArtistDao(db)
 	defer dao.Close()
 
-	rowsAffected, err := dao.Delete(artist)
+	rowsAffected, err := dao.Delete(context.Background(), artist)
 	assert.Equal(int64(0), rowsAffected)
 	assert.NotNil(err)
 
@@ -163,7 +229,7 @@ func TestArtistDaoSaveError(t *testing.T) {
 	dao := mysql.NewArtistDao(db)
 	defer dao.Close()
 
-	lastId, err := dao.Save(artist)
+	lastId, err := dao.Save(context.Background(), artist)
 	assert.Equal(int64(0), lastId)
 	assert.NotNil(err)
 
@@ -188,7 +254,8 @@ func TestArtistDaoLoadAllError(t *testing.T) {
 	dao := mysql.NewArtistDao(db)
 	defer dao.Close()
 
-	result := dao.LoadAll()
+	result, err := dao.LoadAll(context.Background())
+	assert.NotNil(err)
 	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -214,9 +281,9 @@ func TestArtistDaoLoadAllScanError(t *testing.T) {
 	dao := mysql.NewArtistDao(db)
 	defer dao.Close()
 
-	result := dao.LoadAll()
-	assert.NotNil(result)
-	assert.Len(result, 0)
+	result, err := dao.LoadAll(context.Background())
+	assert.NotNil(err)
+	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
@@ -244,9 +311,166 @@ func TestArtistDaoLoadAll(t *testing.T) {
 	dao := mysql.NewArtistDao(db)
 	defer dao.Close()
 
-	result := dao.LoadAll()
+	// A row that can't be read fails the whole load, rather than quietly
+	// leaving artists out
+	result, err := dao.LoadAll(context.Background())
+	assert.NotNil(err)
+	assert.Nil(result)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestArtistDaoLoadMany(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(int64(1), "James").
+		AddRow(int64(3), "Jayne")
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM artist
+        WHERE id IN \(\?, \?\)
+    `).
+		WithArgs(3, 1).
+		WillReturnRows(mockRows)
+
+	dao := mysql.NewArtistDao(db)
+	defer dao.Close()
+
+	result, err := dao.LoadMany(context.Background(), []int64{3, 1, 3})
+	assert.Nil(err)
+	assert.Equal([]model.Artist{
+		{Id: 1, Name: "James"},
+		{Id: 3, Name: "Jayne"},
+	}, result)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestArtistDaoLoadManyEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	dao := mysql.NewArtistDao(db)
+	defer dao.Close()
+
+	result, err := dao.LoadMany(context.Background(), []int64{})
+	assert.Nil(err)
 	assert.NotNil(result)
-	assert.Len(result, 1)
+	assert.Len(result, 0)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestArtistDaoLoadManyError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM artist
+        WHERE id IN \(\?\)
+    `).
+		WithArgs(1).
+		WillReturnError(errors.New("Something bad happened"))
+
+	dao := mysql.NewArtistDao(db)
+	defer dao.Close()
+
+	result, err := dao.LoadMany(context.Background(), []int64{1})
+	assert.NotNil(err)
+	assert.Nil(result)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestArtistDaoLoadByName(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM artist
+        WHERE name = \?
+    `).
+		WithArgs("James").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(1), "James"))
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM artist
+        WHERE name = \?
+    `).
+		WithArgs("Nobody").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+	dao := mysql.NewArtistDao(db)
+	defer dao.Close()
+
+	result, err := dao.LoadByName(context.Background(), "James")
+	assert.Nil(err)
+	assert.Equal(&model.Artist{Id: 1, Name: "James"}, result)
+
+	result, err = dao.LoadByName(context.Background(), "Nobody")
+	assert.Nil(result)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestArtistDaoSaveDuplicate(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	artist := model.Artist{
+		Id:   2,
+		Name: "James",
+	}
+
+	mock.ExpectExec(`
+        INSERT INTO artist\(
+            id,
+            name
+        \)
+        VALUES\(
+            \?,
+            \?
+        \)
+        ON DUPLICATE KEY UPDATE
+            name = VALUES\(name\)
+    `).
+		WithArgs(artist.Id, artist.Name).
+		WillReturnError(&driver.MySQLError{Number: 1062, Message: "Duplicate entry 'James' for key 'name'"})
+
+	dao := mysql.NewArtistDao(db)
+	defer dao.Close()
+
+	lastId, err := dao.Save(context.Background(), artist)
+	assert.Equal(int64(0), lastId)
+	assert.True(errors.Is(err, daopkg.ErrDuplicate))
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
@@ -0,0 +1,203 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type commentDao struct {
+	db *sql.DB
+}
+
+func NewCommentDao(db *sql.DB) dao.CommentDao {
+	return commentDao{
+		db: db,
+	}
+}
+
+func (this commentDao) Close() {
+	logrus.Debug("Closing Comment DAO")
+}
+
+func scanComment(row rowScanner) (model.Comment, error) {
+	var comment model.Comment
+	var albumId, trackId, parentId sql.NullInt64
+	var editedAt, deletedAt sql.NullTime
+
+	err := row.Scan(
+		&comment.Id,
+		&albumId,
+		&trackId,
+		&parentId,
+		&comment.Author,
+		&comment.Body,
+		&comment.Resolved,
+		&comment.CreatedAt,
+		&editedAt,
+		&deletedAt,
+	)
+
+	comment.Target, comment.TargetId = scanTarget(albumId, trackId)
+	if parentId.Valid {
+		comment.ParentId = &parentId.Int64
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		comment.DeletedAt = &deletedAt.Time
+	}
+
+	return comment, err
+}
+
+func (this commentDao) Load(ctx context.Context, id int64) (*model.Comment, error) {
+	row := this.db.QueryRowContext(ctx, `
+        SELECT
+            *
+        FROM comment
+        WHERE id = ?
+    `, id)
+
+	comment, err := scanComment(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: comment %d", dao.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load comment %d: %w", id, translateError(err))
+	}
+
+	return &comment, nil
+}
+
+func (this commentDao) LoadFor(ctx context.Context, target model.Target, targetId int64) ([]model.Comment, error) {
+	column, found := targetColumns[target]
+	if !found {
+		return nil, fmt.Errorf("Unknown target %q", target)
+	}
+
+	rows, err := this.db.QueryContext(ctx, `
+        SELECT
+            *
+        FROM comment
+        WHERE `+column+` = ?
+        ORDER BY
+            created_at ASC,
+            id ASC
+    `, targetId)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load comments for %s %d: %w", target, targetId, translateError(err))
+	}
+	defer rows.Close()
+
+	var ret []model.Comment = make([]model.Comment, 0)
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load comments for %s %d: %w", target, targetId, err)
+		}
+		ret = append(ret, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to load comments for %s %d: %w", target, targetId, translateError(err))
+	}
+	return ret, nil
+}
+
+func (this commentDao) CountForAlbums(ctx context.Context, ids []int64) (map[int64]int64, error) {
+	var ret map[int64]int64 = make(map[int64]int64)
+
+	ids = uniqueIds(ids)
+	if len(ids) == 0 {
+		return ret, nil
+	}
+
+	in, args := inClause(ids)
+	rows, err := this.db.QueryContext(ctx, `
+        SELECT
+            album,
+            COUNT(*)
+        FROM comment
+        WHERE album IN `+in+`
+            AND deleted_at IS NULL
+        GROUP BY album
+    `, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to count comments: %w", translateError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var albumId, count int64
+		if err := rows.Scan(&albumId, &count); err != nil {
+			return nil, fmt.Errorf("Unable to count comments: %w", err)
+		}
+		ret[albumId] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to count comments: %w", translateError(err))
+	}
+	return ret, nil
+}
+
+func (this commentDao) Save(ctx context.Context, comment model.Comment) (int64, error) {
+	albumId, trackId, err := targetArgs(comment.Target, comment.TargetId)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := this.db.ExecContext(ctx, `
+        INSERT INTO comment(
+            id,
+            album,
+            track,
+            parent,
+            author,
+            body,
+            resolved,
+            edited_at,
+            deleted_at
+        )
+        VALUES(
+            ?,
+            ?,
+            ?,
+            ?,
+            ?,
+            ?,
+            ?,
+            ?,
+            ?
+        )
+        ON DUPLICATE KEY UPDATE
+            body = VALUES(body),
+            resolved = VALUES(resolved),
+            edited_at = VALUES(edited_at),
+            deleted_at = VALUES(deleted_at)
+    `,
+		comment.Id,
+		albumId,
+		trackId,
+		comment.ParentId,
+		comment.Author,
+		comment.Body,
+		comment.Resolved,
+		comment.EditedAt,
+		comment.DeletedAt,
+	)
+
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
@@ -0,0 +1,321 @@
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	daopkg "citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/dao/mysql"
+	"citadel_intranet/src/db/model"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var commentColumns = []string{"id", "album", "track", "parent", "author", "body", "resolved", "created_at", "edited_at", "deleted_at"}
+
+func TestCommentDaoLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	editedAt := createdAt.Add(time.Hour)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM comment
+        WHERE id = \?
+    `).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(commentColumns).
+			AddRow(7, nil, 12, 3, "James", "*Much* better", true, createdAt, editedAt, nil))
+
+	dao := mysql.NewCommentDao(db)
+	defer dao.Close()
+
+	parentId := int64(3)
+	comment, err := dao.Load(context.Background(), int64(7))
+	assert.Nil(err)
+	assert.Equal(&model.Comment{
+		Id:        7,
+		Target:    model.TargetTrack,
+		TargetId:  12,
+		ParentId:  &parentId,
+		Author:    "James",
+		Body:      "*Much* better",
+		Resolved:  true,
+		CreatedAt: createdAt,
+		EditedAt:  &editedAt,
+	}, comment)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestCommentDaoLoadError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM comment
+        WHERE id = \?
+    `).
+		WithArgs(7).
+		WillReturnError(errors.New("Something bad happened"))
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM comment
+        WHERE id = \?
+    `).
+		WithArgs(8).
+		WillReturnRows(sqlmock.NewRows(commentColumns))
+
+	dao := mysql.NewCommentDao(db)
+	defer dao.Close()
+
+	// A failed query mustn't look like a missing comment
+	comment, err := dao.Load(context.Background(), int64(7))
+	assert.NotNil(err)
+	assert.False(errors.Is(err, daopkg.ErrNotFound))
+	assert.Nil(comment)
+
+	comment, err = dao.Load(context.Background(), int64(8))
+	assert.True(errors.Is(err, daopkg.ErrNotFound))
+	assert.Nil(comment)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestCommentDaoLoadFor(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM comment
+        WHERE album = \?
+        ORDER BY
+            created_at ASC,
+            id ASC
+    `).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows(commentColumns).
+			AddRow(1, 42, nil, nil, "James", "First", false, createdAt, nil, createdAt).
+			AddRow(2, 42, nil, 1, "Bobby", "Second", false, createdAt, nil, nil))
+
+	dao := mysql.NewCommentDao(db)
+	defer dao.Close()
+
+	comments, err := dao.LoadFor(context.Background(), model.TargetAlbum, int64(42))
+	assert.Nil(err)
+	assert.Len(comments, 2)
+	assert.Equal(int64(1), comments[0].Id)
+	assert.Nil(comments[0].ParentId)
+	assert.True(comments[0].IsDeleted())
+	assert.Equal(int64(2), comments[1].Id)
+	assert.Equal(int64(1), *comments[1].ParentId)
+	assert.False(comments[1].IsDeleted())
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestCommentDaoLoadForError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM comment
+        WHERE track = \?
+    `).
+		WithArgs(12).
+		WillReturnError(errors.New("Something bad happened"))
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM comment
+        WHERE track = \?
+    `).
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows(commentColumns).
+			AddRow("cat", nil, 12, nil, "Bobby", "Broken", false, createdAt, nil, nil))
+
+	dao := mysql.NewCommentDao(db)
+	defer dao.Close()
+
+	comments, err := dao.LoadFor(context.Background(), model.TargetTrack, int64(12))
+	assert.NotNil(err)
+	assert.Nil(comments)
+
+	comments, err = dao.LoadFor(context.Background(), model.TargetTrack, int64(12))
+	assert.NotNil(err)
+	assert.Nil(comments)
+
+	comments, err = dao.LoadFor(context.Background(), "artist", int64(12))
+	assert.NotNil(err)
+	assert.Nil(comments)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestCommentDaoCountForAlbums(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            COUNT\(\*\)
+        FROM comment
+        WHERE album IN \(\?, \?\)
+            AND deleted_at IS NULL
+        GROUP BY album
+    `).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"album", "count"}).AddRow(2, 5))
+
+	dao := mysql.NewCommentDao(db)
+	defer dao.Close()
+
+	counts, err := dao.CountForAlbums(context.Background(), []int64{1, 2, 1})
+	assert.Nil(err)
+	assert.Equal(map[int64]int64{2: 5}, counts)
+
+	counts, err = dao.CountForAlbums(context.Background(), []int64{})
+	assert.Nil(err)
+	assert.Equal(map[int64]int64{}, counts)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestCommentDaoCountForAlbumsError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            COUNT\(\*\)
+        FROM comment
+    `).
+		WithArgs(1).
+		WillReturnError(errors.New("Something bad happened"))
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            COUNT\(\*\)
+        FROM comment
+    `).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"album", "count"}).AddRow("cat", 5))
+
+	dao := mysql.NewCommentDao(db)
+	defer dao.Close()
+
+	counts, err := dao.CountForAlbums(context.Background(), []int64{1})
+	assert.NotNil(err)
+	assert.Nil(counts)
+
+	counts, err = dao.CountForAlbums(context.Background(), []int64{1})
+	assert.NotNil(err)
+	assert.Nil(counts)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestCommentDaoSave(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	parentId := int64(3)
+	deletedAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	comment := model.Comment{
+		Id:        7,
+		Target:    model.TargetAlbum,
+		TargetId:  42,
+		ParentId:  &parentId,
+		Author:    "James",
+		DeletedAt: &deletedAt,
+	}
+
+	mock.ExpectExec(`
+        INSERT INTO comment\(
+            id,
+            album,
+            track,
+            parent,
+            author,
+            body,
+            resolved,
+            edited_at,
+            deleted_at
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?,
+            \?,
+            \?,
+            \?,
+            \?,
+            \?,
+            \?
+        \)
+        ON DUPLICATE KEY UPDATE
+            body = VALUES\(body\),
+            resolved = VALUES\(resolved\),
+            edited_at = VALUES\(edited_at\),
+            deleted_at = VALUES\(deleted_at\)
+    `).
+		WithArgs(7, 42, nil, 3, "James", "", false, nil, deletedAt).
+		WillReturnResult(sqlmock.NewResult(7, 2))
+
+	dao := mysql.NewCommentDao(db)
+	defer dao.Close()
+
+	lastId, err := dao.Save(context.Background(), comment)
+	assert.Nil(err)
+	assert.Equal(int64(7), lastId)
+
+	lastId, err = dao.Save(context.Background(), model.Comment{Target: "artist"})
+	assert.NotNil(err)
+	assert.Equal(int64(0), lastId)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
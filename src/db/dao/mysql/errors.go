@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"citadel_intranet/src/db/dao"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+const (
+	errorCodeDuplicateEntry     = 1062
+	errorCodeTooManyConnections = 1040
+	errorCodeServerShutdown     = 1053
+)
+
+/*
+An error that means the database couldn't be reached, it matches
+dao.ErrUnavailable while still unwrapping to the driver's own error.
+*/
+type unavailableError struct {
+	err error
+}
+
+func (this unavailableError) Error() string {
+	return fmt.Sprintf("%s: %s", dao.ErrUnavailable.Error(), this.err.Error())
+}
+
+func (this unavailableError) Unwrap() error {
+	return this.err
+}
+
+func (this unavailableError) Is(target error) bool {
+	return target == dao.ErrUnavailable
+}
+
+/*
+Translate MySQL specific errors into the backend agnostic errors exposed by
+the dao package, wrapping the original so that it isn't lost.
+*/
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case errorCodeDuplicateEntry:
+			return fmt.Errorf("%w: %s", dao.ErrDuplicate, mysqlErr.Message)
+		case errorCodeTooManyConnections, errorCodeServerShutdown:
+			return unavailableError{err: err}
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysqldriver.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return unavailableError{err: err}
+	}
+
+	return err
+}
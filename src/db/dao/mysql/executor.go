@@ -0,0 +1,66 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+)
+
+/*
+Anything queries can be run through, either the database itself or a
+transaction on it. A DAO built on a transaction takes part in it and leaves
+committing or rolling back to whoever began it.
+*/
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+/*
+Run work within a new transaction on db, committing it when work returns nil
+and rolling it back otherwise. A panic in work rolls the transaction back too,
+before carrying on up the stack.
+*/
+func InTransaction(ctx context.Context, db *sql.DB, work func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Left open, a transaction holds on to its connection and any locks it
+	// took until the context ends, which for a background context is never.
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+
+		recovered := recover()
+		tx.Rollback()
+		if recovered != nil {
+			panic(recovered)
+		}
+	}()
+
+	if err = work(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return translateError(tx.Commit())
+}
+
+/*
+Run work within a transaction. When executor is already a transaction the work
+simply joins it, otherwise a new one is begun for the work alone.
+*/
+func inTransaction(ctx context.Context, executor Executor, work func(tx Executor) error) error {
+	db, ok := executor.(*sql.DB)
+	if !ok {
+		return work(executor)
+	}
+
+	return InTransaction(ctx, db, func(tx *sql.Tx) error {
+		return work(tx)
+	})
+}
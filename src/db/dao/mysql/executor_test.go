@@ -0,0 +1,58 @@
+package mysql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"citadel_intranet/src/db/dao/mysql"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	assert.Nil(mysql.InTransaction(context.Background(), db, func(tx *sql.Tx) error {
+		return nil
+	}))
+
+	failed := errors.New("Something bad happened")
+	assert.Equal(failed, mysql.InTransaction(context.Background(), db, func(tx *sql.Tx) error {
+		return failed
+	}))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestInTransactionPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	assert.PanicsWithValue("Something bad happened", func() {
+		mysql.InTransaction(context.Background(), db, func(tx *sql.Tx) error {
+			panic("Something bad happened")
+		})
+	})
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"strings"
+)
+
+/*
+Build the placeholder list and matching arguments for an `IN (...)` clause.
+*/
+func inClause(ids []int64) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+
+	for index, id := range ids {
+		placeholders[index] = "?"
+		args[index] = id
+	}
+
+	return "(" + strings.Join(placeholders, ", ") + ")", args
+}
+
+/*
+Remove duplicate ids, keeping the order they were first seen in.
+*/
+func uniqueIds(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	ret := make([]int64, 0, len(ids))
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			ret = append(ret, id)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,260 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type proposalDao struct {
+	db *sql.DB
+}
+
+func NewProposalDao(db *sql.DB) dao.ProposalDao {
+	return proposalDao{
+		db: db,
+	}
+}
+
+func (this proposalDao) Close() {
+	logrus.Debug("Closing Proposal DAO")
+}
+
+const proposalSelect = `
+        SELECT
+            proposal.id,
+            proposal.album,
+            proposal.track,
+            proposal.title,
+            proposal.proposed_by,
+            proposal.status,
+            proposal.created_at,
+            COALESCE(SUM(vote.vote), 0) AS score
+        FROM title_proposal AS proposal
+        LEFT JOIN title_proposal_vote AS vote
+            ON vote.proposal = proposal.id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProposal(row rowScanner) (model.TitleProposal, error) {
+	var proposal model.TitleProposal
+	var albumId sql.NullInt64
+	var trackId sql.NullInt64
+
+	err := row.Scan(
+		&proposal.Id,
+		&albumId,
+		&trackId,
+		&proposal.Title,
+		&proposal.ProposedBy,
+		&proposal.Status,
+		&proposal.CreatedAt,
+		&proposal.Score,
+	)
+
+	proposal.Target, proposal.TargetId = scanTarget(albumId, trackId)
+
+	return proposal, err
+}
+
+func (this proposalDao) Load(ctx context.Context, id int64) (*model.TitleProposal, error) {
+	row := this.db.QueryRowContext(ctx, proposalSelect+`
+        WHERE proposal.id = ?
+        GROUP BY proposal.id
+    `, id)
+
+	proposal, err := scanProposal(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: proposal %d", dao.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load proposal %d: %w", id, translateError(err))
+	}
+
+	return &proposal, nil
+}
+
+func (this proposalDao) LoadFor(ctx context.Context, target model.Target, targetId int64) ([]model.TitleProposal, error) {
+	column, found := targetColumns[target]
+	if !found {
+		return nil, fmt.Errorf("Unknown target %q", target)
+	}
+
+	rows, err := this.db.QueryContext(ctx, proposalSelect+`
+        WHERE proposal.`+column+` = ?
+        GROUP BY proposal.id
+        ORDER BY
+            score DESC,
+            proposal.id ASC
+    `, targetId)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load proposals for %s %d: %w", target, targetId, translateError(err))
+	}
+	defer rows.Close()
+
+	var ret []model.TitleProposal = make([]model.TitleProposal, 0)
+	for rows.Next() {
+		proposal, err := scanProposal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load proposals for %s %d: %w", target, targetId, err)
+		}
+		ret = append(ret, proposal)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to load proposals for %s %d: %w", target, targetId, translateError(err))
+	}
+	return ret, nil
+}
+
+func (this proposalDao) Save(ctx context.Context, proposal model.TitleProposal) (int64, error) {
+	albumId, trackId, err := targetArgs(proposal.Target, proposal.TargetId)
+	if err != nil {
+		return 0, err
+	}
+
+	status := proposal.Status
+	if status == "" {
+		status = model.ProposalOpen
+	}
+
+	result, err := this.db.ExecContext(ctx, `
+        INSERT INTO title_proposal(
+            id,
+            album,
+            track,
+            title,
+            proposed_by,
+            status
+        )
+        VALUES(
+            ?,
+            ?,
+            ?,
+            ?,
+            ?,
+            ?
+        )
+        ON DUPLICATE KEY UPDATE
+            title = VALUES(title),
+            status = VALUES(status)
+    `,
+		proposal.Id,
+		albumId,
+		trackId,
+		proposal.Title,
+		proposal.ProposedBy,
+		status,
+	)
+
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+func (this proposalDao) Vote(ctx context.Context, vote model.ProposalVote) error {
+	_, err := this.db.ExecContext(ctx, `
+        INSERT INTO title_proposal_vote(
+            proposal,
+            voter,
+            vote
+        )
+        VALUES(
+            ?,
+            ?,
+            ?
+        )
+        ON DUPLICATE KEY UPDATE
+            vote = VALUES(vote)
+    `,
+		vote.ProposalId,
+		vote.Voter,
+		vote.Vote,
+	)
+
+	return translateError(err)
+}
+
+func (this proposalDao) Accept(ctx context.Context, id int64) error {
+	return InTransaction(ctx, this.db, func(tx *sql.Tx) error {
+		var albumId sql.NullInt64
+		var trackId sql.NullInt64
+		var title string
+		var status model.ProposalStatus
+
+		err := tx.QueryRowContext(ctx, `
+            SELECT
+                album,
+                track,
+                title,
+                status
+            FROM title_proposal
+            WHERE id = ?
+            FOR UPDATE
+        `, id).Scan(&albumId, &trackId, &title, &status)
+
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: proposal %d no longer exists", dao.ErrConflict, id)
+		}
+		if err != nil {
+			return translateError(err)
+		}
+
+		if status != model.ProposalOpen {
+			return fmt.Errorf("%w: proposal %d is already %s", dao.ErrConflict, id, status)
+		}
+
+		column := targetColumns[model.TargetAlbum]
+		targetId := albumId.Int64
+		if trackId.Valid {
+			column = targetColumns[model.TargetTrack]
+			targetId = trackId.Int64
+		}
+
+		// Renaming is a change like any other, so copies read before the
+		// accept are out of date.
+		_, err = tx.ExecContext(ctx, `
+            UPDATE `+column+`
+            SET
+                title = ?,
+                version = version + 1
+            WHERE id = ?
+        `, title, targetId)
+
+		if err != nil {
+			return translateError(err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+            UPDATE title_proposal
+            SET
+                status = IF(id = ?, 'accepted', 'rejected')
+            WHERE `+column+` = ?
+                AND status = 'open'
+        `, id, targetId)
+
+		return translateError(err)
+	})
+}
+
+func (this proposalDao) Delete(ctx context.Context, proposal model.TitleProposal) (int64, error) {
+	result, err := this.db.ExecContext(ctx, `
+        DELETE
+        FROM title_proposal
+        WHERE id = ?
+    `, proposal.Id)
+
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.RowsAffected()
+}
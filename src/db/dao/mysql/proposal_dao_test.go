@@ -0,0 +1,445 @@
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	daopkg "citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/dao/mysql"
+	"citadel_intranet/src/db/model"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const proposalSelect = `
+        SELECT
+            proposal\.id,
+            proposal\.album,
+            proposal\.track,
+            proposal\.title,
+            proposal\.proposed_by,
+            proposal\.status,
+            proposal\.created_at,
+            COALESCE\(SUM\(vote\.vote\), 0\) AS score
+        FROM title_proposal AS proposal
+        LEFT JOIN title_proposal_vote AS vote
+            ON vote\.proposal = proposal\.id`
+
+var proposalColumns = []string{"id", "album", "track", "title", "proposed_by", "status", "created_at", "score"}
+
+func TestProposalDaoLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(proposalSelect + `
+        WHERE proposal\.id = \?
+        GROUP BY proposal\.id
+    `).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(proposalColumns).
+			AddRow(7, nil, 12, "Pancake Irons", "James", "open", createdAt, 3))
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	proposal, err := dao.Load(context.Background(), int64(7))
+	assert.Nil(err)
+	assert.Equal(&model.TitleProposal{
+		Id:         7,
+		Target:     model.TargetTrack,
+		TargetId:   12,
+		Title:      "Pancake Irons",
+		ProposedBy: "James",
+		Status:     model.ProposalOpen,
+		Score:      3,
+		CreatedAt:  createdAt,
+	}, proposal)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoLoadError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(proposalSelect + `
+        WHERE proposal\.id = \?
+        GROUP BY proposal\.id
+    `).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(proposalColumns))
+	mock.ExpectQuery(proposalSelect + `
+        WHERE proposal\.id = \?
+        GROUP BY proposal\.id
+    `).
+		WithArgs(8).
+		WillReturnError(errors.New("Something bad happened"))
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	proposal, err := dao.Load(context.Background(), int64(7))
+	assert.True(errors.Is(err, daopkg.ErrNotFound))
+	assert.Nil(proposal)
+
+	// A failed query mustn't look like a missing proposal
+	proposal, err = dao.Load(context.Background(), int64(8))
+	assert.NotNil(err)
+	assert.False(errors.Is(err, daopkg.ErrNotFound))
+	assert.Nil(proposal)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoLoadFor(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(proposalSelect + `
+        WHERE proposal\.album = \?
+        GROUP BY proposal\.id
+        ORDER BY
+            score DESC,
+            proposal\.id ASC
+    `).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows(proposalColumns).
+			AddRow(2, 42, nil, "Pancake Irons", "James", "open", createdAt, 4).
+			AddRow(1, 42, nil, "Crepe Irons", "Bobby", "rejected", createdAt, -1))
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	proposals, err := dao.LoadFor(context.Background(), model.TargetAlbum, int64(42))
+	assert.Nil(err)
+	assert.Len(proposals, 2)
+	assert.Equal(int64(2), proposals[0].Id)
+	assert.Equal(model.TargetAlbum, proposals[0].Target)
+	assert.Equal(int64(42), proposals[0].TargetId)
+	assert.Equal(int64(4), proposals[0].Score)
+	assert.Equal(int64(1), proposals[1].Id)
+	assert.Equal(model.ProposalRejected, proposals[1].Status)
+	assert.Equal(int64(-1), proposals[1].Score)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoLoadForError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(proposalSelect + `
+        WHERE proposal\.track = \?
+        GROUP BY proposal\.id
+        ORDER BY
+            score DESC,
+            proposal\.id ASC
+    `).
+		WithArgs(12).
+		WillReturnError(errors.New("Something bad happened"))
+	mock.ExpectQuery(proposalSelect + `
+        WHERE proposal\.track = \?
+    `).
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows(proposalColumns).
+			AddRow("cat", nil, 12, "Broken", "Bobby", "open", createdAt, 0))
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	proposals, err := dao.LoadFor(context.Background(), model.TargetTrack, int64(12))
+	assert.NotNil(err)
+	assert.Nil(proposals)
+
+	proposals, err = dao.LoadFor(context.Background(), model.TargetTrack, int64(12))
+	assert.NotNil(err)
+	assert.Nil(proposals)
+
+	proposals, err = dao.LoadFor(context.Background(), "artist", int64(12))
+	assert.NotNil(err)
+	assert.Nil(proposals)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoSave(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectExec(`
+        INSERT INTO title_proposal\(
+            id,
+            album,
+            track,
+            title,
+            proposed_by,
+            status
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?,
+            \?,
+            \?,
+            \?
+        \)
+        ON DUPLICATE KEY UPDATE
+            title = VALUES\(title\),
+            status = VALUES\(status\)
+    `).
+		WithArgs(0, nil, 12, "Pancake Irons", "James", "open").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	lastId, err := dao.Save(context.Background(), model.TitleProposal{
+		Target:     model.TargetTrack,
+		TargetId:   12,
+		Title:      "Pancake Irons",
+		ProposedBy: "James",
+	})
+	assert.Nil(err)
+	assert.Equal(int64(7), lastId)
+
+	lastId, err = dao.Save(context.Background(), model.TitleProposal{Target: "artist", TargetId: 12})
+	assert.NotNil(err)
+	assert.Equal(int64(0), lastId)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoVote(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectExec(`
+        INSERT INTO title_proposal_vote\(
+            proposal,
+            voter,
+            vote
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?
+        \)
+        ON DUPLICATE KEY UPDATE
+            vote = VALUES\(vote\)
+    `).
+		WithArgs(7, "Bobby", -1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.Vote(context.Background(), model.ProposalVote{ProposalId: 7, Voter: "Bobby", Vote: -1}))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoAccept(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            track,
+            title,
+            status
+        FROM title_proposal
+        WHERE id = \?
+        FOR UPDATE
+    `).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"album", "track", "title", "status"}).
+			AddRow(nil, 12, "Pancake Irons", "open"))
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            title = \?,
+            version = version \+ 1
+        WHERE id = \?
+    `).
+		WithArgs("Pancake Irons", 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`
+        UPDATE title_proposal
+        SET
+            status = IF\(id = \?, 'accepted', 'rejected'\)
+        WHERE track = \?
+            AND status = 'open'
+    `).
+		WithArgs(7, 12).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.Accept(context.Background(), int64(7)))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoAcceptAlbum(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            track,
+            title,
+            status
+        FROM title_proposal
+        WHERE id = \?
+        FOR UPDATE
+    `).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"album", "track", "title", "status"}).
+			AddRow(42, nil, "Pancake Irons", "open"))
+	// The album's version is bumped along with its title, so that updates
+	// made from a copy read before the accept are turned away.
+	mock.ExpectExec(`
+        UPDATE album
+        SET
+            title = \?,
+            version = version \+ 1
+        WHERE id = \?
+    `).
+		WithArgs("Pancake Irons", 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`
+        UPDATE title_proposal
+        SET
+            status = IF\(id = \?, 'accepted', 'rejected'\)
+        WHERE album = \?
+            AND status = 'open'
+    `).
+		WithArgs(7, 42).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.Accept(context.Background(), int64(7)))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoAcceptClosed(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            track,
+            title,
+            status
+        FROM title_proposal
+        WHERE id = \?
+        FOR UPDATE
+    `).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"album", "track", "title", "status"}).
+			AddRow(42, nil, "Pancake Irons", "rejected"))
+	mock.ExpectRollback()
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	err = dao.Accept(context.Background(), int64(7))
+	assert.True(errors.Is(err, daopkg.ErrConflict))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestProposalDaoAcceptError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`
+        SELECT
+            album,
+            track,
+            title,
+            status
+        FROM title_proposal
+        WHERE id = \?
+        FOR UPDATE
+    `).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"album", "track", "title", "status"}).
+			AddRow(42, nil, "Pancake Irons", "open"))
+	mock.ExpectExec(`
+        UPDATE album
+        SET
+            title = \?,
+            version = version \+ 1
+        WHERE id = \?
+    `).
+		WithArgs("Pancake Irons", 42).
+		WillReturnError(errors.New("Deadlock found"))
+	mock.ExpectRollback()
+
+	dao := mysql.NewProposalDao(db)
+	defer dao.Close()
+
+	assert.NotNil(dao.Accept(context.Background(), int64(7)))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type sessionDao struct {
+	db *sql.DB
+}
+
+func NewSessionDao(db *sql.DB) dao.SessionDao {
+	return sessionDao{
+		db: db,
+	}
+}
+
+func (this sessionDao) Close() {
+	logrus.Debug("Closing Session DAO")
+}
+
+func (this sessionDao) Load(id string) *model.Session {
+	var session model.Session
+
+	row := this.db.QueryRow(`
+        SELECT
+            *
+        FROM session
+        WHERE id = ?
+    `, id)
+
+	err := row.Scan(&session.Id, &session.UserId, &session.CreatedAt, &session.ExpiresAt)
+
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Warn("Unable to load session ", err.Error())
+		}
+		return nil
+	}
+
+	return &session
+}
+
+func (this sessionDao) Save(session model.Session) error {
+	_, err := this.db.Exec(`
+        INSERT INTO session(
+            id,
+            user,
+            expires_at
+        )
+        VALUES(
+            ?,
+            ?,
+            ?
+        )
+    `,
+		session.Id,
+		session.UserId,
+		session.ExpiresAt,
+	)
+
+	return err
+}
+
+func (this sessionDao) Delete(id string) (int64, error) {
+	result, err := this.db.Exec(`
+        DELETE
+        FROM session
+        WHERE id = ?
+    `, id)
+
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
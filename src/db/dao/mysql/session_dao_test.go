@@ -0,0 +1,123 @@
+package mysql_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"citadel_intranet/src/db/dao/mysql"
+	"citadel_intranet/src/db/model"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionDao(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	session := model.Session{
+		Id:        "abc123",
+		UserId:    1,
+		ExpiresAt: createdAt.Add(time.Hour),
+	}
+
+	mock.ExpectExec(`
+        INSERT INTO session\(
+            id,
+            user,
+            expires_at
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?
+        \)
+    `).
+		WithArgs(session.Id, session.UserId, session.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM session
+        WHERE id = \?
+    `).
+		WithArgs(session.Id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user", "created_at", "expires_at"}).
+			AddRow(session.Id, session.UserId, createdAt, session.ExpiresAt))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM session
+        WHERE id = \?
+    `).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user", "created_at", "expires_at"}))
+
+	mock.ExpectExec(`
+        DELETE
+        FROM session
+        WHERE id = \?
+    `).
+		WithArgs(session.Id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dao := mysql.NewSessionDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.Save(session))
+
+	session.CreatedAt = createdAt
+	assert.Equal(&session, dao.Load(session.Id))
+	assert.Nil(dao.Load("missing"))
+
+	rows, err := dao.Delete(session.Id)
+	assert.Nil(err)
+	assert.Equal(int64(1), rows)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestSessionDaoErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM session
+        WHERE id = \?
+    `).
+		WithArgs("abc123").
+		WillReturnError(errors.New("Something bad happened"))
+
+	mock.ExpectExec(`
+        DELETE
+        FROM session
+        WHERE id = \?
+    `).
+		WithArgs("abc123").
+		WillReturnError(errors.New("Something bad happened"))
+
+	dao := mysql.NewSessionDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.Load("abc123"))
+
+	rows, err := dao.Delete("abc123")
+	assert.Equal(int64(0), rows)
+	assert.NotNil(err)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
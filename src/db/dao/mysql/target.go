@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+
+	"citadel_intranet/src/db/model"
+)
+
+/*
+Tables that can be attached to either an album or a track have a nullable
+column for each, named after the table holding the album or track.
+*/
+var targetColumns = map[model.Target]string{
+	model.TargetAlbum: "album",
+	model.TargetTrack: "track",
+}
+
+/*
+Work out which album or track a row is attached to from its nullable album
+and track columns.
+*/
+func scanTarget(albumId sql.NullInt64, trackId sql.NullInt64) (model.Target, int64) {
+	if albumId.Valid {
+		return model.TargetAlbum, albumId.Int64
+	} else if trackId.Valid {
+		return model.TargetTrack, trackId.Int64
+	}
+
+	return "", 0
+}
+
+/*
+Build the album and track column values for a row attached to target, one of
+which is always NULL.
+*/
+func targetArgs(target model.Target, targetId int64) (interface{}, interface{}, error) {
+	switch target {
+	case model.TargetAlbum:
+		return targetId, nil, nil
+	case model.TargetTrack:
+		return nil, targetId, nil
+	}
+
+	return nil, nil, fmt.Errorf("Unknown target %q", target)
+}
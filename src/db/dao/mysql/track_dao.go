@@ -1,7 +1,9 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	"citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/model"
@@ -10,10 +12,10 @@ import (
 )
 
 type trackDao struct {
-	db *sql.DB
+	db Executor
 }
 
-func NewTrackDao(db *sql.DB) dao.TrackDao {
+func NewTrackDao(db Executor) dao.TrackDao {
 	return trackDao{
 		db: db,
 	}
@@ -23,96 +25,241 @@ func (this trackDao) Close() {
 	logrus.Debug("Closing Track DAO")
 }
 
-func (this trackDao) scanAll(rows *sql.Rows) []model.Track {
-	var ret []model.Track = make([]model.Track, 0)
+/*
+Scan every track row, closing the rows once they've all been read.
+*/
+func (this trackDao) scanAll(rows *sql.Rows) ([]model.Track, error) {
+	defer rows.Close()
 
+	var ret []model.Track = make([]model.Track, 0)
 	for rows.Next() {
 		var track model.Track
-		err := rows.Scan(&track.Id, &track.Title, &track.AlbumId, &track.Rating)
-
+		err := rows.Scan(&track.Id, &track.Title, &track.AlbumId, &track.Rating, &track.DiscNumber, &track.TrackNumber, &track.Version)
 		if err != nil {
-			logrus.Warn(err.Error())
-		} else {
-			ret = append(ret, track)
+			return nil, err
 		}
+		ret = append(ret, track)
 	}
 
-	return ret
+	return ret, rows.Err()
 }
 
-func (this trackDao) LoadForAlbum(id int64) []model.Track {
-	rows, err := this.db.Query(`
+func (this trackDao) LoadForAlbum(ctx context.Context, id int64) ([]model.Track, error) {
+	rows, err := this.db.QueryContext(ctx, `
         SELECT
             *
         FROM track
         WHERE album = ?
+        ORDER BY
+            disc_number ASC,
+            track_number ASC,
+            id ASC
     `, id)
 
 	if err != nil {
-		logrus.Warn("Unable to load tracks ", err.Error())
-		return nil
+		return nil, fmt.Errorf("Unable to load tracks for album %d: %w", id, translateError(err))
 	}
 
-	return this.scanAll(rows)
+	tracks, err := this.scanAll(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load tracks for album %d: %w", id, translateError(err))
+	}
+	return tracks, nil
 }
 
-func (this trackDao) Load(id int64) *model.Track {
+func (this trackDao) LoadForAlbums(ctx context.Context, ids []int64) (map[int64][]model.Track, error) {
+	var ret map[int64][]model.Track = make(map[int64][]model.Track)
+
+	ids = uniqueIds(ids)
+	if len(ids) == 0 {
+		return ret, nil
+	}
+
+	in, args := inClause(ids)
+	rows, err := this.db.QueryContext(ctx, `
+        SELECT
+            *
+        FROM track
+        WHERE album IN `+in+`
+        ORDER BY
+            album ASC,
+            disc_number ASC,
+            track_number ASC,
+            id ASC
+    `, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load tracks: %w", translateError(err))
+	}
+
+	tracks, err := this.scanAll(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load tracks: %w", translateError(err))
+	}
+
+	for _, track := range tracks {
+		ret[track.AlbumId] = append(ret[track.AlbumId], track)
+	}
+
+	return ret, nil
+}
+
+func (this trackDao) Load(ctx context.Context, id int64) (*model.Track, error) {
 	var track *model.Track = &model.Track{}
 
-	row := this.db.QueryRow(`
+	row := this.db.QueryRowContext(ctx, `
         SELECT
             *
         FROM track
         WHERE id = ?
     `, id)
 
-	err := row.Scan(&track.Id, &track.Title, &track.AlbumId, &track.Rating)
+	err := row.Scan(&track.Id, &track.Title, &track.AlbumId, &track.Rating, &track.DiscNumber, &track.TrackNumber, &track.Version)
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: track %d", dao.ErrNotFound, id)
+	}
 	if err != nil {
-		logrus.Warn("Loading failed for ", id, " ", err.Error())
-		return nil
+		return nil, fmt.Errorf("Unable to load track %d: %w", id, translateError(err))
 	}
 
-	return track
+	return track, nil
 }
 
-func (this trackDao) LoadAll() []model.Track {
-	rows, err := this.db.Query(`
+func (this trackDao) LoadAll(ctx context.Context) ([]model.Track, error) {
+	rows, err := this.db.QueryContext(ctx, `
         SELECT
             *
         FROM track
     `)
 
 	if err != nil {
-		logrus.Warn("Unable to load tracks ", err.Error())
-		return nil
+		return nil, fmt.Errorf("Unable to load tracks: %w", translateError(err))
 	}
 
-	return this.scanAll(rows)
+	tracks, err := this.scanAll(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load tracks: %w", translateError(err))
+	}
+	return tracks, nil
 }
 
-func (this trackDao) Delete(track model.Track) (int64, error) {
-	result, err := this.db.Exec(`
+/*
+Lock the tracks on an album for the rest of the transaction, making sure they
+are still exactly the tracks being moved. The order was checked against the
+album's tracks before the transaction began, which may have changed since.
+*/
+func (this trackDao) checkReorderedTracks(ctx context.Context, tx Executor, albumId int64, positions []model.TrackPosition) error {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT
+            id
+        FROM track
+        WHERE album = ?
+        FOR UPDATE
+    `, albumId)
+
+	if err != nil {
+		return translateError(err)
+	}
+	defer rows.Close()
+
+	onAlbum := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		onAlbum[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return translateError(err)
+	}
+
+	changed := len(onAlbum) != len(positions)
+	for _, position := range positions {
+		changed = changed || !onAlbum[position.Id]
+	}
+
+	if changed {
+		return fmt.Errorf("%w: the tracks on album %d have changed", dao.ErrConflict, albumId)
+	}
+	return nil
+}
+
+func (this trackDao) Reorder(ctx context.Context, albumId int64, positions []model.TrackPosition) error {
+	return inTransaction(ctx, this.db, func(tx Executor) error {
+		if err := this.checkReorderedTracks(ctx, tx, albumId, positions); err != nil {
+			return err
+		}
+
+		// Positions are unique on an album, so every track is moved out of
+		// the way before any of them is put in its new place.
+		_, err := tx.ExecContext(ctx, `
+        UPDATE track
+        SET
+            track_number = -track_number
+        WHERE album = ?
+    `, albumId)
+
+		if err != nil {
+			return translateError(err)
+		}
+
+		for _, position := range positions {
+			_, err := tx.ExecContext(ctx, `
+        UPDATE track
+        SET
+            disc_number = ?,
+            track_number = ?,
+            version = version + 1
+        WHERE id = ?
+            AND album = ?
+    `,
+				position.DiscNumber,
+				position.TrackNumber,
+				position.Id,
+				albumId,
+			)
+
+			if err != nil {
+				return translateError(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (this trackDao) Delete(ctx context.Context, track model.Track) (int64, error) {
+	result, err := this.db.ExecContext(ctx, `
         DELETE
         FROM track
         WHERE id = ?
     `, track.Id)
 
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return result.RowsAffected()
 }
 
-func (this trackDao) Save(track model.Track) (int64, error) {
-	result, err := this.db.Exec(`
+func (this trackDao) Save(ctx context.Context, track model.Track) (int64, error) {
+	if track.Id != 0 && track.Version != 0 {
+		return this.update(ctx, track)
+	}
+
+	result, err := this.db.ExecContext(ctx, `
         INSERT INTO track(
             id,
             title,
             album,
-            rating
+            rating,
+            disc_number,
+            track_number
         )
         VALUES(
+            ?,
+            ?,
             ?,
             ?,
             ?,
@@ -121,16 +268,62 @@ func (this trackDao) Save(track model.Track) (int64, error) {
         ON DUPLICATE KEY UPDATE
             title = VALUES(title),
             album = VALUES(album),
-            rating = VALUES(rating)
+            rating = VALUES(rating),
+            disc_number = VALUES(disc_number),
+            track_number = VALUES(track_number),
+            version = version + 1
     `,
 		track.Id,
 		track.Title,
 		track.AlbumId,
 		track.Rating,
+		track.DiscNumber,
+		track.TrackNumber,
 	)
 
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return result.LastInsertId()
 }
+
+/*
+Update an existing track, but only if it's still at the version it was read
+at.
+*/
+func (this trackDao) update(ctx context.Context, track model.Track) (int64, error) {
+	result, err := this.db.ExecContext(ctx, `
+        UPDATE track
+        SET
+            title = ?,
+            album = ?,
+            rating = ?,
+            disc_number = ?,
+            track_number = ?,
+            version = version + 1
+        WHERE id = ?
+            AND version = ?
+    `,
+		track.Title,
+		track.AlbumId,
+		track.Rating,
+		track.DiscNumber,
+		track.TrackNumber,
+		track.Id,
+		track.Version,
+	)
+
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	if rowsAffected == 0 {
+		return 0, fmt.Errorf("%w: track %d is no longer at version %d", dao.ErrConflict, track.Id, track.Version)
+	}
+	return track.Id, nil
+}
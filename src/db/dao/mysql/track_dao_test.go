@@ -1,9 +1,11 @@
 package mysql_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
+	daopkg "citadel_intranet/src/db/dao"
 	"citadel_intranet/src/db/dao/mysql"
 	"citadel_intranet/src/db/model"
 
@@ -31,9 +33,13 @@ func TestTrackDao(t *testing.T) {
             id,
             title,
             album,
-            rating
+            rating,
+            disc_number,
+            track_number
         \)
         VALUES\(
+            \?,
+            \?,
             \?,
             \?,
             \?,
@@ -42,13 +48,16 @@ func TestTrackDao(t *testing.T) {
         ON DUPLICATE KEY UPDATE
             title = VALUES\(title\),
             album = VALUES\(album\),
-            rating = VALUES\(rating\)
+            rating = VALUES\(rating\),
+            disc_number = VALUES\(disc_number\),
+            track_number = VALUES\(track_number\),
+            version = version \+ 1
     `).
-		WithArgs(track.Id, track.Title, track.AlbumId, track.Rating).
+		WithArgs(track.Id, track.Title, track.AlbumId, track.Rating, track.DiscNumber, track.TrackNumber).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating"}).
-		AddRow(int64(456), "Something Awesome", int64(123), uint(5))
+	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating", "disc_number", "track_number", "version"}).
+		AddRow(int64(456), "Something Awesome", int64(123), uint(5), 1, 1, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
@@ -69,17 +78,18 @@ func TestTrackDao(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	rows, err := dao.Save(track)
+	rows, err := dao.Save(context.Background(), track)
 	assert.Nil(err)
 	assert.Equal(int64(1), rows)
 
-	result := dao.Load(int64(456))
+	result, err := dao.Load(context.Background(), int64(456))
+	assert.Nil(err)
 	assert.NotNil(result)
 	assert.Equal(track.Title, result.Title)
 	assert.Equal(track.AlbumId, result.AlbumId)
 	assert.Equal(track.Rating, result.Rating)
 
-	rows, err = dao.Delete(track)
+	rows, err = dao.Delete(context.Background(), track)
 	assert.Nil(err)
 	assert.Equal(int64(1), rows)
 
@@ -94,15 +104,19 @@ func TestTrackDaoLoadForAlbum(t *testing.T) {
 
 	defer db.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating"}).
-		AddRow(int64(457), "Track 1", int64(123), uint(5)).
-		AddRow(int64(456), "Track 2", int64(123), uint(5)).
-		AddRow(int64(458), "Track 3", int64(123), uint(5))
+	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating", "disc_number", "track_number", "version"}).
+		AddRow(int64(457), "Track 1", int64(123), uint(5), 1, 1, 1).
+		AddRow(int64(456), "Track 2", int64(123), uint(5), 1, 2, 1).
+		AddRow(int64(458), "Track 3", int64(123), uint(5), 1, 3, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
         FROM track
         WHERE album = \?
+        ORDER BY
+            disc_number ASC,
+            track_number ASC,
+            id ASC
     `).
 		WithArgs(1).
 		WillReturnRows(mockRows)
@@ -110,7 +124,8 @@ func TestTrackDaoLoadForAlbum(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	result := dao.LoadForAlbum(int64(1))
+	result, err := dao.LoadForAlbum(context.Background(), int64(1))
+	assert.Nil(err)
 	assert.Len(result, 3)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -129,6 +144,10 @@ func TestTrackDaoLoadForAlbumError(t *testing.T) {
             \*
         FROM track
         WHERE album = \?
+        ORDER BY
+            disc_number ASC,
+            track_number ASC,
+            id ASC
     `).
 		WithArgs(1).
 		WillReturnError(errors.New("Something bad happened"))
@@ -136,7 +155,8 @@ func TestTrackDaoLoadForAlbumError(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	result := dao.LoadForAlbum(int64(1))
+	result, err := dao.LoadForAlbum(context.Background(), int64(1))
+	assert.NotNil(err)
 	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -162,7 +182,8 @@ func TestTrackDaoLoadError(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	result := dao.Load(int64(1))
+	result, err := dao.Load(context.Background(), int64(1))
+	assert.NotNil(err)
 	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -192,7 +213,7 @@ func TestTrackDaoDeleteError(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	rowsAffected, err := dao.Delete(track)
+	rowsAffected, err := dao.Delete(context.Background(), track)
 	assert.Equal(int64(0), rowsAffected)
 	assert.NotNil(err)
 
@@ -217,9 +238,13 @@ func TestTrackDaoSaveError(t *testing.T) {
             id,
             title,
             album,
-            rating
+            rating,
+            disc_number,
+            track_number
         \)
         VALUES\(
+            \?,
+            \?,
             \?,
             \?,
             \?,
@@ -228,21 +253,67 @@ func TestTrackDaoSaveError(t *testing.T) {
         ON DUPLICATE KEY UPDATE
             title = VALUES\(title\),
             album = VALUES\(album\),
-            rating = VALUES\(rating\)
+            rating = VALUES\(rating\),
+            disc_number = VALUES\(disc_number\),
+            track_number = VALUES\(track_number\),
+            version = version \+ 1
     `).
-		WithArgs(track.Id, track.Title, track.AlbumId, track.Rating).
+		WithArgs(track.Id, track.Title, track.AlbumId, track.Rating, track.DiscNumber, track.TrackNumber).
 		WillReturnError(errors.New("That's not a real user"))
 
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	lastId, err := dao.Save(track)
+	lastId, err := dao.Save(context.Background(), track)
 	assert.Equal(int64(0), lastId)
 	assert.NotNil(err)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
 
+func TestTrackDaoSaveStale(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	track := model.Track{
+		Id:          456,
+		Title:       "Something Awesome",
+		AlbumId:     123,
+		Rating:      5,
+		DiscNumber:  1,
+		TrackNumber: 2,
+		Version:     3,
+	}
+
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            title = \?,
+            album = \?,
+            rating = \?,
+            disc_number = \?,
+            track_number = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND version = \?
+    `).
+		WithArgs(track.Title, track.AlbumId, track.Rating, track.DiscNumber, track.TrackNumber, track.Id, track.Version).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	dao := mysql.NewTrackDao(db)
+	defer dao.Close()
+
+	lastId, err := dao.Save(context.Background(), track)
+	assert.Equal(int64(0), lastId)
+	assert.True(errors.Is(err, daopkg.ErrConflict))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
 func TestTrackDaoLoadAllError(t *testing.T) {
 	assert := assert.New(t)
 
@@ -260,7 +331,8 @@ func TestTrackDaoLoadAllError(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	result := dao.LoadAll()
+	result, err := dao.LoadAll(context.Background())
+	assert.NotNil(err)
 	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
@@ -274,8 +346,8 @@ func TestTrackDaoLoadAllScanError(t *testing.T) {
 
 	defer db.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating"}).
-		AddRow("cat", "Track 1", int64(123), uint(5))
+	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating", "disc_number", "track_number", "version"}).
+		AddRow("cat", "Track 1", int64(123), uint(5), 1, 1, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
@@ -285,9 +357,9 @@ func TestTrackDaoLoadAllScanError(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	result := dao.LoadAll()
-	assert.NotNil(result)
-	assert.Len(result, 0)
+	result, err := dao.LoadAll(context.Background())
+	assert.NotNil(err)
+	assert.Nil(result)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
@@ -300,10 +372,10 @@ func TestTrackDaoLoadAll(t *testing.T) {
 
 	defer db.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating"}).
-		AddRow(int64(457), "Track 1", int64(123), uint(5)).
-		AddRow(int64(456), "Track 2", int64(123), uint(5)).
-		AddRow(int64(458), "Track 3", int64(123), uint(5))
+	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating", "disc_number", "track_number", "version"}).
+		AddRow(int64(457), "Track 1", int64(123), uint(5), 1, 1, 1).
+		AddRow(int64(456), "Track 2", int64(123), uint(5), 1, 2, 1).
+		AddRow(int64(458), "Track 3", int64(123), uint(5), 1, 3, 1)
 	mock.ExpectQuery(`
         SELECT
             \*
@@ -313,9 +385,296 @@ func TestTrackDaoLoadAll(t *testing.T) {
 	dao := mysql.NewTrackDao(db)
 	defer dao.Close()
 
-	result := dao.LoadAll()
+	result, err := dao.LoadAll(context.Background())
+	assert.Nil(err)
 	assert.NotNil(result)
 	assert.Len(result, 3)
 
 	assert.Nil(mock.ExpectationsWereMet())
 }
+
+func TestTrackDaoLoadForAlbums(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"id", "title", "album", "rating", "disc_number", "track_number", "version"}).
+		AddRow(int64(457), "Track 1", int64(1), uint(5), 1, 1, 1).
+		AddRow(int64(456), "Track 2", int64(2), uint(5), 1, 1, 1).
+		AddRow(int64(458), "Track 3", int64(1), uint(5), 1, 2, 1)
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM track
+        WHERE album IN \(\?, \?, \?\)
+        ORDER BY
+            album ASC,
+            disc_number ASC,
+            track_number ASC,
+            id ASC
+    `).
+		WithArgs(1, 2, 3).
+		WillReturnRows(mockRows)
+
+	dao := mysql.NewTrackDao(db)
+	defer dao.Close()
+
+	result, err := dao.LoadForAlbums(context.Background(), []int64{1, 2, 3})
+	assert.Nil(err)
+	assert.Len(result, 2)
+	assert.Len(result[1], 2)
+	assert.Len(result[2], 1)
+	assert.Len(result[3], 0)
+	assert.Equal("Track 1", result[1][0].Title)
+	assert.Equal("Track 3", result[1][1].Title)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTrackDaoLoadForAlbumsError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM track
+        WHERE album IN \(\?\)
+        ORDER BY
+            album ASC,
+            disc_number ASC,
+            track_number ASC,
+            id ASC
+    `).
+		WithArgs(1).
+		WillReturnError(errors.New("Something bad happened"))
+
+	dao := mysql.NewTrackDao(db)
+	defer dao.Close()
+
+	result, err := dao.LoadForAlbums(context.Background(), []int64{1})
+	assert.NotNil(err)
+	assert.Nil(result)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTrackDaoReorder(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	positions := []model.TrackPosition{
+		{Id: 457, DiscNumber: 1, TrackNumber: 2},
+		{Id: 456, DiscNumber: 1, TrackNumber: 1},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`
+        SELECT
+            id
+        FROM track
+        WHERE album = \?
+        FOR UPDATE
+    `).
+		WithArgs(123).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(456).AddRow(457))
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            track_number = -track_number
+        WHERE album = \?
+    `).
+		WithArgs(123).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	for _, position := range positions {
+		mock.ExpectExec(`
+        UPDATE track
+        SET
+            disc_number = \?,
+            track_number = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND album = \?
+    `).
+			WithArgs(position.DiscNumber, position.TrackNumber, position.Id, 123).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	dao := mysql.NewTrackDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.Reorder(context.Background(), int64(123), positions))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTrackDaoReorderInTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	// A DAO built on a transaction joins it rather than beginning its own.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`
+        SELECT
+            id
+        FROM track
+        WHERE album = \?
+        FOR UPDATE
+    `).
+		WithArgs(123).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(456))
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            track_number = -track_number
+        WHERE album = \?
+    `).
+		WithArgs(123).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            disc_number = \?,
+            track_number = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND album = \?
+    `).
+		WithArgs(1, 1, 456, 123).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	tx, err := db.Begin()
+	assert.Nil(err)
+
+	dao := mysql.NewTrackDao(tx)
+	defer dao.Close()
+
+	assert.Nil(dao.Reorder(context.Background(), int64(123), []model.TrackPosition{
+		{Id: 456, DiscNumber: 1, TrackNumber: 1},
+	}))
+	assert.Nil(tx.Rollback())
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTrackDaoReorderChangedTracks(t *testing.T) {
+	assert := assert.New(t)
+
+	positions := []model.TrackPosition{
+		{Id: 457, DiscNumber: 1, TrackNumber: 2},
+		{Id: 456, DiscNumber: 1, TrackNumber: 1},
+	}
+
+	// A track added to (or removed from) the album since the order was
+	// checked leaves the tracks untouched.
+	for _, onAlbum := range [][]int64{{456, 457, 458}, {456}, {456, 458}} {
+		db, mock, err := sqlmock.New()
+		assert.Nil(err)
+
+		rows := sqlmock.NewRows([]string{"id"})
+		for _, id := range onAlbum {
+			rows.AddRow(id)
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`
+        SELECT
+            id
+        FROM track
+        WHERE album = \?
+        FOR UPDATE
+    `).
+			WithArgs(123).
+			WillReturnRows(rows)
+		mock.ExpectRollback()
+
+		dao := mysql.NewTrackDao(db)
+
+		err = dao.Reorder(context.Background(), int64(123), positions)
+		assert.True(errors.Is(err, daopkg.ErrConflict), onAlbum)
+
+		assert.Nil(mock.ExpectationsWereMet())
+		dao.Close()
+		db.Close()
+	}
+}
+
+func TestTrackDaoReorderError(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	positions := []model.TrackPosition{
+		{Id: 457, DiscNumber: 1, TrackNumber: 2},
+		{Id: 456, DiscNumber: 1, TrackNumber: 1},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`
+        SELECT
+            id
+        FROM track
+        WHERE album = \?
+        FOR UPDATE
+    `).
+		WithArgs(123).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(456).AddRow(457))
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            track_number = -track_number
+        WHERE album = \?
+    `).
+		WithArgs(123).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            disc_number = \?,
+            track_number = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND album = \?
+    `).
+		WithArgs(1, 2, 457, 123).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`
+        UPDATE track
+        SET
+            disc_number = \?,
+            track_number = \?,
+            version = version \+ 1
+        WHERE id = \?
+            AND album = \?
+    `).
+		WithArgs(1, 1, 456, 123).
+		WillReturnError(errors.New("Deadlock found"))
+	mock.ExpectRollback()
+
+	dao := mysql.NewTrackDao(db)
+	defer dao.Close()
+
+	assert.NotNil(dao.Reorder(context.Background(), int64(123), positions))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
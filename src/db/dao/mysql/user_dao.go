@@ -0,0 +1,205 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type userDao struct {
+	db *sql.DB
+}
+
+func NewUserDao(db *sql.DB) dao.UserDao {
+	return userDao{
+		db: db,
+	}
+}
+
+func (this userDao) Close() {
+	logrus.Debug("Closing User DAO")
+}
+
+func scanUser(row rowScanner) (model.User, error) {
+	var user model.User
+	var oidcSubject sql.NullString
+
+	err := row.Scan(&user.Id, &user.Username, &user.PasswordHash, &user.Role, &oidcSubject, &user.CreatedAt)
+	user.OidcSubject = oidcSubject.String
+
+	return user, err
+}
+
+/*
+Users without a subject store NULL, so that the column's uniqueness only
+applies to users that have one.
+*/
+func oidcSubjectArg(user model.User) sql.NullString {
+	return sql.NullString{
+		String: user.OidcSubject,
+		Valid:  user.OidcSubject != "",
+	}
+}
+
+func (this userDao) LoadAll() []model.User {
+	rows, err := this.db.Query(`
+        SELECT
+            *
+        FROM user
+        ORDER BY
+            username ASC
+    `)
+
+	if err != nil {
+		logrus.Warn("Unable to load users ", err.Error())
+		return nil
+	}
+	defer rows.Close()
+
+	var ret []model.User = make([]model.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+
+		if err != nil {
+			logrus.Warn(err.Error())
+		} else {
+			ret = append(ret, user)
+		}
+	}
+
+	return ret
+}
+
+func (this userDao) Load(id int64) *model.User {
+	row := this.db.QueryRow(`
+        SELECT
+            *
+        FROM user
+        WHERE id = ?
+    `, id)
+
+	user, err := scanUser(row)
+	if err != nil {
+		logrus.Warn("Loading failed for ", id, " ", err.Error())
+		return nil
+	}
+
+	return &user
+}
+
+func (this userDao) LoadByUsername(username string) *model.User {
+	row := this.db.QueryRow(`
+        SELECT
+            *
+        FROM user
+        WHERE username = ?
+    `, username)
+
+	user, err := scanUser(row)
+	if err != nil {
+		logrus.Warn("Loading failed for ", username, " ", err.Error())
+		return nil
+	}
+
+	return &user
+}
+
+func (this userDao) LoadByOidcSubject(subject string) *model.User {
+	row := this.db.QueryRow(`
+        SELECT
+            *
+        FROM user
+        WHERE oidc_subject = ?
+    `, subject)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Warn("Loading failed for ", subject, " ", err.Error())
+		}
+		return nil
+	}
+
+	return &user
+}
+
+func (this userDao) CountByRole(role model.Role) (int64, error) {
+	var count int64
+	err := this.db.QueryRow(`
+        SELECT
+            COUNT(*)
+        FROM user
+        WHERE role = ?
+    `, role).Scan(&count)
+
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return count, nil
+}
+
+func (this userDao) Save(user model.User) (int64, error) {
+	if user.Id != 0 {
+		_, err := this.db.Exec(`
+        UPDATE user
+        SET
+            username = ?,
+            password_hash = ?,
+            role = ?,
+            oidc_subject = ?
+        WHERE id = ?
+    `,
+			user.Username,
+			user.PasswordHash,
+			user.Role,
+			oidcSubjectArg(user),
+			user.Id,
+		)
+
+		if err != nil {
+			return 0, translateError(err)
+		}
+		return user.Id, nil
+	}
+
+	result, err := this.db.Exec(`
+        INSERT INTO user(
+            username,
+            password_hash,
+            role,
+            oidc_subject
+        )
+        VALUES(
+            ?,
+            ?,
+            ?,
+            ?
+        )
+    `,
+		user.Username,
+		user.PasswordHash,
+		user.Role,
+		oidcSubjectArg(user),
+	)
+
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+func (this userDao) Delete(user model.User) (int64, error) {
+	result, err := this.db.Exec(`
+        DELETE
+        FROM user
+        WHERE id = ?
+    `, user.Id)
+
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
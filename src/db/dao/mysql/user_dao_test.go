@@ -0,0 +1,296 @@
+package mysql_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	daopkg "citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/dao/mysql"
+	"citadel_intranet/src/db/model"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserDao(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	user := model.User{
+		Username:     "James",
+		PasswordHash: "hash",
+		Role:         model.RoleEditor,
+	}
+
+	mock.ExpectExec(`
+        INSERT INTO user\(
+            username,
+            password_hash,
+            role,
+            oidc_subject
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?,
+            \?
+        \)
+    `).
+		WithArgs(user.Username, user.PasswordHash, user.Role, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(`
+        UPDATE user
+        SET
+            username = \?,
+            password_hash = \?,
+            role = \?,
+            oidc_subject = \?
+        WHERE id = \?
+    `).
+		WithArgs(user.Username, "new hash", user.Role, nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM user
+        WHERE id = \?
+    `).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "oidc_subject", "created_at"}).
+			AddRow(int64(1), "James", "new hash", "editor", nil, createdAt))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM user
+        WHERE username = \?
+    `).
+		WithArgs("James").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "oidc_subject", "created_at"}).
+			AddRow(int64(1), "James", "new hash", "editor", nil, createdAt))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM user
+        ORDER BY
+            username ASC
+    `).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "oidc_subject", "created_at"}).
+			AddRow(int64(2), "Bobby", "hash", "viewer", nil, createdAt).
+			AddRow(int64(1), "James", "new hash", "editor", nil, createdAt))
+
+	mock.ExpectExec(`
+        DELETE
+        FROM user
+        WHERE id = \?
+    `).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dao := mysql.NewUserDao(db)
+	defer dao.Close()
+
+	id, err := dao.Save(user)
+	assert.Nil(err)
+	assert.Equal(int64(1), id)
+
+	user.Id = id
+	user.PasswordHash = "new hash"
+	id, err = dao.Save(user)
+	assert.Nil(err)
+	assert.Equal(int64(1), id)
+
+	user.CreatedAt = createdAt
+	assert.Equal(&user, dao.Load(1))
+	assert.Equal(&user, dao.LoadByUsername("James"))
+
+	users := dao.LoadAll()
+	assert.Len(users, 2)
+	assert.Equal("Bobby", users[0].Username)
+
+	rows, err := dao.Delete(user)
+	assert.Nil(err)
+	assert.Equal(int64(1), rows)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestUserDaoLoadMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM user
+        WHERE username = \?
+    `).
+		WithArgs("Nobody").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "oidc_subject", "created_at"}))
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM user
+        ORDER BY
+            username ASC
+    `).
+		WillReturnError(errors.New("Something bad happened"))
+
+	dao := mysql.NewUserDao(db)
+	defer dao.Close()
+
+	assert.Nil(dao.LoadByUsername("Nobody"))
+	assert.Nil(dao.LoadAll())
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestUserDaoSaveDuplicate(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectExec(`
+        INSERT INTO user\(
+            username,
+            password_hash,
+            role,
+            oidc_subject
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?,
+            \?
+        \)
+    `).
+		WithArgs("James", "hash", model.RoleViewer, nil).
+		WillReturnError(&driver.MySQLError{Number: 1062, Message: "Duplicate entry 'James' for key 'username'"})
+
+	dao := mysql.NewUserDao(db)
+	defer dao.Close()
+
+	id, err := dao.Save(model.User{Username: "James", PasswordHash: "hash", Role: model.RoleViewer})
+	assert.Equal(int64(0), id)
+	assert.True(errors.Is(err, daopkg.ErrDuplicate))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestUserDaoLoadByOidcSubject(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+
+	mock.ExpectExec(`
+        INSERT INTO user\(
+            username,
+            password_hash,
+            role,
+            oidc_subject
+        \)
+        VALUES\(
+            \?,
+            \?,
+            \?,
+            \?
+        \)
+    `).
+		WithArgs("Bobby", "", model.RoleContributor, "subject-1").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM user
+        WHERE oidc_subject = \?
+    `).
+		WithArgs("subject-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "oidc_subject", "created_at"}).
+			AddRow(int64(2), "Bobby", "", "contributor", "subject-1", createdAt))
+
+	mock.ExpectQuery(`
+        SELECT
+            \*
+        FROM user
+        WHERE oidc_subject = \?
+    `).
+		WithArgs("subject-2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "oidc_subject", "created_at"}))
+
+	dao := mysql.NewUserDao(db)
+	defer dao.Close()
+
+	user := model.User{Username: "Bobby", Role: model.RoleContributor, OidcSubject: "subject-1"}
+	id, err := dao.Save(user)
+	assert.Nil(err)
+	assert.Equal(int64(2), id)
+
+	user.Id = id
+	user.CreatedAt = createdAt
+	assert.Equal(&user, dao.LoadByOidcSubject("subject-1"))
+	assert.Nil(dao.LoadByOidcSubject("subject-2"))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestUserDaoCountByRole(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock, err := sqlmock.New()
+	assert.Nil(err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(`
+        SELECT
+            COUNT\(\*\)
+        FROM user
+        WHERE role = \?
+    `).
+		WithArgs(model.RoleAdmin).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`
+        SELECT
+            COUNT\(\*\)
+        FROM user
+        WHERE role = \?
+    `).
+		WithArgs(model.RoleAdmin).
+		WillReturnError(errors.New("Something bad happened"))
+
+	dao := mysql.NewUserDao(db)
+	defer dao.Close()
+
+	count, err := dao.CountByRole(model.RoleAdmin)
+	assert.Nil(err)
+	assert.Equal(int64(2), count)
+
+	count, err = dao.CountByRole(model.RoleAdmin)
+	assert.NotNil(err)
+	assert.Equal(int64(0), count)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
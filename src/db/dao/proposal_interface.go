@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+
+	"citadel_intranet/src/db/model"
+)
+
+type ProposalDao interface {
+	BaseDao
+
+	/*
+	   Load a title proposal, along with its score, from its id
+
+	   Returns the proposal and an error, which wraps ErrNotFound if no
+	   proposal is found
+	*/
+	Load(context.Context, int64) (*model.TitleProposal, error)
+
+	/*
+	   Load every title proposal for an album or track, highest score first.
+	*/
+	LoadFor(context.Context, model.Target, int64) ([]model.TitleProposal, error)
+
+	/*
+	   Save a title proposal via upsert. Only the title and status of an
+	   existing proposal are updated.
+
+	   Returns the last inserted id and an error
+	*/
+	Save(context.Context, model.TitleProposal) (int64, error)
+
+	/*
+	   Record a vote on a proposal, replacing any earlier vote by the same
+	   voter.
+
+	   Returns an error
+	*/
+	Vote(context.Context, model.ProposalVote) error
+
+	/*
+	   Accept a proposal, renaming its album or track to the proposed title
+	   and rejecting every other open proposal for the same album or track.
+	   This all happens within a single transaction.
+
+	   Returns an error, which wraps ErrConflict if the proposal was no longer
+	   open
+	*/
+	Accept(context.Context, int64) error
+
+	/*
+	   Delete a proposal based on its id
+
+	   Returns rows affected and an error
+	*/
+	Delete(context.Context, model.TitleProposal) (int64, error)
+}
@@ -0,0 +1,30 @@
+package dao
+
+import (
+	"citadel_intranet/src/db/model"
+)
+
+type SessionDao interface {
+	BaseDao
+
+	/*
+	   Load a session from its id, expired sessions included
+
+	   Returns nil if no session is found
+	*/
+	Load(string) *model.Session
+
+	/*
+	   Save a new session
+
+	   Returns an error
+	*/
+	Save(model.Session) error
+
+	/*
+	   Delete a session based on its id
+
+	   Returns rows affected and an error
+	*/
+	Delete(string) (int64, error)
+}
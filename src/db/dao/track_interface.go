@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"context"
+
 	"citadel_intranet/src/db/model"
 )
 
@@ -10,31 +12,56 @@ type TrackDao interface {
 	/*
 	   Load all tracks
 	*/
-	LoadAll() []model.Track
+	LoadAll(context.Context) ([]model.Track, error)
 
 	/*
 	   Load an track from their id
 
-	   Returns nil if no track is found
+	   Returns the track and an error, which wraps ErrNotFound if no track is
+	   found
 	*/
-	Load(int64) *model.Track
+	Load(context.Context, int64) (*model.Track, error)
 
 	/*
-	   Load all tracks associated with an album id.
+	   Load all tracks associated with an album id, ordered by disc and track
+	   number.
+	*/
+	LoadForAlbum(context.Context, int64) ([]model.Track, error)
+
+	/*
+	   Load all tracks for each of the given album ids, in a single round trip.
+	   Tracks are ordered by disc and track number within each album.
+
+	   Returns the tracks keyed by album id, albums without any tracks are
+	   left out of the map.
+	*/
+	LoadForAlbums(context.Context, []int64) (map[int64][]model.Track, error)
+
+	/*
+	   Save an track via upsert. Every save bumps the track's version, and
+	   when an existing track is saved with a version it is only updated if
+	   that is still its current version.
+
+	   Returns the last inserted id and an error, which wraps ErrConflict if
+	   the track has changed since (or no longer exists), or ErrDuplicate if
+	   another track on the album is already at its position
 	*/
-	LoadForAlbum(int64) []model.Track
+	Save(context.Context, model.Track) (int64, error)
 
 	/*
-	   Save an track via upsert.
+	   Move tracks on an album to new positions. Every position is written
+	   within a single transaction, either all of them are applied or none
+	   are. Each moved track's version is bumped.
 
-	   Returns the last inserted id and an error
+	   Returns an error, which wraps ErrConflict if the album's tracks are no
+	   longer exactly the ones being moved
 	*/
-	Save(model.Track) (int64, error)
+	Reorder(context.Context, int64, []model.TrackPosition) error
 
 	/*
 	   Delete an track based on its id
 
 	   Returns rows affected and an error
 	*/
-	Delete(model.Track) (int64, error)
+	Delete(context.Context, model.Track) (int64, error)
 }
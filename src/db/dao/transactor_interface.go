@@ -0,0 +1,28 @@
+package dao
+
+import (
+	"context"
+)
+
+/*
+The DAOs handed to a unit of work, every one of them bound to the same
+transaction.
+*/
+type UnitOfWork struct {
+	Artist ArtistDao
+	Album  AlbumDao
+	Track  TrackDao
+}
+
+type Transactor interface {
+	/*
+	   Run the given work within a single transaction, so that everything it
+	   saves through the unit of work is kept or thrown away together. The
+	   transaction is committed when the work returns nil and rolled back
+	   otherwise.
+
+	   Returns the error from the work, or from beginning or committing the
+	   transaction
+	*/
+	InTransaction(context.Context, func(UnitOfWork) error) error
+}
@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"citadel_intranet/src/db/model"
+)
+
+type UserDao interface {
+	BaseDao
+
+	/*
+	   Load all users
+	*/
+	LoadAll() []model.User
+
+	/*
+	   Load a user from their id
+
+	   Returns nil if no user is found
+	*/
+	Load(int64) *model.User
+
+	/*
+	   Load a user from their username
+
+	   Returns nil if no user is found
+	*/
+	LoadByUsername(string) *model.User
+
+	/*
+	   Load a user from the subject their single sign-on provider knows them
+	   by
+
+	   Returns nil if no user is found
+	*/
+	LoadByOidcSubject(string) *model.User
+
+	/*
+	   Count the users that have the given role
+
+	   Returns the count and an error
+	*/
+	CountByRole(model.Role) (int64, error)
+
+	/*
+	   Save a user, inserting them if they don't have an id yet and updating
+	   them otherwise. Unlike the other DAOs this is never an upsert, a new
+	   user must not be able to take over an existing account by reusing
+	   its username.
+
+	   Returns the user's id and an error, which wraps ErrDuplicate if the
+	   username is already taken
+	*/
+	Save(model.User) (int64, error)
+
+	/*
+	   Delete a user based on their id, along with all of their sessions
+
+	   Returns rows affected and an error
+	*/
+	Delete(model.User) (int64, error)
+}
@@ -3,6 +3,7 @@
 package db_test
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -15,6 +16,7 @@ import (
 
 func TestDaoCalls(t *testing.T) {
 	assert := assert.New(t)
+	ctx := context.Background()
 
 	wd, err := os.Getwd()
 	assert.Nil(err)
@@ -29,62 +31,72 @@ func TestDaoCalls(t *testing.T) {
 		MigrationsPath: wd + "/../../migrations/",
 	}
 
-	db := db.NewDatabaseClient(cfg)
-	assert.NotNil(db)
-	db.Migrate(cfg.MigrationsPath)
-	defer db.Close()
+	client := db.NewDatabaseClient(cfg)
+	assert.NotNil(client)
+	db.Migrate(client.Db, cfg.MigrationsPath)
+	defer client.Close()
 
 	artist := model.Artist{
 		Name: "James",
 	}
 
-	artistId, err := db.Artist.Save(artist)
+	artistId, err := client.Artist.Save(ctx, artist)
 	assert.Nil(err)
 	artist.Id = artistId
 
-	artists := db.Artist.LoadAll()
+	artists, err := client.Artist.LoadAll(ctx)
+	assert.Nil(err)
 	assert.Len(artists, 1)
 	assert.Equal(artist, artists[0])
 
 	album := model.Album{
-		Title:     "Something Awesome",
-		Artist:    artist,
-		Tracks:    []model.Track{},
-		Published: false,
-		Rating:    0,
+		Title:  "Something Awesome",
+		Artist: artist,
+		Tracks: []model.Track{},
+		State:  model.AlbumDraft,
+		Rating: 0,
 	}
 
-	album.Id, err = db.Album.Save(album)
+	album.Id, err = client.Album.Save(ctx, album)
 	assert.Nil(err)
+	album.Version = 1
 
-	albums := db.Album.LoadAll()
+	albums, err := client.Album.LoadAll(ctx)
+	assert.Nil(err)
 	assert.Len(albums, 1)
 	assert.Equal(album, albums[0])
 
 	track := model.Track{
-		Title:   "Track 1",
-		AlbumId: album.Id,
-		Rating:  0,
+		Title:       "Track 1",
+		AlbumId:     album.Id,
+		Rating:      0,
+		DiscNumber:  1,
+		TrackNumber: 1,
 	}
 
-	track.Id, err = db.Track.Save(track)
+	track.Id, err = client.Track.Save(ctx, track)
 	assert.Nil(err)
+	track.Version = 1
 
-	tracks := db.Track.LoadAll()
+	tracks, err := client.Track.LoadAll(ctx)
+	assert.Nil(err)
 	assert.Len(tracks, 1)
 	assert.Equal(track, tracks[0])
 
-	tracksForAlbum := db.Track.LoadForAlbum(album.Id)
+	tracksForAlbum, err := client.Track.LoadForAlbum(ctx, album.Id)
+	assert.Nil(err)
 	assert.Len(tracksForAlbum, 1)
 	assert.Equal(track, tracksForAlbum[0])
 
-	rows, err := db.Album.Delete(album)
+	rows, err := client.Album.Delete(ctx, album)
 	assert.Nil(err)
 	assert.Equal(int64(1), rows)
 
-	retrievedArtist := db.Artist.Load(artist.Id)
+	retrievedArtist, err := client.Artist.Load(ctx, artist.Id)
+	assert.Nil(err)
 	assert.Equal(artist, *retrievedArtist)
 
-	tracksForAlbum = db.Track.LoadForAlbum(album.Id)
+	tracksForAlbum, err = client.Track.LoadForAlbum(ctx, album.Id)
+	assert.Nil(err)
 	assert.Len(tracksForAlbum, 0)
 }
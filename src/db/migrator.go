@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha1"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -62,6 +63,57 @@ func Migrate(db *sql.DB, migrationsPath string) {
 	}
 }
 
+/*
+Check that every migration in migrationsPath has been applied, which also
+fails while they're still being run.
+*/
+func CheckMigrations(ctx context.Context, db *sql.DB, migrationsPath string) error {
+	items, err := os.ReadDir(migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+        SELECT
+            name
+        FROM migrations
+    `)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return err
+		}
+		applied[name] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	pending := make([]string, 0)
+	for _, item := range items {
+		// Skip dotfiles
+		if item.Name()[0] == '.' {
+			continue
+		}
+
+		if !applied[item.Name()] {
+			pending = append(pending, item.Name())
+		}
+	}
+
+	if len(pending) > 0 {
+		return errors.New("Migrations not applied yet: " + strings.Join(pending, ", "))
+	}
+	return nil
+}
+
 func ensureMigrationsTableExists(db *sql.DB) {
 	_, err := db.Exec(`
         CREATE TABLE IF NOT EXISTS migrations(
@@ -29,10 +29,10 @@ func TestRunMigrations(t *testing.T) {
 			MigrationsPath: wd + "/../../migrations/",
 		}
 
-		db := db.NewDatabaseClient(cfg)
-		assert.NotNil(db)
-		defer db.Close()
-		db.Migrate(cfg.MigrationsPath)
+		client := db.NewDatabaseClient(cfg)
+		assert.NotNil(client)
+		defer client.Close()
+		db.Migrate(client.Db, cfg.MigrationsPath)
 	}
 
 	callback()
@@ -64,7 +64,7 @@ func TestBadConnection(t *testing.T) {
 
 		// This should be a fatal error due to not being able to connect
 		client := db.NewDatabaseClient(cfg)
-		client.Migrate(cfg.MigrationsPath)
+		db.Migrate(client.Db, cfg.MigrationsPath)
 		defer client.Close()
 		assert.False(true, "The code didn't hit a fatal error")
 	})()
@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"citadel_intranet/src/db"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMigrations(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "migrations")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{".gitkeep", "001.sql", "002.sql", "003.sql"} {
+		assert.Nil(ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0600))
+	}
+
+	conn, mock, err := sqlmock.New()
+	assert.Nil(err)
+	defer conn.Close()
+
+	query := `
+        SELECT
+            name
+        FROM migrations
+    `
+	mock.ExpectQuery(query).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("001.sql"))
+	mock.ExpectQuery(query).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("001.sql").AddRow("002.sql").AddRow("003.sql"))
+	mock.ExpectQuery(query).
+		WillReturnError(errors.New("Table 'migrations' doesn't exist"))
+
+	err = db.CheckMigrations(context.Background(), conn, dir)
+	assert.Equal("Migrations not applied yet: 002.sql, 003.sql", err.Error())
+
+	assert.Nil(db.CheckMigrations(context.Background(), conn, dir))
+	assert.NotNil(db.CheckMigrations(context.Background(), conn, dir))
+	assert.NotNil(db.CheckMigrations(context.Background(), conn, filepath.Join(dir, "missing")))
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
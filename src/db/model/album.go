@@ -1,10 +1,93 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
+/*
+Where an album is in the release workflow.
+*/
+type AlbumState string
+
+const (
+	AlbumDraft     AlbumState = "draft"
+	AlbumInReview  AlbumState = "in_review"
+	AlbumApproved  AlbumState = "approved"
+	AlbumScheduled AlbumState = "scheduled"
+	AlbumPublished AlbumState = "published"
+	AlbumArchived  AlbumState = "archived"
+)
+
+/*
+Every album state, in workflow order.
+*/
+var AlbumStates = []AlbumState{
+	AlbumDraft,
+	AlbumInReview,
+	AlbumApproved,
+	AlbumScheduled,
+	AlbumPublished,
+	AlbumArchived,
+}
+
 type Album struct {
-	Id        int64   `json:"id"`
-	Title     string  `json:"title"`
-	Artist    Artist  `json:"artist"`
-	Tracks    []Track `json:"tracks"`
-	Published bool    `json:"published"`
-	Rating    uint    `json:"rating"`
+	Id     int64      `json:"id"`
+	Title  string     `json:"title"`
+	Artist Artist     `json:"artist"`
+	Tracks []Track    `json:"tracks"`
+	State  AlbumState `json:"state"`
+	Rating uint       `json:"rating"`
+
+	// Bumped on every change to the album, used to turn away edits based on
+	// an out of date copy.
+	Version int64 `json:"version"`
+
+	// Number of comments left on the album, not counting deleted ones.
+	CommentCount int64 `json:"commentCount"`
+}
+
+func (this Album) IsPublished() bool {
+	return this.State == AlbumPublished
+}
+
+/*
+Albums used to only carry a published flag, it is still included (derived from
+the state) so that older clients keep working.
+*/
+func (this Album) MarshalJSON() ([]byte, error) {
+	type album Album
+	return json.Marshal(struct {
+		album
+		Published bool `json:"published"`
+	}{
+		album:     album(this),
+		Published: this.IsPublished(),
+	})
+}
+
+/*
+A record of an album moving from one state to another.
+*/
+type AlbumStateChange struct {
+	Id        int64      `json:"id"`
+	AlbumId   int64      `json:"album"`
+	From      AlbumState `json:"from"`
+	To        AlbumState `json:"to"`
+	ChangedBy string     `json:"changedBy"`
+	ChangedAt time.Time  `json:"changedAt"`
+}
+
+/*
+A single page of albums, along with enough information to retrieve the next
+one.
+*/
+type AlbumPage struct {
+	Albums []Album `json:"albums"`
+	Total  int64   `json:"total"`
+	Limit  uint    `json:"limit"`
+	Offset uint    `json:"offset"`
+
+	// Offset of the next page, nil when this is the last page.
+	Next *uint `json:"next"`
 }
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+)
+
+/*
+A long lived credential for scripts, used in place of logging in. Scopes name
+the permissions the token may use, on top of whatever its user's role allows.
+*/
+type ApiToken struct {
+	Id         int64      `json:"id"`
+	UserId     int64      `json:"user"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+func (this ApiToken) IsExpired(now time.Time) bool {
+	return !now.Before(this.ExpiresAt)
+}
+
+func (this ApiToken) HasScope(scope string) bool {
+	for _, candidate := range this.Scopes {
+		if candidate == scope {
+			return true
+		}
+	}
+
+	return false
+}
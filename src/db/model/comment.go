@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+/*
+A note left on an album or track. Bodies are markdown, and are stored and
+returned as written, leaving rendering to the client.
+
+Comments are threaded, ParentId is the comment being replied to, or nil for a
+top level comment. Deleted comments are kept (with their body removed) so that
+replies to them still have somewhere to hang.
+*/
+type Comment struct {
+	Id        int64      `json:"id"`
+	Target    Target     `json:"target"`
+	TargetId  int64      `json:"targetId"`
+	ParentId  *int64     `json:"parent"`
+	Author    string     `json:"author"`
+	Body      string     `json:"body"`
+	Resolved  bool       `json:"resolved"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt"`
+	DeletedAt *time.Time `json:"deletedAt"`
+	Replies   []Comment  `json:"replies"`
+}
+
+func (this Comment) IsDeleted() bool {
+	return this.DeletedAt != nil
+}
@@ -1,7 +1,9 @@
 package model_test
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -19,3 +21,40 @@ func TestModelInstantiation(t *testing.T) {
 	artist := &model.Artist{}
 	assert.NotNil(artist)
 }
+
+func TestAlbumJsonIncludesPublished(t *testing.T) {
+	assert := assert.New(t)
+
+	for state, published := range map[model.AlbumState]bool{
+		model.AlbumDraft:     false,
+		model.AlbumScheduled: false,
+		model.AlbumPublished: true,
+		model.AlbumArchived:  false,
+	} {
+		album := model.Album{Id: 1, State: state}
+		assert.Equal(published, album.IsPublished())
+
+		body, err := json.Marshal(album)
+		assert.Nil(err)
+
+		var decoded map[string]interface{}
+		assert.Nil(json.Unmarshal(body, &decoded))
+		assert.Equal(published, decoded["published"], state)
+		assert.Equal(string(state), decoded["state"])
+	}
+}
+
+func TestApiTokenScopesAndExpiry(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2026, time.October, 18, 9, 30, 0, 0, time.UTC)
+	token := model.ApiToken{
+		Scopes:    []string{"tracks:create"},
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	assert.True(token.HasScope("tracks:create"))
+	assert.False(token.HasScope("tracks:remove"))
+	assert.False(token.IsExpired(now))
+	assert.True(token.IsExpired(now.Add(time.Hour)))
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+type ProposalStatus string
+
+const (
+	ProposalOpen     ProposalStatus = "open"
+	ProposalAccepted ProposalStatus = "accepted"
+	ProposalRejected ProposalStatus = "rejected"
+)
+
+/*
+An alternative title suggested for an album or track. Score is the sum of
+every vote cast on the proposal.
+*/
+type TitleProposal struct {
+	Id         int64          `json:"id"`
+	Target     Target         `json:"target"`
+	TargetId   int64          `json:"targetId"`
+	Title      string         `json:"title"`
+	ProposedBy string         `json:"proposedBy"`
+	Status     ProposalStatus `json:"status"`
+	Score      int64          `json:"score"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+/*
+A single teammate's vote on a proposal, either 1 (up) or -1 (down).
+*/
+type ProposalVote struct {
+	ProposalId int64  `json:"proposal"`
+	Voter      string `json:"voter"`
+	Vote       int    `json:"vote"`
+}
@@ -0,0 +1,11 @@
+package model
+
+/*
+The kind of thing that proposals and comments can be attached to.
+*/
+type Target string
+
+const (
+	TargetAlbum Target = "album"
+	TargetTrack Target = "track"
+)
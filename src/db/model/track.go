@@ -1,8 +1,23 @@
 package model
 
 type Track struct {
-	Id      int64  `json:"id"`
-	Title   string `json:"title"`
-	AlbumId int64  `json:"album"`
-	Rating  uint   `json:"rating"`
+	Id          int64  `json:"id"`
+	Title       string `json:"title"`
+	AlbumId     int64  `json:"album"`
+	Rating      uint   `json:"rating"`
+	DiscNumber  uint   `json:"disc"`
+	TrackNumber uint   `json:"number"`
+
+	// Bumped on every change to the track, used to turn away edits based on
+	// an out of date copy.
+	Version int64 `json:"version"`
+}
+
+/*
+Where a track sits within its album, used when reordering an album's tracks.
+*/
+type TrackPosition struct {
+	Id          int64 `json:"id"`
+	DiscNumber  uint  `json:"disc"`
+	TrackNumber uint  `json:"number"`
 }
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+)
+
+/*
+What a user is allowed to do, see the application package for the operations
+each role allows.
+*/
+type Role string
+
+const (
+	RoleViewer      Role = "viewer"
+	RoleContributor Role = "contributor"
+	RoleEditor      Role = "editor"
+	RoleAdmin       Role = "admin"
+)
+
+var Roles = []Role{
+	RoleViewer,
+	RoleContributor,
+	RoleEditor,
+	RoleAdmin,
+}
+
+type User struct {
+	Id           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role"`
+	OidcSubject  string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+/*
+A logged in user. The id is a hash of the token handed to the browser, the
+token itself is never stored.
+*/
+type Session struct {
+	Id        string    `json:"-"`
+	UserId    int64     `json:"user"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (this Session) IsExpired(now time.Time) bool {
+	return !now.Before(this.ExpiresAt)
+}
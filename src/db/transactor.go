@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/dao/instrumented"
+	"citadel_intranet/src/db/dao/mysql"
+)
+
+type transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) dao.Transactor {
+	return transactor{
+		db: db,
+	}
+}
+
+func (this transactor) InTransaction(ctx context.Context, work func(dao.UnitOfWork) error) error {
+	return mysql.InTransaction(ctx, this.db, func(tx *sql.Tx) error {
+		return work(newUnitOfWork(tx))
+	})
+}
+
+/*
+Build the artist, album and track DAOs on top of executor, which is either the
+database itself or a transaction on it.
+*/
+func newUnitOfWork(executor mysql.Executor) dao.UnitOfWork {
+	artist := instrumented.NewArtistDao(mysql.NewArtistDao(executor))
+	track := instrumented.NewTrackDao(mysql.NewTrackDao(executor))
+
+	return dao.UnitOfWork{
+		Artist: artist,
+		Album:  instrumented.NewAlbumDao(mysql.NewAlbumDao(executor, artist, track)),
+		Track:  track,
+	}
+}
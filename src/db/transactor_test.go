@@ -0,0 +1,100 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"citadel_intranet/src/db"
+	"citadel_intranet/src/db/dao"
+	"citadel_intranet/src/db/model"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInTransactionCommit(t *testing.T) {
+	assert := assert.New(t)
+
+	conn, mock, err := sqlmock.New()
+	assert.Nil(err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO artist").
+		WithArgs(0, "James").
+		WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec("INSERT INTO album").
+		WithArgs(0, "Waffle Irons", 42, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	client := db.NewDatabaseClientFromConnection(conn)
+	err = client.InTransaction(context.Background(), func(work dao.UnitOfWork) error {
+		artistId, err := work.Artist.Save(context.Background(), model.Artist{Name: "James"})
+		if err != nil {
+			return err
+		}
+
+		_, err = work.Album.Save(context.Background(), model.Album{
+			Title:  "Waffle Irons",
+			Artist: model.Artist{Id: artistId},
+		})
+		return err
+	})
+	assert.Nil(err)
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestInTransactionRollback(t *testing.T) {
+	assert := assert.New(t)
+
+	conn, mock, err := sqlmock.New()
+	assert.Nil(err)
+	defer conn.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO artist").
+		WithArgs(0, "James").
+		WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec("INSERT INTO album").
+		WillReturnError(errors.New("Waffles"))
+	mock.ExpectRollback()
+
+	client := db.NewDatabaseClientFromConnection(conn)
+	err = client.InTransaction(context.Background(), func(work dao.UnitOfWork) error {
+		artistId, err := work.Artist.Save(context.Background(), model.Artist{Name: "James"})
+		if err != nil {
+			return err
+		}
+
+		_, err = work.Album.Save(context.Background(), model.Album{
+			Title:  "Waffle Irons",
+			Artist: model.Artist{Id: artistId},
+		})
+		return err
+	})
+	assert.EqualError(err, "Waffles")
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
+
+func TestInTransactionBeginError(t *testing.T) {
+	assert := assert.New(t)
+
+	conn, mock, err := sqlmock.New()
+	assert.Nil(err)
+	defer conn.Close()
+
+	mock.ExpectBegin().WillReturnError(errors.New("Waffles"))
+
+	client := db.NewDatabaseClientFromConnection(conn)
+	err = client.InTransaction(context.Background(), func(work dao.UnitOfWork) error {
+		assert.Fail("Work should not run without a transaction")
+		return nil
+	})
+	assert.EqualError(err, "Waffles")
+
+	assert.Nil(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const requestContextKey contextKey = iota
+
+/*
+Everything known about the request being handled that belongs on its log
+lines.
+*/
+type request struct {
+	id    string
+	user  string
+	entry *logrus.Entry
+}
+
+/*
+Start logging for a request, every line logged through FromContext with the
+returned context carries its id.
+*/
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestContextKey, &request{
+		id:    id,
+		entry: logrus.WithField("request_id", id),
+	})
+}
+
+func fromContext(ctx context.Context) *request {
+	request, _ := ctx.Value(requestContextKey).(*request)
+	return request
+}
+
+/*
+The logger for the request a context belongs to, or the standard logger when
+it doesn't belong to one.
+*/
+func FromContext(ctx context.Context) *logrus.Entry {
+	if request := fromContext(ctx); request != nil {
+		return request.entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+/*
+Record who made a request once they're known, adding them to every line
+logged for it from then on.
+*/
+func SetUser(ctx context.Context, username string) {
+	if request := fromContext(ctx); request != nil {
+		request.user = username
+		request.entry = request.entry.WithField("user", username)
+	}
+}
+
+/*
+The id of the request a context belongs to, or an empty string
+*/
+func RequestId(ctx context.Context) string {
+	if request := fromContext(ctx); request != nil {
+		return request.id
+	}
+	return ""
+}
+
+/*
+Who made the request a context belongs to, or an empty string if they aren't
+known
+*/
+func User(ctx context.Context) string {
+	if request := fromContext(ctx); request != nil {
+		return request.user
+	}
+	return ""
+}
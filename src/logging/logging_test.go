@@ -0,0 +1,35 @@
+package logging_test
+
+import (
+	"context"
+	"testing"
+
+	"citadel_intranet/src/logging"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogging(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := logging.WithRequestId(context.Background(), "abc123")
+	assert.Equal("abc123", logging.RequestId(ctx))
+	assert.Equal("", logging.User(ctx))
+	assert.Equal(logrus.Fields{"request_id": "abc123"}, logging.FromContext(ctx).Data)
+
+	logging.SetUser(ctx, "James")
+	assert.Equal("James", logging.User(ctx))
+	assert.Equal(logrus.Fields{"request_id": "abc123", "user": "James"}, logging.FromContext(ctx).Data)
+}
+
+func TestNoRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	logging.SetUser(ctx, "James")
+
+	assert.Equal("", logging.RequestId(ctx))
+	assert.Equal("", logging.User(ctx))
+	assert.Equal(logrus.Fields{}, logging.FromContext(ctx).Data)
+}
@@ -1,24 +1,74 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"time"
 
 	"citadel_intranet/src/application"
+	"citadel_intranet/src/auth"
 	"citadel_intranet/src/config"
 	"citadel_intranet/src/db"
+	"citadel_intranet/src/db/model"
+	"citadel_intranet/src/metrics"
 	"citadel_intranet/src/server"
+
+	"github.com/sirupsen/logrus"
 )
 
+const shutdownGracePeriod = 5 * time.Second
+
 func main() {
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		logrus.Fatal("Unable to load configuration ", err.Error())
+	}
+
 	dbClient := db.NewDatabaseClient(cfg)
+	if err = metrics.RegisterDatabase(dbClient.Db, cfg.DbName); err != nil {
+		logrus.Fatal("Unable to expose database metrics ", err.Error())
+	}
+
+	// Start serving straight away so that the orchestrator can see that
+	// we're alive, but not ready until the migrations are done.
+	webServer := server.NewServer(cfg)
+	webServer.AddReadinessCheck("database", dbClient.Ping)
+	webServer.AddReadinessCheck("migrations", func(ctx context.Context) error {
+		return dbClient.CheckMigrations(ctx, cfg.MigrationsPath)
+	})
 
 	db.Migrate(dbClient.Db, cfg.MigrationsPath)
 
-	webServer := server.NewServer(cfg)
-	app := application.NewApp(dbClient, webServer)
+	if cfg.AdminUser != "" {
+		if err := application.EnsureUser(dbClient.User, cfg.AdminUser, cfg.AdminPass, model.RoleAdmin); err != nil {
+			logrus.Fatal("Unable to create admin user ", err.Error())
+		}
+	}
+
+	options := []application.Option{
+		application.WithRatingRange(uint(cfg.RatingMin), uint(cfg.RatingMax)),
+	}
+	app := application.NewApp(dbClient, webServer, options...)
+
+	if cfg.OidcIssuer != "" {
+		client, err := auth.NewOidcClient(context.Background(), cfg)
+		if err != nil {
+			logrus.Fatal("Unable to set up single sign-on ", err.Error())
+		}
+
+		oidc, err := application.NewOidcLogin(client, cfg)
+		if err != nil {
+			logrus.Fatal("Unable to set up single sign-on ", err.Error())
+		}
+
+		app = application.NewAppWithOidc(dbClient, webServer, oidc, options...)
+	}
+
 	defer app.Close()
 	app.Run()
 
@@ -27,5 +77,10 @@ func main() {
 
 	s := <-c
 	fmt.Printf("Received signal, shutting down: %s", s)
+
+	// Give the orchestrator a chance to notice that we're going away before
+	// we stop taking requests.
+	webServer.BeginShutdown()
+	time.Sleep(shutdownGracePeriod)
 	app.Close()
 }
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "citadel"
+
+var (
+	// Everything exposed on /metrics. Kept apart from the default registry
+	// so that nothing we haven't chosen to expose sneaks in.
+	registry = prometheus.NewRegistry()
+
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by route, method, and status code.",
+	}, []string{"route", "method", "status"})
+
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by route, method, and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	DaoCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "dao",
+		Name:      "call_duration_seconds",
+		Help:      "Time taken by DAO calls, by DAO and method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"dao", "method"})
+
+	DaoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dao",
+		Name:      "errors_total",
+		Help:      "DAO calls that returned an error, by DAO and method.",
+	}, []string{"dao", "method"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequests,
+		HttpRequestDuration,
+		DaoCallDuration,
+		DaoErrors,
+	)
+}
+
+/*
+Serves every metric in the Prometheus text format.
+*/
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+/*
+Expose the connection pool statistics of a database.
+
+Returns an error if the database has already been registered under the same
+name
+*/
+func RegisterDatabase(db *sql.DB, name string) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+/*
+Record a request that was handled by a route, with the status code it got.
+*/
+func ObserveRequest(route string, method string, status int, latency time.Duration) {
+	labels := []string{route, method, strconv.Itoa(status)}
+	HttpRequests.WithLabelValues(labels...).Inc()
+	HttpRequestDuration.WithLabelValues(labels...).Observe(latency.Seconds())
+}
+
+/*
+Record a call to a DAO method that started at start, and the error it
+returned, if any.
+*/
+func ObserveDaoCall(dao string, method string, start time.Time, err error) {
+	DaoCallDuration.WithLabelValues(dao, method).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		DaoErrors.WithLabelValues(dao, method).Inc()
+	}
+}
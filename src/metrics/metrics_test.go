@@ -0,0 +1,51 @@
+package metrics_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"citadel_intranet/src/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	metrics.ObserveRequest("/api/v1/album/:id", "GET", 200, time.Millisecond)
+	metrics.ObserveRequest("/api/v1/album/:id", "GET", 200, time.Millisecond)
+	metrics.ObserveRequest("/api/v1/album/:id", "GET", 404, time.Millisecond)
+
+	assert.Equal(float64(2), testutil.ToFloat64(metrics.HttpRequests.WithLabelValues("/api/v1/album/:id", "GET", "200")))
+	assert.Equal(float64(1), testutil.ToFloat64(metrics.HttpRequests.WithLabelValues("/api/v1/album/:id", "GET", "404")))
+
+	server := httptest.NewServer(metrics.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.Nil(err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.Contains(string(body), `citadel_http_requests_total{method="GET",route="/api/v1/album/:id",status="404"} 1`)
+	assert.Contains(string(body), `citadel_http_request_duration_seconds_count{method="GET",route="/api/v1/album/:id",status="200"} 2`)
+	assert.Contains(string(body), "go_goroutines")
+}
+
+func TestObserveDaoCall(t *testing.T) {
+	assert := assert.New(t)
+
+	metrics.ObserveDaoCall("test", "Load", time.Now(), nil)
+	metrics.ObserveDaoCall("test", "Save", time.Now(), nil)
+	metrics.ObserveDaoCall("test", "Save", time.Now(), errors.New("Something bad happened"))
+
+	assert.Equal(float64(0), testutil.ToFloat64(metrics.DaoErrors.WithLabelValues("test", "Load")))
+	assert.Equal(float64(1), testutil.ToFloat64(metrics.DaoErrors.WithLabelValues("test", "Save")))
+	assert.Equal(2, testutil.CollectAndCount(metrics.DaoCallDuration, "citadel_dao_call_duration_seconds"))
+}
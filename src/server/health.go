@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HealthOk           = "ok"
+	HealthFailing      = "failing"
+	HealthShuttingDown = "shutting_down"
+
+	// How long all of the readiness checks together may take
+	ReadinessTimeout = 5 * time.Second
+)
+
+/*
+Something that has to work for the server to be ready to take requests,
+returning an error describing what's wrong if it doesn't.
+*/
+type ReadinessCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+type health struct {
+	lock         sync.RWMutex
+	checks       []namedCheck
+	shuttingDown bool
+}
+
+type checkStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthStatus struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkStatus `json:"checks,omitempty"`
+}
+
+/*
+Add a check that has to pass for /readyz to report the server as ready.
+*/
+func (this Server) AddReadinessCheck(name string, check ReadinessCheck) {
+	this.health.lock.Lock()
+	defer this.health.lock.Unlock()
+
+	this.health.checks = append(this.health.checks, namedCheck{name: name, check: check})
+}
+
+/*
+Report the server as not ready from now on, so that it's taken out of rotation
+before it stops taking requests.
+*/
+func (this Server) BeginShutdown() {
+	this.health.lock.Lock()
+	defer this.health.lock.Unlock()
+
+	this.health.shuttingDown = true
+}
+
+func writeHealth(out http.ResponseWriter, code int, status healthStatus) {
+	out.Header().Set("Content-Type", "application/json; charset=utf-8")
+	out.Header().Set("Cache-Control", "no-store")
+	out.WriteHeader(code)
+
+	if err := json.NewEncoder(out).Encode(status); err != nil {
+		logrus.Warn("Unable to write health status ", err.Error())
+	}
+}
+
+/*
+The process is up and serving requests, nothing more.
+*/
+func (this *health) live(out http.ResponseWriter, req *http.Request) {
+	writeHealth(out, http.StatusOK, healthStatus{Status: HealthOk})
+}
+
+/*
+Whether everything the server needs is working, running every check and
+reporting on each of them.
+*/
+func (this *health) ready(out http.ResponseWriter, req *http.Request) {
+	this.lock.RLock()
+	checks := this.checks
+	shuttingDown := this.shuttingDown
+	this.lock.RUnlock()
+
+	if shuttingDown {
+		writeHealth(out, http.StatusServiceUnavailable, healthStatus{Status: HealthShuttingDown})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), ReadinessTimeout)
+	defer cancel()
+
+	code := http.StatusOK
+	status := healthStatus{
+		Status: HealthOk,
+		Checks: make(map[string]checkStatus),
+	}
+
+	for _, check := range checks {
+		if err := check.check(ctx); err != nil {
+			code = http.StatusServiceUnavailable
+			status.Status = HealthFailing
+			status.Checks[check.name] = checkStatus{Status: HealthFailing, Error: err.Error()}
+		} else {
+			status.Checks[check.name] = checkStatus{Status: HealthOk}
+		}
+	}
+
+	writeHealth(out, code, status)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"citadel_intranet/src/logging"
+	"citadel_intranet/src/metrics"
+
+	"github.com/kataras/muxie"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	RequestIdHeader = "X-Request-ID"
+
+	// Longest request id taken from a client, anything longer is replaced
+	maxRequestIdLength = 128
+
+	// Route for requests that don't match any route, so that scanners
+	// trying random paths can't create a new metric series for each one.
+	unmatchedRoute = "unmatched"
+)
+
+// Polled constantly by the orchestrator and Prometheus, so only logged when
+// debugging.
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+/*
+Remembers the status code and size of a response. The status defaults to 200
+for handlers that never call WriteHeader.
+*/
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (this *responseRecorder) WriteHeader(status int) {
+	this.status = status
+	this.ResponseWriter.WriteHeader(status)
+}
+
+func (this *responseRecorder) Write(data []byte) (int, error) {
+	written, err := this.ResponseWriter.Write(data)
+	this.bytes += written
+	return written, err
+}
+
+// The router wants somewhere to put the parameters it finds when matching,
+// which we don't need.
+type ignoreParams struct{}
+
+func (this ignoreParams) Set(key string, value string) {}
+
+/*
+The pattern of the route a request goes to, e.g. /api/v1/album/:id, rather
+than its path.
+*/
+func routeFor(mux *muxie.Mux, req *http.Request) string {
+	node := mux.Routes.Search(req.URL.Path, ignoreParams{})
+	if node == nil {
+		return unmatchedRoute
+	}
+	return node.String()
+}
+
+/*
+Request ids from clients are kept so that requests can be followed across
+services, as long as they're short and safe to put in a log line.
+*/
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		logrus.Warn("Unable to generate request id ", err.Error())
+	}
+	return hex.EncodeToString(buffer)
+}
+
+/*
+Wrap a router so that every request gets an id, shared by every line logged
+for it and handed back in the X-Request-ID header, then is logged and counted
+once it's been handled.
+*/
+func instrument(mux *muxie.Mux) http.Handler {
+	return http.HandlerFunc(func(out http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		id := req.Header.Get(RequestIdHeader)
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+		out.Header().Set(RequestIdHeader, id)
+
+		ctx := logging.WithRequestId(req.Context(), id)
+		req = req.WithContext(ctx)
+		recorder := &responseRecorder{ResponseWriter: out, status: http.StatusOK}
+
+		mux.ServeHTTP(recorder, req)
+
+		route := routeFor(mux, req)
+		latency := time.Since(start)
+		metrics.ObserveRequest(route, req.Method, recorder.status, latency)
+
+		entry := logging.FromContext(ctx).WithFields(logrus.Fields{
+			"method":     req.Method,
+			"route":      route,
+			"path":       req.URL.Path,
+			"status":     recorder.status,
+			"bytes":      recorder.bytes,
+			"latency_ms": float64(latency.Microseconds()) / 1000,
+			"user":       logging.User(ctx),
+		})
+
+		if quietRoutes[route] {
+			entry.Debug("Handled request")
+		} else {
+			entry.Info("Handled request")
+		}
+	})
+}
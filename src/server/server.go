@@ -3,15 +3,19 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 
 	"citadel_intranet/src/config"
+	"citadel_intranet/src/metrics"
 
 	"github.com/kataras/muxie"
+	"github.com/sirupsen/logrus"
 )
 
 type Server struct {
 	server *http.Server
+	health *health
 	Mux    *muxie.Mux
 }
 
@@ -20,20 +24,34 @@ func NewServer(cfg config.Config) Server {
 
 	mux.Handle("/*file", http.FileServer(http.Dir(cfg.ServerFilePath)))
 
+	health := &health{}
+	mux.Handle("/healthz", muxie.Methods().HandleFunc(http.MethodGet, health.live))
+	mux.Handle("/readyz", muxie.Methods().HandleFunc(http.MethodGet, health.ready))
+	mux.Handle("/metrics", muxie.Methods().Handle(http.MethodGet, metrics.Handler()))
+
 	server := http.Server{
 		Addr:    getAddressString(cfg),
-		Handler: mux,
+		Handler: instrument(mux),
+	}
+
+	// Bind before returning so that callers can issue requests immediately,
+	// serving happens in the background.
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		logrus.Panic("Unable to listen on ", server.Addr, " ", err.Error())
 	}
 
-	go server.ListenAndServe()
+	go server.Serve(listener)
 
 	return Server{
 		server: &server,
+		health: health,
 		Mux:    mux,
 	}
 }
 
 func (this Server) Close() {
+	this.BeginShutdown()
 	this.server.Shutdown(context.Background())
 }
 
@@ -1,15 +1,23 @@
 package server_test
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"citadel_intranet/src/config"
+	"citadel_intranet/src/logging"
+	"citadel_intranet/src/metrics"
 	"citadel_intranet/src/server"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,3 +53,119 @@ func TestServerSetup(t *testing.T) {
 
 	server.Close()
 }
+
+func TestServerHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	server := server.NewServer(config.Config{ServerPort: 8080})
+	defer server.Close()
+
+	failing := errors.New("Database is down")
+	server.AddReadinessCheck("database", func(ctx context.Context) error {
+		return failing
+	})
+	server.AddReadinessCheck("migrations", func(ctx context.Context) error {
+		return nil
+	})
+
+	get := func(url string) (int, string) {
+		resp, err := http.Get(url)
+		assert.Nil(err)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, body := get("http://localhost:8080/healthz")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("{\"status\":\"ok\"}\n", body)
+
+	code, body = get("http://localhost:8080/readyz")
+	assert.Equal(http.StatusServiceUnavailable, code)
+	assert.Equal("{\"status\":\"failing\",\"checks\":{\"database\":{\"status\":\"failing\",\"error\":\"Database is down\"},\"migrations\":{\"status\":\"ok\"}}}\n", body)
+
+	failing = nil
+	code, body = get("http://localhost:8080/readyz")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("{\"status\":\"ok\",\"checks\":{\"database\":{\"status\":\"ok\"},\"migrations\":{\"status\":\"ok\"}}}\n", body)
+
+	server.BeginShutdown()
+	code, body = get("http://localhost:8080/readyz")
+	assert.Equal(http.StatusServiceUnavailable, code)
+	assert.Equal("{\"status\":\"shutting_down\"}\n", body)
+
+	// Still alive while shutting down
+	code, _ = get("http://localhost:8080/healthz")
+	assert.Equal(http.StatusOK, code)
+}
+
+func TestServerRequestLogging(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	webServer := server.NewServer(config.Config{ServerPort: 8080})
+	defer webServer.Close()
+
+	webServer.Mux.HandleFunc("/api/v1/album/:id", func(out http.ResponseWriter, req *http.Request) {
+		logging.SetUser(req.Context(), "James")
+		logging.FromContext(req.Context()).Info("Loading album")
+
+		out.WriteHeader(http.StatusNotFound)
+		out.Write([]byte("Not here"))
+	})
+
+	for _, test := range []struct {
+		requestId string
+		kept      bool
+	}{
+		{"", false},
+		{"trace-1234.abc:5", true},
+		{"<script>", false},
+		{strings.Repeat("a", 129), false},
+	} {
+		hook.Reset()
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/v1/album/42", nil)
+		assert.Nil(err)
+		if test.requestId != "" {
+			req.Header.Set(server.RequestIdHeader, test.requestId)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(err)
+		resp.Body.Close()
+		assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+		requestId := resp.Header.Get(server.RequestIdHeader)
+		if test.kept {
+			assert.Equal(test.requestId, requestId)
+		} else {
+			assert.Len(requestId, 32, test.requestId)
+		}
+
+		// Both lines are logged once the response has been written
+		assert.Eventually(func() bool { return len(hook.AllEntries()) == 2 }, time.Second, time.Millisecond)
+		entries := hook.AllEntries()
+
+		assert.Equal("Loading album", entries[0].Message)
+		assert.Equal(requestId, entries[0].Data["request_id"])
+		assert.Equal("James", entries[0].Data["user"])
+
+		assert.Equal("Handled request", entries[1].Message)
+		assert.Equal(logrus.InfoLevel, entries[1].Level)
+		assert.Equal(requestId, entries[1].Data["request_id"])
+		assert.Equal("James", entries[1].Data["user"])
+		assert.Equal("GET", entries[1].Data["method"])
+		assert.Equal("/api/v1/album/:id", entries[1].Data["route"])
+		assert.Equal("/api/v1/album/42", entries[1].Data["path"])
+		assert.Equal(http.StatusNotFound, entries[1].Data["status"])
+		assert.Equal(8, entries[1].Data["bytes"])
+		assert.Contains(entries[1].Data, "latency_ms")
+	}
+
+	assert.Equal(float64(4), testutil.ToFloat64(metrics.HttpRequests.WithLabelValues("/api/v1/album/:id", "GET", "404")))
+}
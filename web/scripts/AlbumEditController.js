@@ -64,12 +64,12 @@ export class AlbumEditController
             .then(function(data)
             {
                 that._closeAllModals();
-                if (data.error === undefined)
+                if (data.code === undefined)
                 {
                     return;
                 }
 
-                console.error("Encountered a problem: " + data);
+                console.error("Encountered a problem: " + data.code + ": " + data.detail);
             })
             .catch(console.error);
     }
@@ -86,32 +86,57 @@ export class AlbumEditController
         const json = JSON.stringify(album);
         let uri = "/api/v1/album";
         let method = "POST";
+        let headers = {
+            "Content-Type": "application/json"
+        };
         if (album.id && album.id > 0)
         {
             uri = "/api/v1/album/" + album.id;
             method = "PUT";
+
+            // Only save over the copy being edited, not someone else's newer
+            // changes.
+            if (album.version)
+            {
+                headers["If-Match"] = "\"" + album.version + "\"";
+            }
         }
 
+        let stale = false;
         fetch(new Request(uri), {
             method: method,
-            headers: {
-                "Content-Type": "application/json"
-            },
+            headers: headers,
             body: json
         })
             .then(function(response)
             {
                 if (method == "PUT" && response.ok)
                 {
+                    if (album.version)
+                    {
+                        album.version++;
+                    }
                     return new Promise((kept, broken) => {
                         kept(album);
                     });
                 }
+                stale = response.status == 412;
                 return response.json();
             })
             .then(function(data)
             {
-                if (data.error === undefined)
+                if (stale)
+                {
+                    // The server sends back its current copy, show that so the
+                    // changes can be made again on top of it.
+                    alert("Someone else has changed this album since it was opened, showing their changes instead.");
+                    that._album = data.current;
+                    that._closeAllModals();
+                    that._loadAllArtists();
+                    return;
+                }
+
+                if (data.code === undefined)
                 {
                     that._album = data;
                     that._closeAllModals();
@@ -119,7 +144,7 @@ export class AlbumEditController
                     return;
                 }
 
-                console.error("Encountered a problem: " + data);
+                console.error("Encountered a problem: " + data.code + ": " + data.detail);
             })
             .catch(console.error);
     }
@@ -247,7 +272,7 @@ export class AlbumEditController
         {
             let t = document.createElement("div");
             t.classList.add("track");
-            t.textContent = track.title;
+            t.textContent = track.disc + "." + track.number + " " + track.title;
             t.setAttribute("data-track", JSON.stringify(track));
 
             t.addEventListener("dblclick", function(e)
@@ -326,13 +351,13 @@ export class AlbumEditController
             })
             .then(function(data)
             {
-                if (data.error === undefined)
+                if (data.code === undefined)
                 {
                     trackEle.textContent = trackTitle;
                     return;
                 }
 
-                console.error("Encountered a problem: " + data);
+                console.error("Encountered a problem: " + data.code + ": " + data.detail);
             })
             .catch(console.error);
     }
@@ -6,7 +6,7 @@ export class AlbumListController
     {
         this._selector = selector;
         this._albums = [];
-        this._request = new Request("/api/v1/album");
+        this._next = null;
 
         const that = this;
         document.body.addEventListener("reloadAlbums", function()
@@ -17,10 +17,16 @@ export class AlbumListController
     }
 
     _loadAllAlbums()
+    {
+        this._albums = [];
+        this._loadAlbumPage(0);
+    }
+
+    _loadAlbumPage(offset)
     {
         const that = this;
 
-        fetch(this._request)
+        fetch(new Request("/api/v1/album?offset=" + offset))
             .then(function(response)
             {
                 if (!response.ok)
@@ -32,7 +38,8 @@ export class AlbumListController
             })
             .then(function(data)
             {
-                that._albums = data;
+                that._albums = that._albums.concat(data.albums);
+                that._next = data.next;
                 that._drawAlbums();
             })
             .catch(console.error);
@@ -62,17 +69,36 @@ export class AlbumListController
     _saveAlbum(album)
     {
         const json = JSON.stringify(album);
+        let headers = {
+            "Content-Type": "application/json"
+        };
+        if (album.version)
+        {
+            // Only save over the copy we were showing, not someone else's
+            // newer changes.
+            headers["If-Match"] = "\"" + album.version + "\"";
+        }
+
         fetch(new Request("/api/v1/album/" + album.id), {
             method: "PUT",
-            headers: {
-                "Content-Type": "application/json"
-            },
+            headers: headers,
             body: json
         })
             .then(function(response)
             {
                 if (response.ok)
                 {
+                    if (album.version)
+                    {
+                        album.version++;
+                    }
+                    return;
+                }
+
+                if (response.status == 412)
+                {
+                    alert("Someone else has changed \"" + album.title + "\" since it was loaded, showing their changes instead.");
+                    document.body.dispatchEvent(new CustomEvent("reloadAlbums", {}));
                     return;
                 }
 
@@ -85,7 +111,7 @@ export class AlbumListController
                     return;
                 }
 
-                console.error("Encountered a problem: " + data);
+                console.error("Encountered a problem: " + data.code + ": " + data.detail);
             })
             .catch(console.error);
     }
@@ -161,7 +187,29 @@ export class AlbumListController
                 that._loadAllArtists(e.target);
             });
 
+            if (album.commentCount > 0)
+            {
+                let comments = document.createElement("span");
+                comments.classList.add("commentCount");
+                item.appendChild(comments).textContent = album.commentCount === 1
+                    ? "1 comment"
+                    : album.commentCount + " comments";
+            }
+
             container.appendChild(item);
         }
+
+        if (this._next !== null)
+        {
+            let more = document.createElement("button");
+            more.classList.add("moreAlbums");
+            more.textContent = "More albums";
+            more.addEventListener("click", function(e)
+            {
+                that._loadAlbumPage(that._next);
+            });
+
+            container.appendChild(more);
+        }
     }
 }
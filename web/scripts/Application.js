@@ -1,11 +1,16 @@
 import {AddButton} from "./AddButton.js";
 import {AlbumListController} from "./AlbumListController.js";
+import {LoginController} from "./LoginController.js";
 
 export class Application
 {
     constructor(bodySelector)
     {
-        this._albums = new AlbumListController(bodySelector);
-        this._addButton = new AddButton();
+        const that = this;
+        this._login = new LoginController(function()
+        {
+            that._albums = new AlbumListController(bodySelector);
+            that._addButton = new AddButton();
+        });
     }
 }
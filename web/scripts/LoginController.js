@@ -0,0 +1,107 @@
+export class LoginController
+{
+    constructor(onLogin)
+    {
+        this._onLogin = onLogin;
+
+        const that = this;
+        fetch(new Request("/api/v1/auth/session"))
+            .then(function(response)
+            {
+                if (response.ok)
+                {
+                    that._onLogin();
+                }
+                else
+                {
+                    that._drawForm();
+                }
+            })
+            .catch(console.error);
+    }
+
+    _drawForm()
+    {
+        this._form = document.createElement("form");
+        this._form.classList.add("loginForm");
+
+        this._username = document.createElement("input");
+        this._username.placeholder = "Username";
+
+        this._password = document.createElement("input");
+        this._password.type = "password";
+        this._password.placeholder = "Password";
+
+        this._error = document.createElement("p");
+        this._error.classList.add("error");
+
+        const submit = document.createElement("button");
+        submit.innerHTML = "Log in";
+
+        this._form.appendChild(this._username);
+        this._form.appendChild(this._password);
+        this._form.appendChild(submit);
+        this._form.appendChild(this._error);
+
+        const that = this;
+        fetch(new Request("/api/v1/auth/methods"))
+            .then(function(response)
+            {
+                return response.json();
+            })
+            .then(function(methods)
+            {
+                if (methods.oidc)
+                {
+                    const sso = document.createElement("a");
+                    sso.classList.add("sso");
+                    sso.href = "/api/v1/auth/oidc/login";
+                    sso.innerHTML = "Log in with single sign-on";
+                    that._form.insertBefore(sso, that._error);
+                }
+            })
+            .catch(console.error);
+
+        this._form.addEventListener("submit", function(e)
+        {
+            e.preventDefault();
+            that._login();
+        });
+
+        document.body.appendChild(this._form);
+    }
+
+    _login()
+    {
+        const that = this;
+        const json = JSON.stringify({
+            username: this._username.value,
+            password: this._password.value
+        });
+
+        fetch(new Request("/api/v1/auth/login"), {
+            method: "POST",
+            headers: {
+                "Content-Type": "application/json"
+            },
+            body: json
+        })
+            .then(function(response)
+            {
+                return response.json().then(function(data)
+                {
+                    if (!response.ok)
+                    {
+                        throw new Error(data.detail);
+                    }
+
+                    that._form.remove();
+                    that._onLogin();
+                });
+            })
+            .catch(function(err)
+            {
+                that._error.innerHTML = err.message;
+            });
+    }
+}